@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,14 +16,22 @@ import (
 	"firebase.google.com/go/v4/auth"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/dgsaltarin/SharedBitesBackend/config"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/analysiscache"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/events"
 	s3adapter "github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/filestore"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/filestore/encrypted"
+	localstore "github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/filestore/local"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/filestore/staging"
 	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/firebaseauth"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/imaging"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/keyprovider"
 	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/sql"
 	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/texttrack"
 	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driving/rest"
 	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driving/rest/hanlders"
 	appmiddleware "github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driving/rest/middlewares"
 	"github.com/dgsaltarin/SharedBitesBackend/internal/application"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
 	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
 	platformaws "github.com/dgsaltarin/SharedBitesBackend/platform/aws"
 	"github.com/dgsaltarin/SharedBitesBackend/platform/database"
@@ -32,6 +41,12 @@ import (
 	"google.golang.org/api/option"
 )
 
+// uploadSweepInterval is how often expired direct and resumable uploads are discarded
+const uploadSweepInterval = 10 * time.Minute
+
+// previewBackfillInterval is how often bills without previews are rendered
+const previewBackfillInterval = time.Minute
+
 // HandlersContainer remains useful for organizing handlers within main.
 type HandlersContainer struct {
 	UserHandler *hanlders.UserHandler
@@ -65,6 +80,15 @@ func main() {
 	sqlDB, _ := db.DB()
 	defer sqlDB.Close()
 
+	jobQueue := sql.NewPostgresJobQueue(db, cfg.Analysis.JobLease)
+	billEvents := sql.NewPostgresBillEventBus(ctx, db, cfg.Database.DSN, events.NewInProcessBillEventBus())
+	groupEventBus := sql.NewPostgresGroupEventBus(ctx, db, cfg.Database.DSN, events.NewInProcessGroupEventBus())
+
+	// Group events are published by bill uploads as well as by the group services
+	groupRepo := sql.NewGroupRepository(db)
+	groupEventRepo := sql.NewGORMGroupEventRepository(db)
+	groupEventService := application.NewGroupEventService(groupRepo, groupEventRepo, groupEventBus)
+
 	// Initialize AWS clients
 	var awsConfig aws.Config
 	var awsConfigErr error
@@ -73,11 +97,59 @@ func main() {
 		log.Printf("WARN: Failed to load AWS config: %v. AWS features may be unavailable.", awsConfigErr)
 	}
 
+	// Initialize the file store
+	var fileStore ports.FileStore
+	var signedFileHandler *hanlders.SignedFileHandler
+	filesURL := strings.TrimRight(cfg.ShareLink.PublicBaseURL, "/") + "/api/v1/files"
+	switch cfg.Storage.Backend {
+	case "local":
+		localFileStore, err := localstore.NewLocalFileStore(localstore.Options{
+			Root:     cfg.Storage.LocalRoot,
+			Secret:   cfg.Storage.URLSecret,
+			FilesURL: filesURL,
+			URLTTL:   cfg.Storage.URLTTL,
+		})
+		if err != nil {
+			log.Printf("WARN: Failed to initialize local file store: %v. File storage features unavailable.", err)
+		} else {
+			fileStore = localFileStore
+			signedFileHandler = hanlders.NewSignedFileHandler(localFileStore)
+		}
+	default:
+		if awsConfigErr == nil {
+			fileStore, err = s3adapter.NewS3FileStore(ctx, cfg.AWS)
+			if err != nil {
+				log.Printf("WARN: Failed to initialize S3 file store: %v. File storage features unavailable.", err)
+			}
+		}
+	}
+
+	// Encrypt stored files when a master key is configured. Downloads then go
+	// through the API, which decrypts the files it serves.
+	rawFileStore := fileStore
+	if fileStore != nil && cfg.Storage.EncryptionKeyID != "" {
+		keys, err := keyprovider.NewStaticKeyProviderFromConfig(cfg.Storage)
+		if err != nil {
+			log.Fatalf("Failed to load storage encryption keys: %v", err)
+		}
+		encryptedFileStore, err := encrypted.NewEncryptedFileStore(fileStore, keys, encrypted.Options{
+			Secret:   cfg.Storage.URLSecret,
+			FilesURL: filesURL,
+			URLTTL:   cfg.Storage.URLTTL,
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize encrypted file store: %v", err)
+		}
+		fileStore = encryptedFileStore
+		signedFileHandler = hanlders.NewSignedFileHandler(encryptedFileStore)
+	}
+
 	// Initialize Textract client
 	var textractClient *platformaws.TextractClient
-	var textProcessor *texttrack.AWSTextractAdapter
-	var fileStore ports.FileStore
+	var textProcessor ports.TextProcessor
 	var billHandler *hanlders.BillHandler
+	var analysisWorkers *application.AnalysisWorkerPool
+	var analysisAdminHandler *hanlders.AnalysisAdminHandler
 
 	if awsConfigErr == nil {
 		textractClient, err = platformaws.NewTextractClient(ctx, cfg.AWS)
@@ -85,41 +157,127 @@ func main() {
 			log.Printf("WARN: Failed to initialize AWS Textract client: %v. Textract features unavailable.", err)
 		}
 
-		textProcessor = texttrack.NewAWSTextractAdapter(awsConfig)
+		// Textract only reads plain documents from AWS S3, others are sent to it
+		var documentStore ports.FileStore
+		if cfg.Storage.Backend == "local" || cfg.AWS.S3Endpoint != "" || cfg.Storage.EncryptionKeyID != "" {
+			documentStore = fileStore
+		}
+		textractAdapter := texttrack.NewAWSTextractAdapter(awsConfig, cfg.AWS.TextractMaxConcurrency, cfg.AWS.TextractPollInterval, documentStore)
+		textProcessor = textractAdapter
 
-		fileStore, err = s3adapter.NewS3FileStore(ctx, cfg.AWS)
-		if err != nil {
-			log.Printf("WARN: Failed to initialize S3 file store: %v. File storage features unavailable.", err)
+		// Responses are cached in memory, in Postgres or in both
+		var analysisCache ports.AnalysisCache
+		if cfg.Analysis.CachePostgres {
+			analysisCache = sql.NewPostgresAnalysisCache(db, cfg.Analysis.CacheTTL, cfg.Analysis.CacheMaxEntries)
+		}
+		if cfg.Analysis.CacheMaxBytes > 0 {
+			analysisCache = analysiscache.NewLRUCache(cfg.Analysis.CacheMaxBytes, cfg.Analysis.CacheTTL, analysisCache)
 		}
+		if analysisCache != nil && fileStore != nil {
+			textProcessor = texttrack.NewCachingAnalyzer(textractAdapter, fileStore, analysisCache)
+		}
+	} else {
+		log.Println("WARN: Textract unavailable, bills will be stored but can't be analyzed.")
+	}
 
-		// Only initialize the bill service if all AWS dependencies are available
-		if textractClient != nil && textProcessor != nil && fileStore != nil {
-			billService := application.NewBillService(textractClient, fileStore, textProcessor, db)
-			billHandler = hanlders.NewBillHandler(billService)
-		} else {
-			log.Println("WARN: BillService not initialized due to missing AWS dependencies.")
+	// Bills can be uploaded and stored as long as there is a file store
+	if fileStore != nil {
+		retryPolicy := domain.RetryPolicy{
+			MaxAttempts: cfg.Analysis.MaxAttempts,
+			BaseDelay:   cfg.Analysis.RetryBaseDelay,
+			MaxDelay:    cfg.Analysis.RetryMaxDelay,
 		}
+		var imageProcessor ports.ImageProcessor
+		if cfg.Image.Normalize {
+			imageProcessor = imaging.NewImageNormalizer(imaging.Options{
+				MaxDimension: cfg.Image.MaxDimension,
+				JPEGQuality:  cfg.Image.JPEGQuality,
+				Deskew:       cfg.Image.Deskew,
+				Crop:         cfg.Image.Crop,
+				Converter:    cfg.Image.Converter,
+				MaxPixels:    cfg.Image.MaxPixels,
+			})
+		}
+		var uploadStager ports.UploadStager
+		switch cfg.Upload.Staging {
+		case "s3":
+			// The S3 file store stages chunks as parts of a multipart upload. The
+			// staged files are encrypted when the upload is completed.
+			uploadStager, _ = rawFileStore.(ports.UploadStager)
+		default:
+			diskStager, err := staging.NewDiskStager(cfg.Upload.StagingDir, fileStore)
+			if err != nil {
+				log.Printf("WARN: Failed to initialize upload staging: %v. Resumable uploads unavailable.", err)
+			} else {
+				uploadStager = diskStager
+			}
+		}
+		previewRenderer := imaging.NewPreviewRenderer(imaging.PreviewOptions{
+			ThumbnailDimension: cfg.Image.ThumbnailDimension,
+			PreviewDimension:   cfg.Image.PreviewDimension,
+			JPEGQuality:        cfg.Image.JPEGQuality,
+			Converter:          cfg.Image.Converter,
+			MaxPixels:          cfg.Image.MaxPixels,
+		})
+		billService := application.NewBillService(textractClient, fileStore, textProcessor, imageProcessor, previewRenderer, jobQueue, billEvents, groupEventService, retryPolicy,
+			domain.DuplicatePolicy(cfg.Upload.DuplicatePolicy), uploadStager, cfg.Upload.ResumableTTL, cfg.Image.MaxPixels, db)
+		billHandler = hanlders.NewBillHandler(billService, hanlders.UploadLimits{
+			MaxFileBytes:    cfg.Upload.MaxFileBytes,
+			MaxRequestBytes: cfg.Upload.MaxRequestBytes,
+		})
+		analysisAdminHandler = hanlders.NewAnalysisAdminHandler(billService)
+
+		analysisWorkers = application.NewAnalysisWorkerPool(jobQueue, billService.ProcessAnalysisJob,
+			cfg.Analysis.Workers, cfg.Analysis.PollInterval, cfg.Analysis.JobTimeout)
+		analysisWorkers.Start()
+
+		go expireUploads(ctx, billService)
+		go renderMissingPreviews(ctx, billService)
+	} else {
+		log.Println("WARN: BillService not initialized due to missing file store.")
 	}
 
 	// Initialize repositories
 	userRepo := sql.NewGORMUserRepository(db)
-	groupRepo := sql.NewGroupRepository(db)
+	billRepo := sql.NewGORMBillRepository(db)
+	claimRepo := sql.NewGORMClaimSessionRepository(db)
 
 	// Initialize services
 	userService := application.NewUserService(userRepo, firebaseAuthProvider)
-	groupService := application.NewGroupService(groupRepo)
+	groupService := application.NewGroupService(groupRepo, userRepo, groupEventService)
+	splitService := application.NewSplitService(billRepo, groupRepo, groupEventService)
+	claimService := application.NewClaimService(billRepo, groupRepo, claimRepo, groupEventService)
+	correctionService := application.NewBillCorrectionService(billRepo, fileStore)
 
 	// Initialize handlers
 	userHandler := hanlders.NewUserHandler(*userService)
 	groupHandler := hanlders.NewGroupHandler(groupService)
+	splitHandler := hanlders.NewSplitHandler(splitService)
+
+	var shareLinkService *application.ShareLinkService
+	var shareHandler *hanlders.ShareHandler
+	if cfg.ShareLink.Secret != "" {
+		shareLinkService = application.NewShareLinkService(splitService, groupRepo, cfg.ShareLink.Secret, cfg.ShareLink.TTL, cfg.ShareLink.PublicBaseURL)
+		shareHandler = hanlders.NewShareHandler(shareLinkService)
+	} else {
+		log.Println("WARN: SHARE_LINK_SECRET not set. Guest share links unavailable.")
+	}
+	claimHandler := hanlders.NewClaimHandler(claimService, shareLinkService)
+	correctionHandler := hanlders.NewBillCorrectionHandler(correctionService)
+	groupEventsHandler := hanlders.NewGroupEventsHandler(groupEventService)
 
 	// Setup router
-	router := setupRouter(userHandler, billHandler, authClient, userService, groupHandler)
+	router := setupRouter(userHandler, billHandler, authClient, userService, groupHandler, splitHandler, shareHandler, claimHandler, correctionHandler, analysisAdminHandler, groupEventsHandler, signedFileHandler)
 
 	server := &http.Server{
 		Addr:    ":" + cfg.Server.Port,
 		Handler: router,
 	}
+	// Event streams never finish on their own, end them so shutdown doesn't wait for them
+	server.RegisterOnShutdown(func() {
+		billEvents.Close()
+		groupEventBus.Close()
+	})
 
 	go func() {
 		log.Printf("Starting server on port %s", cfg.Server.Port)
@@ -138,6 +296,66 @@ func main() {
 		log.Fatalf("Server shutdown failed: %v", err)
 	}
 	log.Println("Server stopped successfully")
+
+	if analysisWorkers != nil {
+		workersCtx, workersCancel := context.WithTimeout(context.Background(), cfg.Analysis.ShutdownTimeout)
+		defer workersCancel()
+
+		if err := analysisWorkers.Shutdown(workersCtx); err != nil {
+			log.Printf("WARN: Analysis workers did not finish in time: %v", err)
+		} else {
+			log.Println("Analysis workers stopped successfully")
+		}
+	}
+}
+
+// expireUploads discards expired resumable uploads and bills still awaiting
+// their file every uploadSweepInterval until ctx is canceled
+func expireUploads(ctx context.Context, billService *application.BillService) {
+	ticker := time.NewTicker(uploadSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := billService.ExpireResumableUploads(ctx)
+			if err != nil {
+				log.Printf("WARN: Failed to expire resumable uploads: %v", err)
+			} else if expired > 0 {
+				log.Printf("Discarded %d expired resumable uploads", expired)
+			}
+
+			expired, err = billService.ExpireBillUploads(ctx)
+			if err != nil {
+				log.Printf("WARN: Failed to expire bill uploads: %v", err)
+			} else if expired > 0 {
+				log.Printf("Deleted %d bills whose upload was never completed", expired)
+			}
+		}
+	}
+}
+
+// renderMissingPreviews renders the previews of bills uploaded before they
+// existed every previewBackfillInterval until ctx is canceled
+func renderMissingPreviews(ctx context.Context, billService *application.BillService) {
+	ticker := time.NewTicker(previewBackfillInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rendered, err := billService.RenderMissingPreviews(ctx)
+			if err != nil {
+				log.Printf("WARN: Failed to render missing previews: %v", err)
+			} else if rendered > 0 {
+				log.Printf("Rendered previews of %d bills", rendered)
+			}
+		}
+	}
 }
 
 func initFirebase(ctx context.Context, serviceAccountKeyPath string) (*firebase.App, error) {
@@ -150,7 +368,10 @@ func initFirebase(ctx context.Context, serviceAccountKeyPath string) (*firebase.
 }
 
 func setupRouter(userHandler *hanlders.UserHandler, billHandler *hanlders.BillHandler,
-	authClient *auth.Client, userService *application.UserService, groupHandler *hanlders.GroupHandler) *gin.Engine {
+	authClient *auth.Client, userService *application.UserService, groupHandler *hanlders.GroupHandler,
+	splitHandler *hanlders.SplitHandler, shareHandler *hanlders.ShareHandler, claimHandler *hanlders.ClaimHandler,
+	correctionHandler *hanlders.BillCorrectionHandler, analysisAdminHandler *hanlders.AnalysisAdminHandler,
+	groupEventsHandler *hanlders.GroupEventsHandler, signedFileHandler *hanlders.SignedFileHandler) *gin.Engine {
 	router := gin.Default()
 
 	router.GET("/healthcheck", func(c *gin.Context) {
@@ -168,7 +389,7 @@ func setupRouter(userHandler *hanlders.UserHandler, billHandler *hanlders.BillHa
 	protectedApiV1.Use(appmiddleware.FirebaseAuthMiddleware(authClient))
 	protectedApiV1.Use(appmiddleware.UserLookupMiddleware(userService))
 
-	rest.SetupAppRoutes(publicApiV1, protectedApiV1, userHandler, billHandler, groupHandler)
+	rest.SetupAppRoutes(publicApiV1, protectedApiV1, userHandler, billHandler, groupHandler, splitHandler, shareHandler, claimHandler, correctionHandler, analysisAdminHandler, groupEventsHandler, signedFileHandler)
 
 	return router
 }
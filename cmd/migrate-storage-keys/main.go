@@ -0,0 +1,65 @@
+// Command migrate-storage-keys moves bill files stored under their uploaded
+// filename to unique keys and updates the bills to point at them.
+//
+// Usage:
+//
+//	go run ./cmd/migrate-storage-keys [-dry-run]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/dgsaltarin/SharedBitesBackend/config"
+	s3adapter "github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/filestore"
+	localstore "github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/filestore/local"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/application"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+	"github.com/dgsaltarin/SharedBitesBackend/platform/database"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report the bills that would be moved without changing anything")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	cfg := config.MustLoad(logger)
+	ctx := context.Background()
+
+	db := database.MustConnectGORM(cfg.Database)
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+
+	var fileStore ports.FileStore
+	var err error
+	switch cfg.Storage.Backend {
+	case "local":
+		fileStore, err = localstore.NewLocalFileStore(localstore.Options{
+			Root:   cfg.Storage.LocalRoot,
+			Secret: cfg.Storage.URLSecret,
+		})
+	default:
+		fileStore, err = s3adapter.NewS3FileStore(ctx, cfg.AWS)
+	}
+	if err != nil {
+		log.Fatalf("Failed to initialize file store: %v", err)
+	}
+
+	report, err := application.NewStorageKeyMigrator(fileStore, db).Migrate(ctx, *dryRun)
+	if err != nil {
+		log.Fatalf("Storage key migration failed: %v", err)
+	}
+
+	if *dryRun {
+		log.Printf("Dry run: %d bills would be moved, %d of them share their file with another bill", report.Migrated, report.Shared)
+		return
+	}
+	log.Printf("Moved %d bills to unique storage keys, %d failed, %d shared their file with another bill",
+		report.Migrated, report.Failed, report.Shared)
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,55 @@
+// Command reparse-analyses parses the stored Textract responses of bills again
+// after the parser was improved, without analyzing their files again. Only bills
+// whose last analysis was parsed by an older parser version are updated, and
+// corrections made by users are kept.
+//
+// Usage:
+//
+//	go run ./cmd/reparse-analyses [-dry-run]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/dgsaltarin/SharedBitesBackend/config"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/texttrack"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/application"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/platform/database"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report the bills that would be parsed again without changing anything")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	cfg := config.MustLoad(logger)
+	ctx := context.Background()
+
+	db := database.MustConnectGORM(cfg.Database)
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+
+	// Parsing stored responses needs neither Textract nor the file store
+	billService := application.NewBillService(nil, nil, nil, nil, nil, nil, nil, nil, domain.RetryPolicy{},
+		domain.DuplicatePolicy(cfg.Upload.DuplicatePolicy), nil, 0, 0, db)
+
+	report, err := billService.ReparseAnalyses(ctx, *dryRun)
+	if err != nil {
+		log.Fatalf("Reparsing analyses failed: %v", err)
+	}
+
+	if *dryRun {
+		log.Printf("Dry run: %d bills would be parsed again with parser version %d", report.Reparsed, texttrack.ParserVersion)
+		return
+	}
+	log.Printf("Parsed %d bills again with parser version %d, %d skipped while being analyzed or edited, %d failed",
+		report.Reparsed, texttrack.ParserVersion, report.Skipped, report.Failed)
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,83 @@
+// Command rotate-storage-keys rewraps the data keys of encrypted bill files with
+// the master key named by STORAGE_ENCRYPTION_KEY_ID, and encrypts the files stored
+// before encryption was enabled. Once it has run without failures, retired master
+// keys can be removed from STORAGE_ENCRYPTION_KEYS.
+//
+// Usage:
+//
+//	go run ./cmd/rotate-storage-keys [-dry-run]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/dgsaltarin/SharedBitesBackend/config"
+	s3adapter "github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/filestore"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/filestore/encrypted"
+	localstore "github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/filestore/local"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/keyprovider"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/application"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+	"github.com/dgsaltarin/SharedBitesBackend/platform/database"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report the files that would be rotated without changing anything")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	cfg := config.MustLoad(logger)
+	ctx := context.Background()
+
+	if cfg.Storage.EncryptionKeyID == "" {
+		log.Fatalf("STORAGE_ENCRYPTION_KEY_ID must name the master key to rotate to")
+	}
+
+	db := database.MustConnectGORM(cfg.Database)
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+
+	var fileStore ports.FileStore
+	var err error
+	switch cfg.Storage.Backend {
+	case "local":
+		fileStore, err = localstore.NewLocalFileStore(localstore.Options{
+			Root:   cfg.Storage.LocalRoot,
+			Secret: cfg.Storage.URLSecret,
+		})
+	default:
+		fileStore, err = s3adapter.NewS3FileStore(ctx, cfg.AWS)
+	}
+	if err != nil {
+		log.Fatalf("Failed to initialize file store: %v", err)
+	}
+
+	keys, err := keyprovider.NewStaticKeyProviderFromConfig(cfg.Storage)
+	if err != nil {
+		log.Fatalf("Failed to load storage encryption keys: %v", err)
+	}
+	encryptedFileStore, err := encrypted.NewEncryptedFileStore(fileStore, keys, encrypted.Options{Secret: cfg.Storage.URLSecret})
+	if err != nil {
+		log.Fatalf("Failed to initialize encrypted file store: %v", err)
+	}
+
+	report, err := application.NewMasterKeyRotator(encryptedFileStore, db).Rotate(ctx, *dryRun)
+	if err != nil {
+		log.Fatalf("Master key rotation failed: %v", err)
+	}
+
+	if *dryRun {
+		log.Printf("Dry run: %d files would be rotated to master key %s and %d encrypted, %d already use it, %d can't be read",
+			report.Rotated, keys.CurrentKeyID(), report.Encrypted, report.Current, report.Failed)
+		return
+	}
+	log.Printf("Rotated %d files to master key %s and encrypted %d, %d already used it, %d failed",
+		report.Rotated, keys.CurrentKeyID(), report.Encrypted, report.Current, report.Failed)
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
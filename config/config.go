@@ -5,16 +5,26 @@ import (
 	"log"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
 )
 
 type AWSConfig struct {
-	Region          string `envconfig:"AWS_REGION" required:"true"`
+	Region          string `envconfig:"AWS_REGION"`
 	AccessKeyID     string `envconfig:"AWS_ACCESS_KEY_ID"`
 	SecretAccessKey string `envconfig:"AWS_SECRET_ACCESS_KEY"`
 	S3Bucket        string `envconfig:"AWS_S3_BUCKET"`
+
+	// S3-compatible stores such as MinIO, LocalStack or Ceph
+	S3Endpoint        string `envconfig:"AWS_S3_ENDPOINT"`
+	S3UsePathStyle    bool   `envconfig:"AWS_S3_USE_PATH_STYLE" default:"false"`
+	S3AccessKeyID     string `envconfig:"AWS_S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `envconfig:"AWS_S3_SECRET_ACCESS_KEY"`
+
+	TextractMaxConcurrency int           `envconfig:"AWS_TEXTRACT_MAX_CONCURRENCY" default:"8"`
+	TextractPollInterval   time.Duration `envconfig:"AWS_TEXTRACT_POLL_INTERVAL" default:"2s"`
 }
 
 type FirebaseConfig struct {
@@ -31,11 +41,75 @@ type DatabaseConfig struct {
 	DSN string `envconfig:"DB_DSN" required:"true"`
 }
 
+type ShareLinkConfig struct {
+	Secret        string        `envconfig:"SHARE_LINK_SECRET"`
+	TTL           time.Duration `envconfig:"SHARE_LINK_TTL" default:"720h"`
+	PublicBaseURL string        `envconfig:"PUBLIC_BASE_URL" default:"http://localhost:8080"`
+}
+
+type AnalysisConfig struct {
+	Workers         int           `envconfig:"ANALYSIS_WORKERS" default:"4"`
+	PollInterval    time.Duration `envconfig:"ANALYSIS_POLL_INTERVAL" default:"1s"`
+	JobTimeout      time.Duration `envconfig:"ANALYSIS_JOB_TIMEOUT" default:"2m"`
+	JobLease        time.Duration `envconfig:"ANALYSIS_JOB_LEASE" default:"10m"`
+	ShutdownTimeout time.Duration `envconfig:"ANALYSIS_SHUTDOWN_TIMEOUT" default:"30s"`
+	MaxAttempts     int           `envconfig:"ANALYSIS_MAX_ATTEMPTS" default:"5"`
+	RetryBaseDelay  time.Duration `envconfig:"ANALYSIS_RETRY_BASE_DELAY" default:"10s"`
+	RetryMaxDelay   time.Duration `envconfig:"ANALYSIS_RETRY_MAX_DELAY" default:"10m"`
+
+	// Textract responses cached by document hash
+	CacheMaxBytes   int64         `envconfig:"ANALYSIS_CACHE_MAX_BYTES" default:"67108864"`
+	CacheTTL        time.Duration `envconfig:"ANALYSIS_CACHE_TTL" default:"720h"`
+	CachePostgres   bool          `envconfig:"ANALYSIS_CACHE_POSTGRES" default:"false"`
+	CacheMaxEntries int           `envconfig:"ANALYSIS_CACHE_MAX_ENTRIES" default:"10000"`
+}
+
+type ImageConfig struct {
+	Normalize    bool   `envconfig:"IMAGE_NORMALIZE" default:"true"`
+	MaxDimension int    `envconfig:"IMAGE_MAX_DIMENSION" default:"2500"`
+	JPEGQuality  int    `envconfig:"IMAGE_JPEG_QUALITY" default:"85"`
+	Deskew       bool   `envconfig:"IMAGE_DESKEW" default:"false"`
+	Crop         bool   `envconfig:"IMAGE_CROP" default:"false"`
+	Converter    string `envconfig:"IMAGE_CONVERTER" default:"magick"`
+	MaxPixels    int    `envconfig:"IMAGE_MAX_PIXELS" default:"50000000"`
+
+	// Renditions shown in bill lists and previews
+	ThumbnailDimension int `envconfig:"IMAGE_THUMBNAIL_DIMENSION" default:"256"`
+	PreviewDimension   int `envconfig:"IMAGE_PREVIEW_DIMENSION" default:"1024"`
+}
+
+type UploadConfig struct {
+	DuplicatePolicy string `envconfig:"DUPLICATE_POLICY" default:"flag"`
+	MaxFileBytes    int64  `envconfig:"UPLOAD_MAX_FILE_BYTES" default:"10485760"`
+	MaxRequestBytes int64  `envconfig:"UPLOAD_MAX_REQUEST_BYTES" default:"104857600"`
+
+	// Resumable uploads
+	Staging      string        `envconfig:"UPLOAD_STAGING" default:"disk"`
+	StagingDir   string        `envconfig:"UPLOAD_STAGING_DIR" default:"./data/uploads"`
+	ResumableTTL time.Duration `envconfig:"UPLOAD_RESUMABLE_TTL" default:"24h"`
+}
+
+type StorageConfig struct {
+	Backend   string        `envconfig:"STORAGE_BACKEND" default:"s3"`
+	LocalRoot string        `envconfig:"STORAGE_LOCAL_ROOT" default:"./data/files"`
+	URLSecret string        `envconfig:"STORAGE_URL_SECRET"`
+	URLTTL    time.Duration `envconfig:"STORAGE_URL_TTL" default:"15m"`
+
+	// Envelope encryption of stored files, enabled when a current key is set
+	EncryptionKeys  string `envconfig:"STORAGE_ENCRYPTION_KEYS"`
+	EncryptionKeyID string `envconfig:"STORAGE_ENCRYPTION_KEY_ID"`
+}
+
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	AWS      AWSConfig
-	Firebase FirebaseConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	AWS       AWSConfig
+	Firebase  FirebaseConfig
+	ShareLink ShareLinkConfig
+	Analysis  AnalysisConfig
+	Image     ImageConfig
+	Upload    UploadConfig
+	Storage   StorageConfig
 }
 
 func Load(logger *slog.Logger) (*Config, error) {
@@ -58,6 +132,41 @@ func Load(logger *slog.Logger) (*Config, error) {
 		return nil, fmt.Errorf("failed to load config from environment: %w", err)
 	}
 
+	switch cfg.Upload.DuplicatePolicy {
+	case "off", "flag", "block":
+	default:
+		return nil, fmt.Errorf("invalid DUPLICATE_POLICY %q, expected off, flag or block", cfg.Upload.DuplicatePolicy)
+	}
+
+	switch cfg.Upload.Staging {
+	case "disk":
+	case "s3":
+		if cfg.Storage.Backend != "s3" {
+			return nil, fmt.Errorf("UPLOAD_STAGING=s3 requires STORAGE_BACKEND=s3")
+		}
+	default:
+		return nil, fmt.Errorf("invalid UPLOAD_STAGING %q, expected disk or s3", cfg.Upload.Staging)
+	}
+
+	switch cfg.Storage.Backend {
+	case "s3":
+	case "local":
+		if cfg.Storage.URLSecret == "" {
+			return nil, fmt.Errorf("STORAGE_URL_SECRET is required with STORAGE_BACKEND=local")
+		}
+	default:
+		return nil, fmt.Errorf("invalid STORAGE_BACKEND %q, expected s3 or local", cfg.Storage.Backend)
+	}
+	if cfg.Storage.EncryptionKeyID != "" {
+		if cfg.Storage.EncryptionKeys == "" {
+			return nil, fmt.Errorf("STORAGE_ENCRYPTION_KEYS is required with STORAGE_ENCRYPTION_KEY_ID")
+		}
+		// Encrypted files are served by the API through signed URLs
+		if cfg.Storage.URLSecret == "" {
+			return nil, fmt.Errorf("STORAGE_URL_SECRET is required with STORAGE_ENCRYPTION_KEY_ID")
+		}
+	}
+
 	// envconfig handles 'required' and 'default' tags.
 	// Additional custom validation can be done here if needed.
 	// For example, check if ServiceAccountKeyPath file exists:
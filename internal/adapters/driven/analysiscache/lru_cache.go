@@ -0,0 +1,119 @@
+package analysiscache
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+)
+
+// LRUCache keeps Textract responses in memory, evicting the least recently used
+// ones once they take more than maxBytes. Entries expire after ttl. When a next
+// cache is set, like the Postgres one, responses are written through to it and
+// read from it on a miss, so they outlive a restart.
+type LRUCache struct {
+	maxBytes int64
+	ttl      time.Duration
+	next     ports.AnalysisCache
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List               // most recently used first
+	entries map[string]*list.Element // content hash to element of order
+}
+
+type cacheEntry struct {
+	contentHash string
+	response    []byte
+	expiresAt   time.Time
+}
+
+// NewLRUCache creates an in-memory cache of at most maxBytes of responses in
+// front of next, which may be nil
+func NewLRUCache(maxBytes int64, ttl time.Duration, next ports.AnalysisCache) *LRUCache {
+	return &LRUCache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		next:     next,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached response for a document, from memory or else from the next cache
+func (c *LRUCache) Get(ctx context.Context, contentHash string) ([]byte, bool, error) {
+	if response, ok := c.get(contentHash); ok {
+		return response, true, nil
+	}
+	if c.next == nil {
+		return nil, false, nil
+	}
+
+	response, ok, err := c.next.Get(ctx, contentHash)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	c.put(contentHash, response)
+	return response, true, nil
+}
+
+// Put caches the response for a document in memory and in the next cache
+func (c *LRUCache) Put(ctx context.Context, contentHash string, response []byte) error {
+	c.put(contentHash, response)
+	if c.next == nil {
+		return nil
+	}
+	return c.next.Put(ctx, contentHash, response)
+}
+
+func (c *LRUCache) get(contentHash string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[contentHash]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.response, true
+}
+
+func (c *LRUCache) put(contentHash string, response []byte) {
+	size := int64(len(response))
+	if size > c.maxBytes {
+		log.Printf("Analysis response of %d bytes is larger than the cache, it is not kept in memory", size)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[contentHash]; ok {
+		c.remove(element)
+	}
+	c.entries[contentHash] = c.order.PushFront(&cacheEntry{
+		contentHash: contentHash,
+		response:    response,
+		expiresAt:   time.Now().Add(c.ttl),
+	})
+	c.size += size
+
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops an element, with c.mu held
+func (c *LRUCache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.contentHash)
+	c.size -= int64(len(entry.response))
+}
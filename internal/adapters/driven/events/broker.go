@@ -0,0 +1,89 @@
+package events
+
+import (
+	"log"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind before
+// events are dropped for it
+const subscriberBuffer = 16
+
+// broker fans events out to the subscribers of a key, such as a user or a group.
+type broker[E any] struct {
+	name        string
+	mu          sync.RWMutex
+	subscribers map[uuid.UUID]map[chan E]struct{}
+	closed      bool
+}
+
+func newBroker[E any](name string) *broker[E] {
+	return &broker[E]{
+		name:        name,
+		subscribers: make(map[uuid.UUID]map[chan E]struct{}),
+	}
+}
+
+// publish sends the event to every subscriber of key without blocking.
+func (b *broker[E]) publish(key uuid.UUID, event E) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers[key] {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("Dropping %s event for %s: subscriber is not keeping up", b.name, key)
+		}
+	}
+}
+
+// subscribe registers a subscriber for the events of key.
+func (b *broker[E]) subscribe(key uuid.UUID) (<-chan E, func()) {
+	ch := make(chan E, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subscribers[key] == nil {
+		b.subscribers[key] = make(map[chan E]struct{})
+	}
+	b.subscribers[key][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subscribers[key][ch]; !ok {
+				return // already closed by close
+			}
+			delete(b.subscribers[key], ch)
+			if len(b.subscribers[key]) == 0 {
+				delete(b.subscribers, key)
+			}
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
+
+// close ends every subscription. Later subscriptions are closed right away.
+func (b *broker[E]) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, channels := range b.subscribers {
+		for ch := range channels {
+			close(ch)
+		}
+	}
+	b.subscribers = make(map[uuid.UUID]map[chan E]struct{})
+	b.closed = true
+}
@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+	"github.com/google/uuid"
+)
+
+type inProcessBillEventBus struct {
+	broker *broker[*domain.BillEvent]
+}
+
+// NewInProcessBillEventBus creates a bus that delivers events to the
+// subscribers of this process only.
+func NewInProcessBillEventBus() ports.BillEventBus {
+	return &inProcessBillEventBus{broker: newBroker[*domain.BillEvent]("bill")}
+}
+
+// Publish sends the event to every subscriber of the bill owner without blocking.
+func (b *inProcessBillEventBus) Publish(ctx context.Context, event *domain.BillEvent) error {
+	b.broker.publish(event.UserID, event)
+	return nil
+}
+
+// Subscribe registers a subscriber for the events of the user's bills.
+func (b *inProcessBillEventBus) Subscribe(userID uuid.UUID) (<-chan *domain.BillEvent, func()) {
+	return b.broker.subscribe(userID)
+}
+
+// Close ends every subscription.
+func (b *inProcessBillEventBus) Close() error {
+	b.broker.close()
+	return nil
+}
+
+type inProcessGroupEventBus struct {
+	broker *broker[*domain.GroupEvent]
+}
+
+// NewInProcessGroupEventBus creates a bus that delivers group events to the
+// subscribers of this process only.
+func NewInProcessGroupEventBus() ports.GroupEventBus {
+	return &inProcessGroupEventBus{broker: newBroker[*domain.GroupEvent]("group")}
+}
+
+// Publish sends the event to every subscriber of the group without blocking.
+func (b *inProcessGroupEventBus) Publish(ctx context.Context, event *domain.GroupEvent) error {
+	b.broker.publish(event.GroupID, event)
+	return nil
+}
+
+// Subscribe registers a subscriber for the events of the group.
+func (b *inProcessGroupEventBus) Subscribe(groupID uuid.UUID) (<-chan *domain.GroupEvent, func()) {
+	return b.broker.subscribe(groupID)
+}
+
+// Close ends every subscription.
+func (b *inProcessGroupEventBus) Close() error {
+	b.broker.close()
+	return nil
+}
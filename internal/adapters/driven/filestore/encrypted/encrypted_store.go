@@ -0,0 +1,290 @@
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/filestore/signedurl"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+)
+
+// dataKeySize is the size of the AES-256 key each file is encrypted with
+const dataKeySize = 32
+
+// storedContentType is the content type the underlying store keeps encrypted
+// files with, as their real one is only known once they are decrypted
+const storedContentType = "application/octet-stream"
+
+// Options configures the encrypting file store
+type Options struct {
+	Secret   string        // key download URLs are signed with
+	FilesURL string        // public URL of the API routes serving the files
+	URLTTL   time.Duration // how long download URLs stay valid
+}
+
+// FileStore encrypts the files of another store with envelope encryption: each
+// file is sealed with AES-GCM under a data key of its own, stored with the file
+// after being wrapped by a master key of the KeyProvider. The underlying store
+// only ever sees ciphertext, so its own download URLs would be of no use to
+// clients; download URLs point at the API instead, which decrypts the files it
+// serves. Files stored before encryption was enabled are read as they are.
+type FileStore struct {
+	files  ports.FileStore
+	keys   ports.KeyProvider
+	signer *signedurl.Signer
+}
+
+// NewEncryptedFileStore creates a file store encrypting the files of files with
+// data keys wrapped by keys
+func NewEncryptedFileStore(files ports.FileStore, keys ports.KeyProvider, options Options) (*FileStore, error) {
+	if files == nil || keys == nil {
+		return nil, fmt.Errorf("a file store and a key provider must be specified for the encrypted file store")
+	}
+	if options.Secret == "" {
+		return nil, fmt.Errorf("a secret to sign download URLs must be specified for the encrypted file store")
+	}
+	return &FileStore{
+		files:  files,
+		keys:   keys,
+		signer: signedurl.NewSigner(options.Secret, options.FilesURL, options.URLTTL),
+	}, nil
+}
+
+// UploadFile encrypts a file and stores it in the underlying store
+func (s *FileStore) UploadFile(ctx context.Context, file io.Reader, destinationPath string, metadata ports.FileMetadata) (string, error) {
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s: %w", destinationPath, err)
+	}
+	sealed, err := s.seal(ctx, content, metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt file %s: %w", destinationPath, err)
+	}
+	return s.files.UploadFile(ctx, bytes.NewReader(sealed), destinationPath, ports.FileMetadata{ContentType: storedContentType})
+}
+
+// GetFileURL returns a signed download URL for a stored file, served decrypted by the API
+func (s *FileStore) GetFileURL(ctx context.Context, storagePath string) (string, error) {
+	return s.signer.DownloadURL(s.files.ObjectKey(storagePath)), nil
+}
+
+// DeleteFile removes a stored file from the underlying store
+func (s *FileStore) DeleteFile(ctx context.Context, storagePath string) error {
+	return s.files.DeleteFile(ctx, storagePath)
+}
+
+// CopyFile copies a stored file to a new key, replacing its metadata. The copy
+// is encrypted with a data key of its own.
+func (s *FileStore) CopyFile(ctx context.Context, storagePath, destinationPath string, metadata ports.FileMetadata) (string, error) {
+	content, _, err := s.open(ctx, storagePath)
+	if err != nil {
+		return "", err
+	}
+	return s.UploadFile(ctx, bytes.NewReader(content), destinationPath, metadata)
+}
+
+// OpenFile reads and decrypts a stored file. The caller closes it.
+func (s *FileStore) OpenFile(ctx context.Context, storagePath string) (io.ReadCloser, error) {
+	content, _, err := s.open(ctx, storagePath)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+// CreateUploadURL returns the upload URL of the underlying store. Files uploaded
+// through it are stored in plain until they are copied to their final key when
+// the upload is completed, which encrypts them. Callers delete the plain file
+// when the upload is rejected or abandoned.
+func (s *FileStore) CreateUploadURL(ctx context.Context, destinationPath string, metadata ports.FileMetadata, size int64) (*ports.UploadURL, error) {
+	return s.files.CreateUploadURL(ctx, destinationPath, metadata, size)
+}
+
+// ObjectKey returns the key a stored file is kept under in the underlying store
+func (s *FileStore) ObjectKey(storagePath string) string {
+	return s.files.ObjectKey(storagePath)
+}
+
+// OpenSignedFile checks a download URL made by GetFileURL and decrypts the file it points to
+func (s *FileStore) OpenSignedFile(ctx context.Context, signature ports.FileSignature) (*ports.DownloadedFile, error) {
+	if err := s.signer.Verify("GET", signature); err != nil {
+		return nil, err
+	}
+	content, metadata, err := s.open(ctx, signature.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &ports.DownloadedFile{Content: nopSeekCloser{bytes.NewReader(content)}, FileMetadata: *metadata}, nil
+}
+
+// StoreSignedFile stores a file uploaded through an upload URL of the underlying
+// store, when its upload URLs point at the API too
+func (s *FileStore) StoreSignedFile(ctx context.Context, signature ports.FileSignature, file io.Reader) error {
+	server, ok := s.files.(ports.SignedFileServer)
+	if !ok {
+		return domain.ErrFileLinkInvalid
+	}
+	return server.StoreSignedFile(ctx, signature, file)
+}
+
+// RotateFileKey rewraps the data key of a stored file with the current master
+// key. Only the envelope changes, the content is not encrypted again. Files
+// stored in plain are encrypted.
+func (s *FileStore) RotateFileKey(ctx context.Context, storagePath string, dryRun bool) (ports.FileKeyRotation, error) {
+	raw, err := s.read(ctx, storagePath)
+	if err != nil {
+		return "", err
+	}
+	sealed, encrypted, err := parseEnvelope(raw)
+	if err != nil {
+		return "", err
+	}
+
+	rotation := ports.FileKeyRotated
+	switch {
+	case !encrypted:
+		rotation = ports.FileKeyEncrypted
+	case sealed.keyID == s.keys.CurrentKeyID():
+		return ports.FileKeyCurrent, nil
+	}
+	if dryRun {
+		return rotation, nil
+	}
+
+	var content []byte
+	if encrypted {
+		dataKey, err := s.keys.UnwrapKey(ctx, sealed.keyID, sealed.wrappedKey)
+		if err != nil {
+			return "", err
+		}
+		if sealed.wrappedKey, sealed.keyID, err = s.keys.WrapKey(ctx, dataKey); err != nil {
+			return "", fmt.Errorf("failed to wrap data key: %w", err)
+		}
+		content = sealed.marshal()
+	} else {
+		metadata := ports.FileMetadata{ContentType: http.DetectContentType(raw), Filename: path.Base(s.files.ObjectKey(storagePath))}
+		if content, err = s.seal(ctx, raw, metadata); err != nil {
+			return "", fmt.Errorf("failed to encrypt file %s: %w", storagePath, err)
+		}
+	}
+
+	_, err = s.files.UploadFile(ctx, bytes.NewReader(content), s.files.ObjectKey(storagePath), ports.FileMetadata{ContentType: storedContentType})
+	if err != nil {
+		return "", err
+	}
+	return rotation, nil
+}
+
+// seal encrypts content with a new data key and returns the envelope to store
+func (s *FileStore) seal(ctx context.Context, content []byte, metadata ports.FileMetadata) ([]byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	encodedMetadata, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, keyID, err := s.keys.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	sealed := &envelope{
+		keyID:      keyID,
+		wrappedKey: wrappedKey,
+		metadata:   encodedMetadata,
+		nonce:      nonce,
+		ciphertext: aead.Seal(nil, nonce, content, encodedMetadata),
+	}
+	return sealed.marshal(), nil
+}
+
+// open reads and decrypts a stored file. Files stored in plain are returned as
+// they are, with their content type sniffed.
+func (s *FileStore) open(ctx context.Context, storagePath string) ([]byte, *ports.FileMetadata, error) {
+	raw, err := s.read(ctx, storagePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	sealed, encrypted, err := parseEnvelope(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt file %s: %w", storagePath, err)
+	}
+	if !encrypted {
+		return raw, &ports.FileMetadata{ContentType: http.DetectContentType(raw), Filename: path.Base(s.files.ObjectKey(storagePath))}, nil
+	}
+
+	dataKey, err := s.keys.UnwrapKey(ctx, sealed.keyID, sealed.wrappedKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt file %s: %w", storagePath, err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt file %s: %w", storagePath, err)
+	}
+	if len(sealed.nonce) != aead.NonceSize() {
+		return nil, nil, fmt.Errorf("failed to decrypt file %s: %w: invalid nonce", storagePath, domain.ErrFileDecryption)
+	}
+	content, err := aead.Open(nil, sealed.nonce, sealed.ciphertext, sealed.metadata)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt file %s: %w", storagePath, domain.ErrFileDecryption)
+	}
+
+	var metadata ports.FileMetadata
+	if err := json.Unmarshal(sealed.metadata, &metadata); err != nil {
+		return nil, nil, fmt.Errorf("failed to read metadata of file %s: %w", storagePath, err)
+	}
+	return content, &metadata, nil
+}
+
+// read reads a stored file as the underlying store keeps it
+func (s *FileStore) read(ctx context.Context, storagePath string) ([]byte, error) {
+	file, err := s.files.OpenFile(ctx, storagePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", storagePath, err)
+	}
+	return content, nil
+}
+
+func newAEAD(dataKey []byte) (cipher.AEAD, error) {
+	if len(dataKey) != dataKeySize {
+		return nil, fmt.Errorf("%w: data key must be %d bytes", domain.ErrFileDecryption, dataKeySize)
+	}
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// nopSeekCloser serves decrypted content, which is held in memory
+type nopSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopSeekCloser) Close() error { return nil }
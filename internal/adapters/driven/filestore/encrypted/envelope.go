@@ -0,0 +1,77 @@
+package encrypted
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+)
+
+// envelopeMagic starts every encrypted file. Bill files stored before encryption
+// was enabled are images and documents, none of which start with it.
+var envelopeMagic = []byte("SBENC\x01")
+
+// envelope is an encrypted file as stored: the content sealed with a data key of
+// its own, next to the data key wrapped with a master key. The metadata is kept
+// in plain and authenticated with the content.
+type envelope struct {
+	keyID      string // master key the data key is wrapped with
+	wrappedKey []byte
+	metadata   []byte // JSON of the file metadata
+	nonce      []byte
+	ciphertext []byte
+}
+
+// marshal lays the envelope out as the magic followed by the length-prefixed
+// key ID, wrapped key and metadata, the nonce and the ciphertext
+func (e *envelope) marshal() []byte {
+	var buf bytes.Buffer
+	buf.Write(envelopeMagic)
+	writeField(&buf, []byte(e.keyID))
+	writeField(&buf, e.wrappedKey)
+	writeField(&buf, e.metadata)
+	writeField(&buf, e.nonce)
+	buf.Write(e.ciphertext)
+	return buf.Bytes()
+}
+
+// parseEnvelope reads an encrypted file. It returns false for files stored in plain.
+func parseEnvelope(content []byte) (*envelope, bool, error) {
+	if !bytes.HasPrefix(content, envelopeMagic) {
+		return nil, false, nil
+	}
+
+	rest := content[len(envelopeMagic):]
+	fields := make([][]byte, 4)
+	for i := range fields {
+		var err error
+		if fields[i], rest, err = readField(rest); err != nil {
+			return nil, true, err
+		}
+	}
+	return &envelope{
+		keyID:      string(fields[0]),
+		wrappedKey: fields[1],
+		metadata:   fields[2],
+		nonce:      fields[3],
+		ciphertext: rest,
+	}, true, nil
+}
+
+func writeField(buf *bytes.Buffer, field []byte) {
+	buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(field))))
+	buf.Write(field)
+}
+
+func readField(content []byte) ([]byte, []byte, error) {
+	if len(content) < 4 {
+		return nil, nil, fmt.Errorf("%w: envelope is truncated", domain.ErrFileDecryption)
+	}
+	size := binary.BigEndian.Uint32(content)
+	content = content[4:]
+	if uint64(size) > uint64(len(content)) {
+		return nil, nil, fmt.Errorf("%w: envelope is truncated", domain.ErrFileDecryption)
+	}
+	return content[:size], content[size:], nil
+}
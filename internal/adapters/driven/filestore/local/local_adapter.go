@@ -0,0 +1,292 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/filestore/signedurl"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+)
+
+// storagePrefix starts the storage paths of local files, like s3:// does for S3 objects
+const storagePrefix = "local://"
+
+// metadataSuffix names the file next to each stored file that holds its metadata
+const metadataSuffix = ".meta.json"
+
+// Options configures the local file store
+type Options struct {
+	Root     string        // directory the files are stored in
+	Secret   string        // key download and upload URLs are signed with
+	FilesURL string        // public URL of the API routes serving the files
+	URLTTL   time.Duration // how long download and upload URLs stay valid
+}
+
+// FileStore keeps bill files on the local filesystem. Its download and upload
+// URLs point at the API, which serves the files after checking the URL signature,
+// so it works without any storage service.
+type FileStore struct {
+	root   string
+	signer *signedurl.Signer
+}
+
+// NewLocalFileStore creates a file store in options.Root, creating the directory if needed
+func NewLocalFileStore(options Options) (*FileStore, error) {
+	if options.Root == "" {
+		return nil, fmt.Errorf("storage directory must be specified for the local file store")
+	}
+	if options.Secret == "" {
+		return nil, fmt.Errorf("a secret to sign download URLs must be specified for the local file store")
+	}
+	if err := os.MkdirAll(options.Root, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory %s: %w", options.Root, err)
+	}
+
+	return &FileStore{
+		root:   options.Root,
+		signer: signedurl.NewSigner(options.Secret, options.FilesURL, options.URLTTL),
+	}, nil
+}
+
+// UploadFile writes a file under the storage directory and returns its storage path
+func (s *FileStore) UploadFile(ctx context.Context, file io.Reader, destinationPath string, metadata ports.FileMetadata) (string, error) {
+	filePath, err := s.filePath(destinationPath)
+	if err != nil {
+		return "", err
+	}
+	if err := writeFile(filePath, file); err != nil {
+		return "", fmt.Errorf("failed to store file %s: %w", destinationPath, err)
+	}
+	if err := writeMetadata(filePath, metadata); err != nil {
+		os.Remove(filePath)
+		return "", fmt.Errorf("failed to store metadata of file %s: %w", destinationPath, err)
+	}
+	return storagePrefix + destinationPath, nil
+}
+
+// GetFileURL returns a signed download URL for a stored file, served by the API
+func (s *FileStore) GetFileURL(ctx context.Context, storagePath string) (string, error) {
+	key := s.ObjectKey(storagePath)
+	if _, err := s.filePath(key); err != nil {
+		return "", err
+	}
+	return s.signer.DownloadURL(key), nil
+}
+
+// CreateUploadURL returns a signed URL the API accepts an upload of exactly size
+// bytes on. The metadata is stored right away, the file once it is uploaded.
+func (s *FileStore) CreateUploadURL(ctx context.Context, destinationPath string, metadata ports.FileMetadata, size int64) (*ports.UploadURL, error) {
+	filePath, err := s.filePath(destinationPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeMetadata(filePath, metadata); err != nil {
+		return nil, fmt.Errorf("failed to store metadata of file %s: %w", destinationPath, err)
+	}
+
+	uploadURL, expiresAt := s.signer.UploadURL(destinationPath, size)
+	return &ports.UploadURL{
+		URL:         uploadURL,
+		Method:      "PUT",
+		Headers:     map[string]string{"Content-Type": metadata.ContentType},
+		StoragePath: storagePrefix + destinationPath,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// DeleteFile removes a stored file. Files that don't exist are not an error.
+func (s *FileStore) DeleteFile(ctx context.Context, storagePath string) error {
+	filePath, err := s.filePath(s.ObjectKey(storagePath))
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{filePath, filePath + metadataSuffix} {
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete file %s: %w", storagePath, err)
+		}
+	}
+	return nil
+}
+
+// CopyFile copies a stored file to a new key, replacing its metadata
+func (s *FileStore) CopyFile(ctx context.Context, storagePath, destinationPath string, metadata ports.FileMetadata) (string, error) {
+	file, err := s.OpenFile(ctx, storagePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return s.UploadFile(ctx, file, destinationPath, metadata)
+}
+
+// OpenFile opens a stored file. The caller closes it.
+func (s *FileStore) OpenFile(ctx context.Context, storagePath string) (io.ReadCloser, error) {
+	filePath, err := s.filePath(s.ObjectKey(storagePath))
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", domain.ErrStoredFileNotFound, storagePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", storagePath, err)
+	}
+	return file, nil
+}
+
+// OpenSignedFile checks a download URL made by GetFileURL and opens the file it points to
+func (s *FileStore) OpenSignedFile(ctx context.Context, signature ports.FileSignature) (*ports.DownloadedFile, error) {
+	filePath, err := s.verify("GET", signature)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, domain.ErrStoredFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", signature.Key, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read file %s: %w", signature.Key, err)
+	}
+
+	metadata, err := readMetadata(filePath)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read metadata of file %s: %w", signature.Key, err)
+	}
+	return &ports.DownloadedFile{Content: file, ModTime: info.ModTime(), FileMetadata: *metadata}, nil
+}
+
+// StoreSignedFile checks an upload URL made by CreateUploadURL and stores the
+// uploaded file, which must have the size the URL was made for
+func (s *FileStore) StoreSignedFile(ctx context.Context, signature ports.FileSignature, file io.Reader) error {
+	filePath, err := s.verify("PUT", signature)
+	if err != nil {
+		return err
+	}
+	size, err := strconv.ParseInt(signature.Size, 10, 64)
+	if err != nil {
+		return domain.ErrFileLinkInvalid
+	}
+
+	if err := writeFile(filePath, &exactReader{file: file, remaining: size}); err != nil {
+		if errors.Is(err, domain.ErrInvalidInput) {
+			return err
+		}
+		return fmt.Errorf("failed to store file %s: %w", signature.Key, err)
+	}
+	return nil
+}
+
+// verify checks the signature and expiry of a URL for method and returns where
+// the file it points to is on disk
+func (s *FileStore) verify(method string, signature ports.FileSignature) (string, error) {
+	if err := s.signer.Verify(method, signature); err != nil {
+		return "", err
+	}
+	filePath, err := s.filePath(signature.Key)
+	if err != nil {
+		return "", domain.ErrFileLinkInvalid
+	}
+	return filePath, nil
+}
+
+// filePath returns where the file stored under key is on disk. Keys leaving the
+// storage directory are rejected.
+func (s *FileStore) filePath(key string) (string, error) {
+	name := filepath.FromSlash(key)
+	if !filepath.IsLocal(name) || strings.HasSuffix(key, metadataSuffix) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.root, name), nil
+}
+
+// ObjectKey returns the key of a stored file from its storage path
+func (s *FileStore) ObjectKey(storagePath string) string {
+	return strings.TrimPrefix(storagePath, storagePrefix)
+}
+
+// writeFile writes a file through a temporary file renamed into place, so
+// readers never see a partly written file
+func writeFile(filePath string, content io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o700); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := io.Copy(temp, content); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), filePath)
+}
+
+func writeMetadata(filePath string, metadata ports.FileMetadata) error {
+	content, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	return writeFile(filePath+metadataSuffix, bytes.NewReader(content))
+}
+
+// readMetadata reads the metadata stored next to a file. Files stored without
+// any are served as binary data.
+func readMetadata(filePath string) (*ports.FileMetadata, error) {
+	metadata := &ports.FileMetadata{ContentType: "application/octet-stream", Filename: filepath.Base(filePath)}
+	content, err := os.ReadFile(filePath + metadataSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return metadata, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// exactReader fails when the file it reads is not exactly the expected size
+type exactReader struct {
+	file      io.Reader
+	remaining int64
+}
+
+func (r *exactReader) Read(p []byte) (int, error) {
+	// Read one byte more than expected to tell a file of exactly the size from a larger one
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.file.Read(p)
+	r.remaining -= int64(n)
+	switch {
+	case r.remaining < 0:
+		return 0, fmt.Errorf("%w: upload is larger than the size it was signed for", domain.ErrInvalidInput)
+	case err == io.EOF && r.remaining > 0:
+		return n, fmt.Errorf("%w: upload is smaller than the size it was signed for", domain.ErrInvalidInput)
+	}
+	return n, err
+}
@@ -5,16 +5,20 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 
 	appconfig "github.com/dgsaltarin/SharedBitesBackend/config"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
 	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
 )
 
@@ -24,9 +28,12 @@ type s3FileStore struct {
 	presignClient *s3.PresignClient
 	bucketName    string
 	awsRegion     string
+	endpoint      string // URL of an S3-compatible store, empty for AWS
+	usePathStyle  bool
 }
 
-// NewS3FileStore creates a new S3 file store.
+// NewS3FileStore creates a new S3 file store. When an endpoint is configured it
+// talks to that S3-compatible store instead of AWS, with its own credentials if set.
 func NewS3FileStore(ctx context.Context, appCfg appconfig.AWSConfig) (ports.FileStore, error) {
 	var cfg aws.Config
 	var err error
@@ -38,13 +45,32 @@ func NewS3FileStore(ctx context.Context, appCfg appconfig.AWSConfig) (ports.File
 		return nil, fmt.Errorf("S3 bucket name must be specified in AWS config")
 	}
 
-	cfg, err = awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(appCfg.Region))
+	options := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(appCfg.Region)}
+	accessKeyID, secretAccessKey := appCfg.AccessKeyID, appCfg.SecretAccessKey
+	if appCfg.S3AccessKeyID != "" {
+		accessKeyID, secretAccessKey = appCfg.S3AccessKeyID, appCfg.S3SecretAccessKey
+	}
+	if accessKeyID != "" && secretAccessKey != "" {
+		options = append(options, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, "")))
+	}
+
+	cfg, err = awsconfig.LoadDefaultConfig(ctx, options...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config for S3: %w", err)
 	}
 
-	s3Client := s3.NewFromConfig(cfg)
+	s3Client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if appCfg.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(appCfg.S3Endpoint)
+			// Not every S3-compatible store supports the checksums AWS S3 now requires by default
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+			o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+		}
+		o.UsePathStyle = appCfg.S3UsePathStyle
+	})
 	uploader := manager.NewUploader(s3Client)
+	// The presign client shares the client options, so presigned URLs point at the same endpoint
 	presignClient := s3.NewPresignClient(s3Client)
 
 	return &s3FileStore{
@@ -53,11 +79,13 @@ func NewS3FileStore(ctx context.Context, appCfg appconfig.AWSConfig) (ports.File
 		presignClient: presignClient,
 		bucketName:    appCfg.S3Bucket,
 		awsRegion:     appCfg.Region,
+		endpoint:      strings.TrimRight(appCfg.S3Endpoint, "/"),
+		usePathStyle:  appCfg.S3UsePathStyle,
 	}, nil
 }
 
 // UploadFile uploads a file to S3 and returns its storage path (key).
-func (s *s3FileStore) UploadFile(ctx context.Context, file io.Reader, destinationPath, contentType string) (string, error) {
+func (s *s3FileStore) UploadFile(ctx context.Context, file io.Reader, destinationPath string, metadata ports.FileMetadata) (string, error) {
 	if s.client == nil {
 		return "", fmt.Errorf("S3 client not initialized")
 	}
@@ -69,7 +97,11 @@ func (s *s3FileStore) UploadFile(ctx context.Context, file io.Reader, destinatio
 		Bucket:      aws.String(s.bucketName),
 		Key:         aws.String(destinationPath),
 		Body:        file,
-		ContentType: aws.String(contentType),
+		ContentType: aws.String(metadata.ContentType),
+	}
+	if metadata.Filename != "" {
+		uploadInput.ContentDisposition = aws.String(contentDisposition(metadata.Filename))
+		uploadInput.Metadata = objectMetadata(metadata.Filename)
 	}
 
 	// Using the manager.Uploader for potentially large files and multipart uploads
@@ -96,13 +128,14 @@ func (s *s3FileStore) GetFileURL(ctx context.Context, storagePath string) (strin
 		po.Expires = 15 * time.Minute // URL expires in 15 minutes
 	}
 
+	key := s.ObjectKey(storagePath)
 	presignedURL, err := s.presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucketName),
-		Key:    aws.String(storagePath),
+		Key:    aws.String(key),
 	}, presignDuration)
 
 	if err != nil {
-		return "", fmt.Errorf("failed to generate pre-signed URL for S3 object (bucket: %s, key: %s): %w", s.bucketName, storagePath, err)
+		return "", fmt.Errorf("failed to generate pre-signed URL for S3 object (bucket: %s, key: %s): %w", s.bucketName, key, err)
 	}
 
 	return presignedURL.URL, nil
@@ -117,9 +150,10 @@ func (s *s3FileStore) DeleteFile(ctx context.Context, storagePath string) error
 		return fmt.Errorf("S3 bucket name not configured")
 	}
 
+	key := s.ObjectKey(storagePath)
 	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.bucketName),
-		Key:    aws.String(storagePath),
+		Key:    aws.String(key),
 	})
 
 	if err != nil {
@@ -130,12 +164,148 @@ func (s *s3FileStore) DeleteFile(ctx context.Context, storagePath string) error
 			// For now, let's return nil to indicate the desired state (file doesn't exist) is achieved.
 			return nil
 		}
-		return fmt.Errorf("failed to delete file from S3 (bucket: %s, key: %s): %w", s.bucketName, storagePath, err)
+		return fmt.Errorf("failed to delete file from S3 (bucket: %s, key: %s): %w", s.bucketName, key, err)
 	}
 
 	return nil
 }
 
+// CopyFile copies an S3 object to a new key in the bucket, replacing its metadata.
+func (s *s3FileStore) CopyFile(ctx context.Context, storagePath, destinationPath string, metadata ports.FileMetadata) (string, error) {
+	if s.client == nil {
+		return "", fmt.Errorf("S3 client not initialized")
+	}
+	if s.bucketName == "" {
+		return "", fmt.Errorf("S3 bucket name not configured")
+	}
+
+	key := s.ObjectKey(storagePath)
+	copyInput := &s3.CopyObjectInput{
+		Bucket:            aws.String(s.bucketName),
+		Key:               aws.String(destinationPath),
+		CopySource:        aws.String(url.PathEscape(s.bucketName) + "/" + escapeKey(key)),
+		MetadataDirective: types.MetadataDirectiveReplace,
+		ContentType:       aws.String(metadata.ContentType),
+	}
+	if metadata.Filename != "" {
+		copyInput.ContentDisposition = aws.String(contentDisposition(metadata.Filename))
+		copyInput.Metadata = objectMetadata(metadata.Filename)
+	}
+
+	if _, err := s.client.CopyObject(ctx, copyInput); err != nil {
+		return "", fmt.Errorf("failed to copy file in S3 (bucket: %s, key: %s to %s): %w", s.bucketName, key, destinationPath, err)
+	}
+
+	return fmt.Sprintf("s3://%s/%s", s.bucketName, destinationPath), nil
+}
+
+// OpenFile downloads an S3 object. The caller closes it.
+func (s *s3FileStore) OpenFile(ctx context.Context, storagePath string) (io.ReadCloser, error) {
+	if s.client == nil {
+		return nil, fmt.Errorf("S3 client not initialized")
+	}
+	if s.bucketName == "" {
+		return nil, fmt.Errorf("S3 bucket name not configured")
+	}
+
+	key := s.ObjectKey(storagePath)
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, fmt.Errorf("%w: %s", domain.ErrStoredFileNotFound, storagePath)
+		}
+		return nil, fmt.Errorf("failed to download file from S3 (bucket: %s, key: %s): %w", s.bucketName, key, err)
+	}
+	return output.Body, nil
+}
+
+// CreateUploadURL generates a pre-signed URL for uploading an S3 object directly.
+// The content type, size and metadata are signed, so the client must send them
+// as returned in the headers. The URL expires like download URLs.
+func (s *s3FileStore) CreateUploadURL(ctx context.Context, destinationPath string, metadata ports.FileMetadata, size int64) (*ports.UploadURL, error) {
+	if s.client == nil {
+		return nil, fmt.Errorf("S3 client not initialized")
+	}
+	if s.bucketName == "" {
+		return nil, fmt.Errorf("S3 bucket name not configured")
+	}
+
+	putInput := &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucketName),
+		Key:           aws.String(destinationPath),
+		ContentType:   aws.String(metadata.ContentType),
+		ContentLength: aws.Int64(size),
+	}
+	if metadata.Filename != "" {
+		putInput.ContentDisposition = aws.String(contentDisposition(metadata.Filename))
+		putInput.Metadata = objectMetadata(metadata.Filename)
+	}
+
+	expiresAt := time.Now().Add(15 * time.Minute)
+	presigned, err := s.presignClient.PresignPutObject(ctx, putInput, func(po *s3.PresignOptions) {
+		po.Expires = 15 * time.Minute // URL expires in 15 minutes
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate pre-signed upload URL for S3 object (bucket: %s, key: %s): %w", s.bucketName, destinationPath, err)
+	}
+
+	headers := make(map[string]string)
+	for name, values := range presigned.SignedHeader {
+		// The HTTP client sets the host itself
+		if !strings.EqualFold(name, "Host") {
+			headers[name] = strings.Join(values, ",")
+		}
+	}
+
+	return &ports.UploadURL{
+		URL:         presigned.URL,
+		Method:      presigned.Method,
+		Headers:     headers,
+		StoragePath: fmt.Sprintf("s3://%s/%s", s.bucketName, destinationPath),
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// ObjectKey returns the key of a stored file. Storage paths are the s3:// URIs
+// returned by UploadFile, but plain keys are accepted too.
+func (s *s3FileStore) ObjectKey(storagePath string) string {
+	return strings.TrimPrefix(storagePath, "s3://"+s.bucketName+"/")
+}
+
+// escapeKey URL-encodes each segment of an object key, keeping the slashes
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// contentDisposition makes downloads of an object keep its original filename
+func contentDisposition(filename string) string {
+	return mime.FormatMediaType("inline", map[string]string{"filename": filename})
+}
+
+// objectMetadata records the original filename on an object. S3 metadata is sent
+// as HTTP headers, so the name is URL-encoded to keep it ASCII.
+func objectMetadata(filename string) map[string]string {
+	return map[string]string{"original-filename": url.QueryEscape(filename)}
+}
+
 func (s *s3FileStore) constructS3URL(key string) string {
+	switch {
+	case s.endpoint != "":
+		if s.usePathStyle {
+			return fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucketName, key)
+		}
+		scheme, host, _ := strings.Cut(s.endpoint, "://")
+		return fmt.Sprintf("%s://%s.%s/%s", scheme, s.bucketName, host, key)
+	case s.usePathStyle:
+		return fmt.Sprintf("https://s3.%s.amazonaws.com/%s/%s", s.awsRegion, s.bucketName, key)
+	}
 	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucketName, s.awsRegion, key)
 }
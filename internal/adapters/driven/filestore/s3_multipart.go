@@ -0,0 +1,227 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+)
+
+// multipartPartSize is the size of the parts resumable uploads are sent to S3
+// in. S3 requires every part but the last to be at least 5 MiB.
+const multipartPartSize = 5 * 1024 * 1024
+
+// pendingPartSuffix names the object holding the bytes of a resumable upload
+// received since its last part, until there are enough for another part
+const pendingPartSuffix = ".part"
+
+// StartUpload starts an S3 multipart upload to destinationPath. The handle is its upload ID.
+func (s *s3FileStore) StartUpload(ctx context.Context, destinationPath string, metadata ports.FileMetadata) (string, error) {
+	createInput := &s3.CreateMultipartUploadInput{
+		Bucket:            aws.String(s.bucketName),
+		Key:               aws.String(destinationPath),
+		ContentType:       aws.String(metadata.ContentType),
+		ChecksumAlgorithm: types.ChecksumAlgorithmCrc32,
+	}
+	if metadata.Filename != "" {
+		createInput.ContentDisposition = aws.String(contentDisposition(metadata.Filename))
+		createInput.Metadata = objectMetadata(metadata.Filename)
+	}
+
+	output, err := s.client.CreateMultipartUpload(ctx, createInput)
+	if err != nil {
+		return "", fmt.Errorf("failed to start multipart upload to S3 (bucket: %s, key: %s): %w", s.bucketName, destinationPath, err)
+	}
+	return aws.ToString(output.UploadId), nil
+}
+
+// WriteChunk sends a chunk to S3 as parts of the multipart upload. Bytes left
+// over after the last full part are kept in a pending object and sent with the
+// next chunk, as chunks can be of any size.
+func (s *s3FileStore) WriteChunk(ctx context.Context, destinationPath, handle string, offset int64, chunk io.Reader) (int64, error) {
+	parts, err := s.listParts(ctx, destinationPath, handle)
+	if err != nil {
+		return 0, err
+	}
+	var staged int64
+	for _, part := range parts {
+		staged += aws.ToInt64(part.Size)
+	}
+
+	var pending []byte
+	if staged != offset {
+		if pending, err = s.readPendingPart(ctx, destinationPath); err != nil {
+			return 0, err
+		}
+		// A pending object left over from a part already sent is ignored when the parts end at offset
+		if staged+int64(len(pending)) != offset {
+			return 0, fmt.Errorf("%w: staged %d bytes, chunk starts at %d", domain.ErrUploadOffsetMismatch, staged+int64(len(pending)), offset)
+		}
+	}
+
+	buffer := make([]byte, multipartPartSize)
+	filled := copy(buffer, pending)
+	carried := filled // bytes of the buffer received with earlier chunks
+	partNumber := int32(len(parts) + 1)
+	var kept int64
+	for {
+		n, readErr := io.ReadFull(chunk, buffer[filled:])
+		filled += n
+		if readErr != nil {
+			// The chunk ended, or failed part way: keep what was received for the next chunk
+			if err := s.storePendingPart(ctx, destinationPath, buffer[:filled]); err != nil {
+				return kept, err
+			}
+			kept += int64(filled - carried)
+			if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+				return kept, nil
+			}
+			return kept, fmt.Errorf("failed to read chunk of %s: %w", destinationPath, readErr)
+		}
+
+		if err := s.uploadPart(ctx, destinationPath, handle, partNumber, buffer); err != nil {
+			if storeErr := s.storePendingPart(ctx, destinationPath, buffer); storeErr != nil {
+				return kept, err
+			}
+			return kept + int64(filled-carried), err
+		}
+		kept += int64(filled - carried)
+		filled, carried = 0, 0
+		partNumber++
+	}
+}
+
+// FinishUpload sends the pending bytes as the last part and completes the multipart upload
+func (s *s3FileStore) FinishUpload(ctx context.Context, destinationPath, handle string, metadata ports.FileMetadata) (string, error) {
+	parts, err := s.listParts(ctx, destinationPath, handle)
+	if err != nil {
+		return "", err
+	}
+	pending, err := s.readPendingPart(ctx, destinationPath)
+	if err != nil {
+		return "", err
+	}
+	if len(pending) > 0 {
+		if err := s.uploadPart(ctx, destinationPath, handle, int32(len(parts)+1), pending); err != nil {
+			return "", err
+		}
+		if parts, err = s.listParts(ctx, destinationPath, handle); err != nil {
+			return "", err
+		}
+	}
+
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, types.CompletedPart{
+			PartNumber:    part.PartNumber,
+			ETag:          part.ETag,
+			ChecksumCRC32: part.ChecksumCRC32,
+		})
+	}
+	_, err = s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucketName),
+		Key:             aws.String(destinationPath),
+		UploadId:        aws.String(handle),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to complete multipart upload to S3 (bucket: %s, key: %s): %w", s.bucketName, destinationPath, err)
+	}
+	s.DeleteFile(ctx, destinationPath+pendingPartSuffix)
+
+	return fmt.Sprintf("s3://%s/%s", s.bucketName, destinationPath), nil
+}
+
+// AbortUpload aborts the multipart upload, deleting its parts and pending bytes.
+// Uploads already aborted are not an error.
+func (s *s3FileStore) AbortUpload(ctx context.Context, destinationPath, handle string) error {
+	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucketName),
+		Key:      aws.String(destinationPath),
+		UploadId: aws.String(handle),
+	})
+	var noSuchUpload *types.NoSuchUpload
+	if err != nil && !errors.As(err, &noSuchUpload) {
+		return fmt.Errorf("failed to abort multipart upload to S3 (bucket: %s, key: %s): %w", s.bucketName, destinationPath, err)
+	}
+	return s.DeleteFile(ctx, destinationPath+pendingPartSuffix)
+}
+
+// listParts returns the parts of a multipart upload sent so far, in order
+func (s *s3FileStore) listParts(ctx context.Context, key, uploadID string) ([]types.Part, error) {
+	var parts []types.Part
+	paginator := s3.NewListPartsPaginator(s.client, &s3.ListPartsInput{
+		Bucket:   aws.String(s.bucketName),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			var noSuchUpload *types.NoSuchUpload
+			if errors.As(err, &noSuchUpload) {
+				return nil, domain.ErrResumableUploadNotFound
+			}
+			return nil, fmt.Errorf("failed to list parts of multipart upload to S3 (bucket: %s, key: %s): %w", s.bucketName, key, err)
+		}
+		parts = append(parts, page.Parts...)
+	}
+	return parts, nil
+}
+
+func (s *s3FileStore) uploadPart(ctx context.Context, key, uploadID string, partNumber int32, content []byte) error {
+	_, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:            aws.String(s.bucketName),
+		Key:               aws.String(key),
+		UploadId:          aws.String(uploadID),
+		PartNumber:        aws.Int32(partNumber),
+		Body:              bytes.NewReader(content),
+		ChecksumAlgorithm: types.ChecksumAlgorithmCrc32,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload part %d to S3 (bucket: %s, key: %s): %w", partNumber, s.bucketName, key, err)
+	}
+	return nil
+}
+
+// readPendingPart returns the bytes of an upload not sent as a part yet
+func (s *s3FileStore) readPendingPart(ctx context.Context, key string) ([]byte, error) {
+	file, err := s.OpenFile(ctx, key+pendingPartSuffix)
+	if errors.Is(err, domain.ErrStoredFileNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending part of %s: %w", key, err)
+	}
+	return content, nil
+}
+
+// storePendingPart replaces the bytes of an upload not sent as a part yet
+func (s *s3FileStore) storePendingPart(ctx context.Context, key string, content []byte) error {
+	if len(content) == 0 {
+		return s.DeleteFile(ctx, key+pendingPartSuffix)
+	}
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key + pendingPartSuffix),
+		Body:   bytes.NewReader(content),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store pending part of %s in S3 (bucket: %s): %w", key, s.bucketName, err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package staging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+)
+
+// DiskStager keeps the chunks of resumable uploads in a file on local disk and
+// hands the complete file to a file store. Every chunk of an upload must reach
+// the same API instance.
+type DiskStager struct {
+	dir   string
+	files ports.FileStore
+}
+
+// NewDiskStager creates a stager keeping uploads in dir, creating the directory if needed
+func NewDiskStager(dir string, files ports.FileStore) (*DiskStager, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("staging directory must be specified for the disk upload stager")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create staging directory %s: %w", dir, err)
+	}
+	return &DiskStager{dir: dir, files: files}, nil
+}
+
+// StartUpload creates the empty file the chunks of an upload are written to.
+// The handle is its name in the staging directory.
+func (s *DiskStager) StartUpload(ctx context.Context, destinationPath string, metadata ports.FileMetadata) (string, error) {
+	handle := uuid.NewString()
+	file, err := os.OpenFile(filepath.Join(s.dir, handle), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging file for %s: %w", destinationPath, err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to create staging file for %s: %w", destinationPath, err)
+	}
+	return handle, nil
+}
+
+// WriteChunk appends a chunk to the staging file, keeping what was received
+// before the chunk failed
+func (s *DiskStager) WriteChunk(ctx context.Context, destinationPath, handle string, offset int64, chunk io.Reader) (int64, error) {
+	filePath, err := s.stagingPath(handle)
+	if err != nil {
+		return 0, err
+	}
+	file, err := os.OpenFile(filePath, os.O_WRONLY, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, domain.ErrResumableUploadNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open staging file for %s: %w", destinationPath, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read staging file for %s: %w", destinationPath, err)
+	}
+	if info.Size() != offset {
+		return 0, fmt.Errorf("%w: staged %d bytes, chunk starts at %d", domain.ErrUploadOffsetMismatch, info.Size(), offset)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to write staging file for %s: %w", destinationPath, err)
+	}
+
+	written, err := io.Copy(file, chunk)
+	if err != nil {
+		return written, fmt.Errorf("failed to write chunk of %s: %w", destinationPath, err)
+	}
+	return written, nil
+}
+
+// FinishUpload stores the staging file in the file store and removes it
+func (s *DiskStager) FinishUpload(ctx context.Context, destinationPath, handle string, metadata ports.FileMetadata) (string, error) {
+	filePath, err := s.stagingPath(handle)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", domain.ErrResumableUploadNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to open staging file for %s: %w", destinationPath, err)
+	}
+	defer file.Close()
+
+	storagePath, err := s.files.UploadFile(ctx, file, destinationPath, metadata)
+	if err != nil {
+		return "", err
+	}
+	os.Remove(filePath)
+	return storagePath, nil
+}
+
+// AbortUpload removes the staging file. Uploads already removed are not an error.
+func (s *DiskStager) AbortUpload(ctx context.Context, destinationPath, handle string) error {
+	filePath, err := s.stagingPath(handle)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove staging file for %s: %w", destinationPath, err)
+	}
+	return nil
+}
+
+// stagingPath returns where the chunks of the upload with handle are kept
+func (s *DiskStager) stagingPath(handle string) (string, error) {
+	if _, err := uuid.Parse(handle); err != nil {
+		return "", fmt.Errorf("invalid staging handle %q", handle)
+	}
+	return filepath.Join(s.dir, handle), nil
+}
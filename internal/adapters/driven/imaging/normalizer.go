@@ -0,0 +1,183 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png" // registers the PNG decoder
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+)
+
+// Options controls how uploaded images are normalized
+type Options struct {
+	MaxDimension int    // longest side in pixels after downscaling, 0 to keep the size
+	JPEGQuality  int    // quality of the re-encoded JPEG (1 to 100)
+	Deskew       bool   // straighten receipts photographed at an angle
+	Crop         bool   // cut away the background around the receipt
+	Converter    string // ImageMagick command used for HEIC and WebP, empty to store them as uploaded
+	MaxPixels    int    // largest width times height decoded, 0 for no limit
+}
+
+type imageNormalizer struct {
+	options Options
+}
+
+// NewImageNormalizer creates an ImageProcessor that turns photos into upright
+// JPEGs no larger than needed. Go can't decode HEIC and WebP, so those are
+// converted with the ImageMagick command set in options.Converter first.
+func NewImageNormalizer(options Options) ports.ImageProcessor {
+	if options.JPEGQuality <= 0 || options.JPEGQuality > 100 {
+		options.JPEGQuality = jpeg.DefaultQuality
+	}
+	return &imageNormalizer{options: options}
+}
+
+func (n *imageNormalizer) Normalize(ctx context.Context, content []byte, filename, contentType string) (*ports.NormalizedImage, error) {
+	unchanged := &ports.NormalizedImage{
+		Content:     content,
+		ContentType: contentType,
+		Extension:   filepath.Ext(filename),
+	}
+
+	format := imageFormat(content, filename, contentType)
+	switch format {
+	case "jpeg", "png":
+	case "heic", "webp":
+		if n.options.Converter == "" {
+			return unchanged, nil
+		}
+		converted, err := n.convert(ctx, content, format)
+		if err != nil {
+			return nil, err
+		}
+		content = converted
+	default:
+		// PDFs and TIFFs can have several pages and are analyzed as they are
+		return unchanged, nil
+	}
+
+	orientation := 1
+	if format != "png" {
+		orientation = jpegOrientation(content)
+	}
+
+	decoded, err := decodeImage(content, format, n.options.MaxPixels)
+	if err != nil {
+		return nil, err
+	}
+
+	img := orient(toRGBA(decoded), orientation)
+	if n.options.Crop {
+		img = cropToReceipt(img)
+	}
+	if n.options.Deskew {
+		img = deskew(img)
+	}
+	hash := differenceHash(img)
+
+	// Upright JPEGs that are small enough are stored as uploaded to avoid a lossy re-encode
+	bounds := decoded.Bounds()
+	if format == "jpeg" && orientation == 1 && !n.options.Crop && !n.options.Deskew &&
+		(n.options.MaxDimension <= 0 || max(bounds.Dx(), bounds.Dy()) <= n.options.MaxDimension) {
+		unchanged.PerceptualHash = &hash
+		return unchanged, nil
+	}
+
+	img = downscale(img, n.options.MaxDimension)
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: n.options.JPEGQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode normalized image: %w", err)
+	}
+
+	return &ports.NormalizedImage{
+		Content:     out.Bytes(),
+		ContentType: "image/jpeg",
+		Extension:   ".jpg",
+		Changed:     true,
+
+		PerceptualHash: &hash,
+	}, nil
+}
+
+// decodeImage decodes a JPEG or PNG image, reading its size from the header first
+// so that images with more than maxPixels pixels are rejected before their pixels
+// are allocated
+func decodeImage(content []byte, format string, maxPixels int) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode %s image: %v", domain.ErrCorruptFile, format, err)
+	}
+	if maxPixels > 0 && int64(config.Width)*int64(config.Height) > int64(maxPixels) {
+		return nil, fmt.Errorf("%w: %s image is %dx%d pixels, more than %d", domain.ErrFileTooLarge, format, config.Width, config.Height, maxPixels)
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode %s image: %v", domain.ErrCorruptFile, format, err)
+	}
+	return decoded, nil
+}
+
+// convert turns a HEIC or WebP image into an upright JPEG with ImageMagick
+func (n *imageNormalizer) convert(ctx context.Context, content []byte, format string) ([]byte, error) {
+	converted, err := runConverter(ctx, n.options.Converter, content, format+":-", "-auto-orient", "jpeg:-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s image: %w", format, err)
+	}
+	return converted, nil
+}
+
+// runConverter runs an ImageMagick command reading content from stdin and
+// returns what it writes to stdout
+func runConverter(ctx context.Context, converter string, content []byte, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, converter, args...)
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// imageFormat names the format of an uploaded file from its content, falling
+// back to the declared content type and extension for formats Go can't sniff
+func imageFormat(content []byte, filename, contentType string) string {
+	switch http.DetectContentType(content) {
+	case "image/jpeg":
+		return "jpeg"
+	case "image/png":
+		return "png"
+	case "image/webp":
+		return "webp"
+	case "application/pdf":
+		return "pdf"
+	}
+
+	// HEIC files are ISO media files with a heic, heix or mif1 brand
+	if len(content) >= 12 && string(content[4:8]) == "ftyp" {
+		switch string(content[8:12]) {
+		case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1":
+			return "heic"
+		}
+	}
+
+	contentType = strings.ToLower(contentType)
+	switch ext := strings.ToLower(filepath.Ext(filename)); {
+	case contentType == "image/heic" || contentType == "image/heif" || ext == ".heic" || ext == ".heif":
+		return "heic"
+	case contentType == "image/webp" || ext == ".webp":
+		return "webp"
+	}
+	return ""
+}
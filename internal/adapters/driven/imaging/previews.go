@@ -0,0 +1,109 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"strings"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+)
+
+// PreviewOptions controls the renditions of bill files
+type PreviewOptions struct {
+	ThumbnailDimension int    // longest side of thumbnails in pixels
+	PreviewDimension   int    // longest side of previews in pixels
+	JPEGQuality        int    // quality of the rendered JPEGs (1 to 100)
+	Converter          string // ImageMagick command used for HEIC, WebP, TIFF and PDF files, empty to only render JPEG and PNG
+	MaxPixels          int    // largest width times height decoded, 0 for no limit
+}
+
+type previewRenderer struct {
+	options PreviewOptions
+}
+
+// NewPreviewRenderer creates a PreviewRenderer that shrinks upright copies of
+// bill images. HEIC, WebP, TIFF and PDF files are rendered with the ImageMagick
+// command set in options.Converter first, taking their first page; PDFs also
+// need Ghostscript.
+func NewPreviewRenderer(options PreviewOptions) ports.PreviewRenderer {
+	if options.JPEGQuality <= 0 || options.JPEGQuality > 100 {
+		options.JPEGQuality = jpeg.DefaultQuality
+	}
+	return &previewRenderer{options: options}
+}
+
+func (r *previewRenderer) RenderPreviews(ctx context.Context, content []byte, contentType string) (*ports.Previews, error) {
+	format := imageFormat(content, "", contentType)
+	if format == "" && (strings.EqualFold(contentType, "image/tiff") || isTIFF(content)) {
+		format = "tiff"
+	}
+
+	switch format {
+	case "jpeg", "png":
+	case "heic", "webp", "tiff", "pdf":
+		if r.options.Converter == "" {
+			return nil, nil
+		}
+		rendered, err := r.renderFirstPage(ctx, content, format)
+		if err != nil {
+			return nil, err
+		}
+		content = rendered
+	default:
+		return nil, nil
+	}
+
+	// The converter already turns its output upright
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(content)
+	}
+
+	decoded, err := decodeImage(content, format, r.options.MaxPixels)
+	if err != nil {
+		return nil, err
+	}
+
+	// The thumbnail is shrunk from the preview, which is quicker than from the full image
+	preview := downscale(orient(toRGBA(decoded), orientation), r.options.PreviewDimension)
+	thumbnail := downscale(preview, r.options.ThumbnailDimension)
+
+	var previews ports.Previews
+	if previews.Preview, err = r.encode(preview); err != nil {
+		return nil, err
+	}
+	if previews.Thumbnail, err = r.encode(thumbnail); err != nil {
+		return nil, err
+	}
+	return &previews, nil
+}
+
+// renderFirstPage renders the first page or frame of a file into an upright JPEG with ImageMagick
+func (r *previewRenderer) renderFirstPage(ctx context.Context, content []byte, format string) ([]byte, error) {
+	args := []string{format + ":-[0]", "-auto-orient", "jpeg:-"}
+	if format == "pdf" {
+		// PDFs have no pixel size, render them sharp enough for the preview on a white page
+		args = []string{"-density", "150", "pdf:-[0]", "-background", "white", "-flatten", "jpeg:-"}
+	}
+	rendered, err := runConverter(ctx, r.options.Converter, content, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s file: %w", format, err)
+	}
+	return rendered, nil
+}
+
+func (r *previewRenderer) encode(img image.Image) ([]byte, error) {
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: r.options.JPEGQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode preview: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// isTIFF reports whether content starts like a TIFF file, in either byte order
+func isTIFF(content []byte) bool {
+	return bytes.HasPrefix(content, []byte("II*\x00")) || bytes.HasPrefix(content, []byte("MM\x00*"))
+}
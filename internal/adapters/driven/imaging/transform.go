@@ -0,0 +1,326 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+// toRGBA copies an image into an RGBA image with its origin at 0,0, flattening
+// transparency onto white like paper
+func toRGBA(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Over)
+	return dst
+}
+
+// orient turns an image the way its EXIF orientation says it should be shown
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 { // the transposing orientations swap width and height
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // upside down
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored upside down
+				dx, dy = x, h-1-y
+			case 5: // mirrored and turned left
+				dx, dy = y, x
+			case 6: // turned left, shown turned right
+				dx, dy = h-1-y, x
+			case 7: // mirrored and turned right
+				dx, dy = h-1-y, w-1-x
+			case 8: // turned right, shown turned left
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+	return dst
+}
+
+// downscale shrinks an image so its longest side is at most maxDimension
+// pixels, averaging the source pixels behind each new one
+func downscale(src *image.RGBA, maxDimension int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if maxDimension <= 0 || (w <= maxDimension && h <= maxDimension) {
+		return src
+	}
+
+	scale := float64(maxDimension) / float64(max(w, h))
+	dw := max(1, int(math.Round(float64(w)*scale)))
+	dh := max(1, int(math.Round(float64(h)*scale)))
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := dy*h/dh, max((dy+1)*h/dh, dy*h/dh+1)
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := dx*w/dw, max((dx+1)*w/dw, dx*w/dw+1)
+
+			var r, g, b, a, n int
+			for y := y0; y < y1; y++ {
+				i := src.PixOffset(x0, y)
+				for x := x0; x < x1; x++ {
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					b += int(src.Pix[i+2])
+					a += int(src.Pix[i+3])
+					n++
+					i += 4
+				}
+			}
+
+			i := dst.PixOffset(dx, dy)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// grayscale returns the luminance of every pixel of a copy of the image
+// shrunk to at most maxDimension pixels, which is enough to find the receipt
+func grayscale(src *image.RGBA, maxDimension int) (*image.Gray, float64) {
+	small := downscale(src, maxDimension)
+	gray := image.NewGray(small.Bounds())
+	draw.Draw(gray, gray.Bounds(), small, image.Point{}, draw.Src)
+	return gray, float64(src.Bounds().Dx()) / float64(small.Bounds().Dx())
+}
+
+// differenceHash computes the 64-bit dHash of an image: it is shrunk to 9x8
+// gray pixels and each bit tells whether a pixel is brighter than the one to its
+// right. Photos of the same receipt differ in only a few bits.
+func differenceHash(src *image.RGBA) int64 {
+	small := image.NewRGBA(image.Rect(0, 0, 9, 8))
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 9; x++ {
+			cell := src.SubImage(image.Rect(x*w/9, y*h/8, max((x+1)*w/9, x*w/9+1), max((y+1)*h/8, y*h/8+1))).(*image.RGBA)
+			small.SetRGBA(x, y, averageColor(cell))
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if luminance(small.RGBAAt(x, y)) > luminance(small.RGBAAt(x+1, y)) {
+				hash |= 1
+			}
+		}
+	}
+	return int64(hash)
+}
+
+// averageColor returns the mean color of an image
+func averageColor(src *image.RGBA) color.RGBA {
+	var r, g, b, n int
+	bounds := src.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := src.PixOffset(x, y)
+			r += int(src.Pix[i])
+			g += int(src.Pix[i+1])
+			b += int(src.Pix[i+2])
+			n++
+		}
+	}
+	if n == 0 {
+		return color.RGBA{A: 255}
+	}
+	return color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: 255}
+}
+
+func luminance(c color.RGBA) int {
+	return (299*int(c.R) + 587*int(c.G) + 114*int(c.B)) / 1000
+}
+
+// analysisDimension is the size of the grayscale copy used to find the receipt
+const analysisDimension = 800
+
+// cropToReceipt cuts away the background around a receipt photographed on a
+// table. The background is the median border color; the receipt is the bounding
+// box of the rows and columns where enough pixels differ from it. The image is
+// left alone when no clear receipt is found.
+func cropToReceipt(src *image.RGBA) *image.RGBA {
+	gray, scale := grayscale(src, analysisDimension)
+	w, h := gray.Bounds().Dx(), gray.Bounds().Dy()
+	if w < 16 || h < 16 {
+		return src
+	}
+
+	var border []int
+	for x := 0; x < w; x++ {
+		border = append(border, int(gray.GrayAt(x, 0).Y), int(gray.GrayAt(x, h-1).Y))
+	}
+	for y := 0; y < h; y++ {
+		border = append(border, int(gray.GrayAt(0, y).Y), int(gray.GrayAt(w-1, y).Y))
+	}
+	sort.Ints(border)
+	background := border[len(border)/2]
+
+	const contrast = 48
+	rows := make([]int, h)
+	cols := make([]int, w)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if diff := int(gray.GrayAt(x, y).Y) - background; diff > contrast || diff < -contrast {
+				rows[y]++
+				cols[x]++
+			}
+		}
+	}
+
+	// Ignore rows and columns with only a few stray pixels
+	top, bottom := span(rows, w/10)
+	left, right := span(cols, h/10)
+	if top < 0 || left < 0 {
+		return src
+	}
+
+	// Keep a small margin so nothing printed at the edge is lost
+	margin := max(w, h) / 100
+	rect := image.Rect(
+		int(float64(max(left-margin, 0))*scale),
+		int(float64(max(top-margin, 0))*scale),
+		int(float64(min(right+margin+1, w))*scale),
+		int(float64(min(bottom+margin+1, h))*scale),
+	).Intersect(src.Bounds())
+
+	// Crops that keep almost everything or almost nothing are guesses
+	area := float64(rect.Dx()*rect.Dy()) / float64(src.Bounds().Dx()*src.Bounds().Dy())
+	if area > 0.95 || area < 0.1 {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, rect.Min, draw.Src)
+	return dst
+}
+
+// span returns the first and last index whose count is above threshold, or -1, -1
+func span(counts []int, threshold int) (int, int) {
+	first, last := -1, -1
+	for i, count := range counts {
+		if count > threshold {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	return first, last
+}
+
+// deskew straightens a receipt photographed at a small angle. The angle is the
+// one, within ±maxSkewDegrees, at which the rows of dark text pixels line up
+// best: their horizontal projection is then the most uneven.
+func deskew(src *image.RGBA) *image.RGBA {
+	const (
+		maxSkewDegrees = 10.0
+		stepDegrees    = 0.25
+		darkThreshold  = 110
+	)
+
+	gray, _ := grayscale(src, analysisDimension)
+	w, h := gray.Bounds().Dx(), gray.Bounds().Dy()
+
+	type point struct{ x, y float64 }
+	var dark []point
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if gray.GrayAt(x, y).Y < darkThreshold {
+				dark = append(dark, point{float64(x) - float64(w)/2, float64(y) - float64(h)/2})
+			}
+		}
+	}
+	if len(dark) < 100 {
+		return src
+	}
+
+	diagonal := int(math.Hypot(float64(w), float64(h))) + 2
+	bins := make([]int, diagonal)
+	score := func(degrees float64) float64 {
+		sin, cos := math.Sincos(degrees * math.Pi / 180)
+		clear(bins)
+		for _, p := range dark {
+			bins[int(p.y*cos-p.x*sin)+diagonal/2]++
+		}
+		var total float64
+		for _, count := range bins {
+			total += float64(count) * float64(count)
+		}
+		return total
+	}
+
+	best, bestScore := 0.0, score(0)
+	for degrees := -maxSkewDegrees; degrees <= maxSkewDegrees; degrees += stepDegrees {
+		if s := score(degrees); s > bestScore {
+			best, bestScore = degrees, s
+		}
+	}
+	if math.Abs(best) < 0.5 {
+		return src
+	}
+	return rotate(src, best)
+}
+
+// rotate turns an image by degrees, counterclockwise for positive angles so a
+// line tilted down by that angle becomes level, filling the new corners with white
+func rotate(src *image.RGBA, degrees float64) *image.RGBA {
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	w, h := float64(src.Bounds().Dx()), float64(src.Bounds().Dy())
+	dw := int(math.Ceil(math.Abs(w*cos) + math.Abs(h*sin)))
+	dh := int(math.Ceil(math.Abs(w*sin) + math.Abs(h*cos)))
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for dy := 0; dy < dh; dy++ {
+		v := float64(dy) - float64(dh)/2
+		for dx := 0; dx < dw; dx++ {
+			u := float64(dx) - float64(dw)/2
+			x := u*cos - v*sin + w/2
+			y := u*sin + v*cos + h/2
+			dst.SetRGBA(dx, dy, bilinear(src, x, y))
+		}
+	}
+	return dst
+}
+
+// bilinear samples an image between pixels, returning white outside it
+func bilinear(src *image.RGBA, x, y float64) color.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if x < 0 || y < 0 || x > float64(w-1) || y > float64(h-1) {
+		return color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	}
+
+	x0, y0 := int(x), int(y)
+	x1, y1 := min(x0+1, w-1), min(y0+1, h-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	var out [4]uint8
+	for c := 0; c < 4; c++ {
+		top := float64(src.Pix[src.PixOffset(x0, y0)+c])*(1-fx) + float64(src.Pix[src.PixOffset(x1, y0)+c])*fx
+		bottom := float64(src.Pix[src.PixOffset(x0, y1)+c])*(1-fx) + float64(src.Pix[src.PixOffset(x1, y1)+c])*fx
+		out[c] = uint8(math.Round(top*(1-fy) + bottom*fy))
+	}
+	return color.RGBA{R: out[0], G: out[1], B: out[2], A: out[3]}
+}
@@ -0,0 +1,114 @@
+package keyprovider
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	appconfig "github.com/dgsaltarin/SharedBitesBackend/config"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+)
+
+// StaticKeyProvider wraps data keys with AES-256-GCM under master keys read from
+// the configuration. Keeping the retired keys next to the current one lets data
+// keys wrapped with them be unwrapped until every file is rotated.
+type StaticKeyProvider struct {
+	keys         map[string]cipher.AEAD
+	currentKeyID string
+}
+
+// NewStaticKeyProvider creates a key provider from master keys of 32 bytes by ID,
+// wrapping new data keys with the key named currentKeyID
+func NewStaticKeyProvider(keys map[string][]byte, currentKeyID string) (*StaticKeyProvider, error) {
+	provider := &StaticKeyProvider{keys: make(map[string]cipher.AEAD, len(keys)), currentKeyID: currentKeyID}
+	for id, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("master key %s must be 32 bytes, got %d", id, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key %s: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key %s: %w", id, err)
+		}
+		provider.keys[id] = aead
+	}
+	if _, ok := provider.keys[currentKeyID]; !ok {
+		return nil, fmt.Errorf("%w: current master key %q is not among the configured keys", domain.ErrMasterKeyNotFound, currentKeyID)
+	}
+	return provider, nil
+}
+
+// ParseMasterKeys parses master keys listed as comma-separated id:key pairs,
+// with the keys base64-encoded
+func ParseMasterKeys(value string) (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, encoded, found := strings.Cut(pair, ":")
+		if !found || id == "" {
+			return nil, fmt.Errorf("invalid master key %q, expected id:base64-key", pair)
+		}
+		if _, exists := keys[id]; exists {
+			return nil, fmt.Errorf("master key %s is listed twice", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("master key %s is not valid base64: %w", id, err)
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+// CurrentKeyID names the master key new data keys are wrapped with
+func (p *StaticKeyProvider) CurrentKeyID() string {
+	return p.currentKeyID
+}
+
+// WrapKey encrypts a data key with the current master key. The nonce is prepended
+// to the wrapped key and the key ID authenticated with it.
+func (p *StaticKeyProvider) WrapKey(ctx context.Context, dataKey []byte) ([]byte, string, error) {
+	aead := p.keys[p.currentKeyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(p.currentKeyID)), p.currentKeyID, nil
+}
+
+// UnwrapKey decrypts a data key wrapped with the master key named keyID
+func (p *StaticKeyProvider) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrMasterKeyNotFound, keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: wrapped data key is too short", domain.ErrFileDecryption)
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, sealed, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("%w: data key doesn't match master key %s", domain.ErrFileDecryption, keyID)
+	}
+	return dataKey, nil
+}
+
+// NewStaticKeyProviderFromConfig creates a key provider from the master keys of
+// the storage configuration
+func NewStaticKeyProviderFromConfig(cfg appconfig.StorageConfig) (*StaticKeyProvider, error) {
+	keys, err := ParseMasterKeys(cfg.EncryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+	return NewStaticKeyProvider(keys, cfg.EncryptionKeyID)
+}
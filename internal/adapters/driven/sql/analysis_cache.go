@@ -0,0 +1,80 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type postgresAnalysisCache struct {
+	db         *gorm.DB
+	ttl        time.Duration
+	maxEntries int
+}
+
+// NewPostgresAnalysisCache creates an analysis cache stored in Postgres, shared
+// by every API instance and kept across restarts. Entries expire after ttl, and
+// the oldest are evicted when there are more than maxEntries; 0 means no limit.
+func NewPostgresAnalysisCache(db *gorm.DB, ttl time.Duration, maxEntries int) ports.AnalysisCache {
+	if db == nil {
+		log.Fatal("GORM DB cannot be nil for AnalysisCache")
+	}
+	return &postgresAnalysisCache{db: db, ttl: ttl, maxEntries: maxEntries}
+}
+
+// Get returns the cached response for a document, unless it expired
+func (c *postgresAnalysisCache) Get(ctx context.Context, contentHash string) ([]byte, bool, error) {
+	var entry domain.AnalysisCacheEntry
+	err := c.db.WithContext(ctx).
+		Where("content_hash = ? AND expires_at > ?", contentHash, time.Now().UTC()).
+		First(&entry).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("error reading analysis cache: %w", err)
+	}
+
+	response, err := entry.RawResponse()
+	if err != nil {
+		return nil, false, err
+	}
+	return response, true, nil
+}
+
+// Put caches the response for a document, replacing any previous one, and evicts
+// expired entries and the oldest ones over the limit
+func (c *postgresAnalysisCache) Put(ctx context.Context, contentHash string, response []byte) error {
+	entry, err := domain.NewAnalysisCacheEntry(contentHash, response, c.ttl)
+	if err != nil {
+		return err
+	}
+	err = c.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "content_hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"response", "response_size", "created_at", "expires_at"}),
+	}).Create(entry).Error
+	if err != nil {
+		return fmt.Errorf("error writing analysis cache: %w", err)
+	}
+
+	if err := c.db.WithContext(ctx).Where("expires_at <= ?", time.Now().UTC()).Delete(&domain.AnalysisCacheEntry{}).Error; err != nil {
+		log.Printf("Error evicting expired analysis cache entries: %v", err)
+	}
+	if c.maxEntries > 0 {
+		err := c.db.WithContext(ctx).
+			Where("content_hash IN (?)", c.db.Model(&domain.AnalysisCacheEntry{}).
+				Select("content_hash").Order("created_at DESC").Offset(c.maxEntries)).
+			Delete(&domain.AnalysisCacheEntry{}).Error
+		if err != nil {
+			log.Printf("Error evicting old analysis cache entries: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// billEventsChannel is the Postgres NOTIFY channel bill events are sent on
+const billEventsChannel = "bill_events"
+
+type postgresBillEventBus struct {
+	db    *gorm.DB
+	local ports.BillEventBus
+}
+
+// NewPostgresBillEventBus creates a bus that sends events through Postgres
+// NOTIFY, so every API instance behind a load balancer receives them. Each
+// instance LISTENs on its own connection until ctx is done and hands the
+// events to local, which delivers them to the subscribers of the instance.
+func NewPostgresBillEventBus(ctx context.Context, db *gorm.DB, dsn string, local ports.BillEventBus) ports.BillEventBus {
+	if db == nil {
+		log.Fatal("GORM DB cannot be nil for BillEventBus")
+	}
+	bus := &postgresBillEventBus{db: db, local: local}
+	go listenNotifications(ctx, dsn, billEventsChannel, bus.deliver)
+	return bus
+}
+
+// Publish notifies every instance, including this one, of the event.
+func (b *postgresBillEventBus) Publish(ctx context.Context, event *domain.BillEvent) error {
+	if err := notify(ctx, b.db, billEventsChannel, event); err != nil {
+		log.Printf("Error publishing bill event (BillID: %s): %v", event.BillID, err)
+		return err
+	}
+	return nil
+}
+
+// Subscribe registers a subscriber on this instance.
+func (b *postgresBillEventBus) Subscribe(userID uuid.UUID) (<-chan *domain.BillEvent, func()) {
+	return b.local.Subscribe(userID)
+}
+
+// Close ends the subscriptions on this instance.
+func (b *postgresBillEventBus) Close() error {
+	return b.local.Close()
+}
+
+func (b *postgresBillEventBus) deliver(ctx context.Context, payload string) {
+	var event domain.BillEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		log.Printf("Ignoring malformed bill event: %v", err)
+		return
+	}
+	b.local.Publish(ctx, &event)
+}
@@ -5,11 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
 	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type gormBillRepository struct {
@@ -36,7 +38,7 @@ func (r *gormBillRepository) CreateBill(ctx context.Context, bill *domain.Bill)
 // GetBillByID retrieves a bill by its UUID, preloading LineItems.
 func (r *gormBillRepository) GetBillByID(ctx context.Context, billID uuid.UUID) (*domain.Bill, error) {
 	var bill domain.Bill
-	err := r.db.WithContext(ctx).Preload("LineItems").Where("id = ?", billID).First(&bill).Error
+	err := r.db.WithContext(ctx).Preload("LineItems.Assignments").Where("id = ?", billID).First(&bill).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, domain.ErrBillNotFound
@@ -124,3 +126,139 @@ func (r *gormBillRepository) SaveBillWithLineItems(ctx context.Context, bill *do
 		return nil
 	})
 }
+
+// GetBillsByGroupID retrieves all bills attached to a group, preloading line items and their assignments.
+func (r *gormBillRepository) GetBillsByGroupID(ctx context.Context, groupID uuid.UUID) ([]*domain.Bill, error) {
+	var bills []*domain.Bill
+	err := r.db.WithContext(ctx).
+		Preload("LineItems.Assignments").
+		Where("group_id = ?", groupID).
+		Order("uploaded_at ASC").
+		Find(&bills).Error
+	if err != nil {
+		log.Printf("Error finding bills by GroupID %s: %v", groupID, err)
+		return nil, fmt.Errorf("database error finding bills by GroupID: %w", err)
+	}
+	return bills, nil
+}
+
+// SetBillGroup persists the group and payer of a bill without touching its line items.
+func (r *gormBillRepository) SetBillGroup(ctx context.Context, bill *domain.Bill) error {
+	err := r.db.WithContext(ctx).Model(bill).Updates(map[string]interface{}{
+		"group_id":          bill.GroupID,
+		"paid_by_member_id": bill.PaidByMemberID,
+	}).Error
+	if err != nil {
+		log.Printf("Error setting group for bill (ID: %s): %v", bill.ID, err)
+		return fmt.Errorf("database error setting bill group: %w", err)
+	}
+	return nil
+}
+
+// ReplaceLineItemAssignments replaces all member assignments of a line item in a transaction.
+func (r *gormBillRepository) ReplaceLineItemAssignments(ctx context.Context, lineItemID uuid.UUID, assignments []*domain.LineItemAssignment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("line_item_id = ?", lineItemID).Delete(&domain.LineItemAssignment{}).Error; err != nil {
+			log.Printf("Error clearing assignments for line item ID %s: %v", lineItemID, err)
+			return fmt.Errorf("transaction error clearing line item assignments: %w", err)
+		}
+
+		if len(assignments) > 0 {
+			if err := tx.Create(assignments).Error; err != nil {
+				log.Printf("Error creating assignments for line item ID %s: %v", lineItemID, err)
+				return fmt.Errorf("transaction error creating line item assignments: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// SaveBillCorrection persists a manual correction of a bill in a transaction:
+// the corrected bill fields, created, updated and deleted line items, and the
+// revisions recording the change. It fails with ErrBillVersionConflict when the
+// bill changed since the correction was made.
+func (r *gormBillRepository) SaveBillCorrection(ctx context.Context, correction *domain.BillCorrection) error {
+	bill := correction.Bill
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.Bill{}).
+			Where("id = ? AND version = ?", bill.ID, correction.BaseVersion).
+			Updates(map[string]interface{}{
+				"vendor_name":           bill.VendorName,
+				"transaction_date":      bill.TransactionDate,
+				"total_amount":          bill.TotalAmount,
+				"subtotal_amount":       bill.SubtotalAmount,
+				"tax_amount":            bill.TaxAmount,
+				"tip_amount":            bill.TipAmount,
+				"discount_amount":       bill.DiscountAmount,
+				"reconciliation_status": bill.ReconciliationStatus,
+				"discrepancies":         bill.Discrepancies,
+				"edited_fields":         bill.EditedFields,
+				"version":               bill.Version,
+				"updated_at":            time.Now().UTC(),
+			})
+		if result.Error != nil {
+			log.Printf("Error saving correction of bill (ID: %s): %v", bill.ID, result.Error)
+			return fmt.Errorf("transaction error updating bill: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return domain.ErrBillVersionConflict
+		}
+
+		for _, item := range correction.CreatedLineItems {
+			if err := tx.Omit(clause.Associations).Create(item).Error; err != nil {
+				log.Printf("Error creating line item for bill ID %s: %v", bill.ID, err)
+				return fmt.Errorf("transaction error creating line item: %w", err)
+			}
+		}
+
+		for _, item := range correction.UpdatedLineItems {
+			err := tx.Model(item).Updates(map[string]interface{}{
+				"description":   item.Description,
+				"quantity":      item.Quantity,
+				"unit_price":    item.UnitPrice,
+				"total_price":   item.TotalPrice,
+				"edited_fields": item.EditedFields,
+			}).Error
+			if err != nil {
+				log.Printf("Error updating line item ID %s: %v", item.ID, err)
+				return fmt.Errorf("transaction error updating line item: %w", err)
+			}
+		}
+
+		if len(correction.DeletedLineItemIDs) > 0 {
+			// Flag the items as deleted by the user, so analyzing the bill again doesn't add them back
+			err := tx.Model(&domain.LineItem{}).Where("bill_id = ? AND id IN ?", bill.ID, correction.DeletedLineItemIDs).Update("deleted_by_user", true).Error
+			if err != nil {
+				log.Printf("Error deleting line items of bill ID %s: %v", bill.ID, err)
+				return fmt.Errorf("transaction error deleting line items: %w", err)
+			}
+			err = tx.Where("bill_id = ? AND id IN ?", bill.ID, correction.DeletedLineItemIDs).Delete(&domain.LineItem{}).Error
+			if err != nil {
+				log.Printf("Error deleting line items of bill ID %s: %v", bill.ID, err)
+				return fmt.Errorf("transaction error deleting line items: %w", err)
+			}
+		}
+
+		if len(correction.Revisions) > 0 {
+			if err := tx.Create(correction.Revisions).Error; err != nil {
+				log.Printf("Error recording revisions of bill ID %s: %v", bill.ID, err)
+				return fmt.Errorf("transaction error recording bill revisions: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetBillRevisions retrieves the revision history of a bill, oldest first.
+func (r *gormBillRepository) GetBillRevisions(ctx context.Context, billID uuid.UUID) ([]*domain.BillRevision, error) {
+	var revisions []*domain.BillRevision
+	err := r.db.WithContext(ctx).
+		Where("bill_id = ?", billID).
+		Order("version ASC, created_at ASC").
+		Find(&revisions).Error
+	if err != nil {
+		log.Printf("Error finding revisions of bill ID %s: %v", billID, err)
+		return nil, fmt.Errorf("database error finding bill revisions: %w", err)
+	}
+	return revisions, nil
+}
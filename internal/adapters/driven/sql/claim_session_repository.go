@@ -0,0 +1,148 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormClaimSessionRepository struct {
+	db *gorm.DB
+}
+
+// NewGORMClaimSessionRepository creates a new GORM claim session repository.
+func NewGORMClaimSessionRepository(db *gorm.DB) ports.ClaimSessionRepository {
+	if db == nil {
+		log.Fatal("GORM DB cannot be nil for ClaimSessionRepository")
+	}
+	return &gormClaimSessionRepository{db: db}
+}
+
+// Create creates a new claim session. A bill can only have one session.
+func (r *gormClaimSessionRepository) Create(ctx context.Context, session *domain.ClaimSession) error {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&domain.ClaimSession{}).Where("bill_id = ?", session.BillID).Count(&count).Error; err != nil {
+		return fmt.Errorf("database error checking existing claim session: %w", err)
+	}
+	if count > 0 {
+		return domain.ErrClaimSessionExists
+	}
+
+	if err := r.db.WithContext(ctx).Create(session).Error; err != nil {
+		log.Printf("Error creating claim session (BillID: %s): %v", session.BillID, err)
+		return fmt.Errorf("database error creating claim session: %w", err)
+	}
+	return nil
+}
+
+// GetByBillID retrieves the claim session of a bill, preloading its claims.
+func (r *gormClaimSessionRepository) GetByBillID(ctx context.Context, billID uuid.UUID) (*domain.ClaimSession, error) {
+	var session domain.ClaimSession
+	err := r.db.WithContext(ctx).Preload("Claims").Where("bill_id = ?", billID).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrClaimSessionNotFound
+		}
+		log.Printf("Error finding claim session by BillID %s: %v", billID, err)
+		return nil, fmt.Errorf("database error finding claim session: %w", err)
+	}
+	return &session, nil
+}
+
+// ReplaceMemberClaims replaces all claims of a member in a transaction.
+// The session row is locked so claims cannot race with finalizing.
+func (r *gormClaimSessionRepository) ReplaceMemberClaims(ctx context.Context, sessionID, memberID uuid.UUID, claims []*domain.LineItemClaim) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		session, err := lockClaimSession(tx, sessionID)
+		if err != nil {
+			return err
+		}
+		if session.IsLocked() {
+			return domain.ErrClaimSessionLocked
+		}
+
+		if err := tx.Where("session_id = ? AND group_member_id = ?", sessionID, memberID).Delete(&domain.LineItemClaim{}).Error; err != nil {
+			log.Printf("Error clearing claims of member %s in session %s: %v", memberID, sessionID, err)
+			return fmt.Errorf("transaction error clearing member claims: %w", err)
+		}
+
+		if len(claims) > 0 {
+			if err := tx.Create(claims).Error; err != nil {
+				log.Printf("Error creating claims of member %s in session %s: %v", memberID, sessionID, err)
+				return fmt.Errorf("transaction error creating member claims: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Finalize locks the session, converts its claims into line item assignments
+// and marks it finalized, all in a single transaction.
+func (r *gormClaimSessionRepository) Finalize(ctx context.Context, sessionID uuid.UUID, buildAssignments func(session *domain.ClaimSession) (map[uuid.UUID][]*domain.LineItemAssignment, error)) (*domain.ClaimSession, error) {
+	var finalized *domain.ClaimSession
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		session, err := lockClaimSession(tx, sessionID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("session_id = ?", sessionID).Find(&session.Claims).Error; err != nil {
+			return fmt.Errorf("transaction error loading claims: %w", err)
+		}
+
+		assignments, err := buildAssignments(session)
+		if err != nil {
+			return err
+		}
+		if err := session.Finalize(); err != nil {
+			return err
+		}
+
+		for lineItemID, itemAssignments := range assignments {
+			if err := tx.Where("line_item_id = ?", lineItemID).Delete(&domain.LineItemAssignment{}).Error; err != nil {
+				log.Printf("Error clearing assignments for line item ID %s: %v", lineItemID, err)
+				return fmt.Errorf("transaction error clearing line item assignments: %w", err)
+			}
+			if len(itemAssignments) > 0 {
+				if err := tx.Create(itemAssignments).Error; err != nil {
+					log.Printf("Error creating assignments for line item ID %s: %v", lineItemID, err)
+					return fmt.Errorf("transaction error creating line item assignments: %w", err)
+				}
+			}
+		}
+
+		if err := tx.Model(session).Updates(map[string]interface{}{
+			"status":       session.Status,
+			"finalized_at": session.FinalizedAt,
+		}).Error; err != nil {
+			log.Printf("Error finalizing claim session %s: %v", sessionID, err)
+			return fmt.Errorf("transaction error finalizing claim session: %w", err)
+		}
+
+		finalized = session
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return finalized, nil
+}
+
+// lockClaimSession loads a claim session with a row lock for the rest of the transaction.
+func lockClaimSession(tx *gorm.DB, sessionID uuid.UUID) (*domain.ClaimSession, error) {
+	var session domain.ClaimSession
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", sessionID).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrClaimSessionNotFound
+		}
+		return nil, fmt.Errorf("transaction error locking claim session: %w", err)
+	}
+	return &session, nil
+}
@@ -0,0 +1,59 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// groupEventsChannel is the Postgres NOTIFY channel group events are sent on
+const groupEventsChannel = "group_events"
+
+type postgresGroupEventBus struct {
+	db    *gorm.DB
+	local ports.GroupEventBus
+}
+
+// NewPostgresGroupEventBus creates a group event bus that works like
+// NewPostgresBillEventBus, on its own NOTIFY channel.
+func NewPostgresGroupEventBus(ctx context.Context, db *gorm.DB, dsn string, local ports.GroupEventBus) ports.GroupEventBus {
+	if db == nil {
+		log.Fatal("GORM DB cannot be nil for GroupEventBus")
+	}
+	bus := &postgresGroupEventBus{db: db, local: local}
+	go listenNotifications(ctx, dsn, groupEventsChannel, bus.deliver)
+	return bus
+}
+
+// Publish notifies every instance, including this one, of the event.
+func (b *postgresGroupEventBus) Publish(ctx context.Context, event *domain.GroupEvent) error {
+	if err := notify(ctx, b.db, groupEventsChannel, event); err != nil {
+		log.Printf("Error publishing group event (GroupID: %s): %v", event.GroupID, err)
+		return err
+	}
+	return nil
+}
+
+// Subscribe registers a subscriber on this instance.
+func (b *postgresGroupEventBus) Subscribe(groupID uuid.UUID) (<-chan *domain.GroupEvent, func()) {
+	return b.local.Subscribe(groupID)
+}
+
+// Close ends the subscriptions on this instance.
+func (b *postgresGroupEventBus) Close() error {
+	return b.local.Close()
+}
+
+func (b *postgresGroupEventBus) deliver(ctx context.Context, payload string) {
+	var event domain.GroupEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		log.Printf("Ignoring malformed group event: %v", err)
+		return
+	}
+	b.local.Publish(ctx, &event)
+}
@@ -0,0 +1,48 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type gormGroupEventRepository struct {
+	db *gorm.DB
+}
+
+// NewGORMGroupEventRepository creates a repository for the group event log
+func NewGORMGroupEventRepository(db *gorm.DB) ports.GroupEventRepository {
+	if db == nil {
+		log.Fatal("GORM DB cannot be nil for GroupEventRepository")
+	}
+	return &gormGroupEventRepository{db: db}
+}
+
+// Append stores an event and assigns its ID.
+func (r *gormGroupEventRepository) Append(ctx context.Context, event *domain.GroupEvent) error {
+	if err := r.db.WithContext(ctx).Create(event).Error; err != nil {
+		log.Printf("Error saving group event (GroupID: %s): %v", event.GroupID, err)
+		return fmt.Errorf("error saving group event: %w", err)
+	}
+	return nil
+}
+
+// ListSince returns up to limit events of the group with an ID greater than afterID, oldest first.
+func (r *gormGroupEventRepository) ListSince(ctx context.Context, groupID uuid.UUID, afterID int64, limit int) ([]*domain.GroupEvent, error) {
+	var events []*domain.GroupEvent
+	err := r.db.WithContext(ctx).
+		Where("group_id = ? AND id > ?", groupID, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		log.Printf("Error listing group events (GroupID: %s): %v", groupID, err)
+		return nil, fmt.Errorf("error listing group events: %w", err)
+	}
+	return events, nil
+}
@@ -128,6 +128,18 @@ func (r *GroupRepository) Delete(ctx context.Context, groupID uuid.UUID) error {
 		return fmt.Errorf("error deleting group members: %w", err)
 	}
 
+	// Delete the event log
+	if err := tx.Where("group_id = ?", groupID).Delete(&domain.GroupEvent{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting group events: %w", err)
+	}
+
+	// Delete the recorded settlements
+	if err := tx.Where("group_id = ?", groupID).Delete(&domain.Settlement{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting group settlements: %w", err)
+	}
+
 	// Then delete the group
 	if err := tx.Delete(&domain.Group{}, "id = ?", groupID).Error; err != nil {
 		tx.Rollback()
@@ -141,3 +153,29 @@ func (r *GroupRepository) Delete(ctx context.Context, groupID uuid.UUID) error {
 
 	return nil
 }
+
+func (r *GroupRepository) UpdateMember(ctx context.Context, member *domain.GroupMember) error {
+	err := r.db.WithContext(ctx).Model(member).Updates(map[string]interface{}{
+		"user_id": member.UserID,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("error updating group member: %w", err)
+	}
+	return nil
+}
+
+func (r *GroupRepository) CreateSettlement(ctx context.Context, settlement *domain.Settlement) error {
+	if err := r.db.WithContext(ctx).Create(settlement).Error; err != nil {
+		return fmt.Errorf("error recording settlement: %w", err)
+	}
+	return nil
+}
+
+func (r *GroupRepository) ListSettlements(ctx context.Context, groupID uuid.UUID) ([]*domain.Settlement, error) {
+	var settlements []*domain.Settlement
+	err := r.db.WithContext(ctx).Where("group_id = ?", groupID).Order("created_at ASC").Find(&settlements).Error
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving group settlements: %w", err)
+	}
+	return settlements, nil
+}
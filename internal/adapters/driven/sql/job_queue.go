@@ -0,0 +1,191 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type postgresJobQueue struct {
+	db    *gorm.DB
+	lease time.Duration
+}
+
+// NewPostgresJobQueue creates a job queue stored in Postgres. Workers claim jobs
+// with SELECT ... FOR UPDATE SKIP LOCKED so each job is handed to one worker.
+// A running job whose lease expired, e.g. because its worker crashed, is handed out again.
+func NewPostgresJobQueue(db *gorm.DB, lease time.Duration) ports.JobQueue {
+	if db == nil {
+		log.Fatal("GORM DB cannot be nil for JobQueue")
+	}
+	return &postgresJobQueue{db: db, lease: lease}
+}
+
+// Enqueue adds a job to the queue.
+func (q *postgresJobQueue) Enqueue(ctx context.Context, job *domain.AnalysisJob) error {
+	if err := q.db.WithContext(ctx).Create(job).Error; err != nil {
+		log.Printf("Error enqueuing analysis job (BillID: %s): %v", job.BillID, err)
+		return fmt.Errorf("%w: %v", domain.ErrQueueFailed, err)
+	}
+	return nil
+}
+
+// Dequeue claims the oldest ready job and marks it running.
+func (q *postgresJobQueue) Dequeue(ctx context.Context) (*domain.AnalysisJob, error) {
+	var job domain.AnalysisJob
+	err := q.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Postgres keeps microseconds, the lease is matched against the stored time
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_at < ?)",
+				domain.AnalysisJobQueued, now, domain.AnalysisJobRunning, now.Add(-q.lease)).
+			Order("run_at ASC").
+			First(&job).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return domain.ErrNoJobs
+			}
+			return fmt.Errorf("%w: %v", domain.ErrQueueFailed, err)
+		}
+
+		job.Status = domain.AnalysisJobRunning
+		job.LockedAt = &now
+		job.Attempts++
+		err = tx.Model(&job).Updates(map[string]interface{}{
+			"status":    job.Status,
+			"locked_at": job.LockedAt,
+			"attempts":  job.Attempts,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("%w: %v", domain.ErrQueueFailed, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Complete marks a job as succeeded.
+func (q *postgresJobQueue) Complete(ctx context.Context, job *domain.AnalysisJob) error {
+	now := time.Now().UTC()
+	return q.finish(ctx, job, map[string]interface{}{
+		"status":      domain.AnalysisJobSucceeded,
+		"locked_at":   nil,
+		"finished_at": now,
+		"updated_at":  now,
+	})
+}
+
+// Retry puts a job back in the queue to run again at runAt and records the error.
+func (q *postgresJobQueue) Retry(ctx context.Context, job *domain.AnalysisJob, jobErr error, runAt time.Time) error {
+	return q.finish(ctx, job, map[string]interface{}{
+		"status":     domain.AnalysisJobQueued,
+		"locked_at":  nil,
+		"last_error": jobErr.Error(),
+		"run_at":     runAt.UTC(),
+		"updated_at": time.Now().UTC(),
+	})
+}
+
+// Fail marks a job as failed, which dead-letters it, and records the error.
+func (q *postgresJobQueue) Fail(ctx context.Context, job *domain.AnalysisJob, jobErr error) error {
+	now := time.Now().UTC()
+	return q.finish(ctx, job, map[string]interface{}{
+		"status":      domain.AnalysisJobFailed,
+		"locked_at":   nil,
+		"last_error":  jobErr.Error(),
+		"finished_at": now,
+		"updated_at":  now,
+	})
+}
+
+// SetTextractJob records the asynchronous Textract job started for a job, so that
+// its later attempts resume it.
+func (q *postgresJobQueue) SetTextractJob(ctx context.Context, job *domain.AnalysisJob, textractJobID string) error {
+	return q.finish(ctx, job, map[string]interface{}{
+		"textract_job_id": textractJobID,
+		"updated_at":      time.Now().UTC(),
+	})
+}
+
+// finish updates a running job while it is still under the lease it was dequeued
+// with, so that a worker whose lease expired can't touch the job of the next one
+func (q *postgresJobQueue) finish(ctx context.Context, job *domain.AnalysisJob, updates map[string]interface{}) error {
+	if job.LockedAt == nil {
+		return domain.ErrJobLeaseLost
+	}
+	result := q.db.WithContext(ctx).Model(&domain.AnalysisJob{}).
+		Where("id = ? AND status = ? AND locked_at = ?", job.ID, domain.AnalysisJobRunning, *job.LockedAt).
+		Updates(updates)
+	if result.Error != nil {
+		log.Printf("Error finishing analysis job %s: %v", job.ID, result.Error)
+		return fmt.Errorf("%w: %v", domain.ErrQueueFailed, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrJobLeaseLost
+	}
+	return nil
+}
+
+// ListFailed returns the dead-lettered jobs, most recent first.
+func (q *postgresJobQueue) ListFailed(ctx context.Context) ([]*domain.AnalysisJob, error) {
+	var jobs []*domain.AnalysisJob
+	err := q.db.WithContext(ctx).
+		Where("status = ?", domain.AnalysisJobFailed).
+		Order("finished_at DESC").
+		Find(&jobs).Error
+	if err != nil {
+		log.Printf("Error listing failed analysis jobs: %v", err)
+		return nil, fmt.Errorf("%w: %v", domain.ErrQueueFailed, err)
+	}
+	return jobs, nil
+}
+
+// Requeue puts a dead-lettered job back in the queue with a fresh attempt count.
+func (q *postgresJobQueue) Requeue(ctx context.Context, jobID uuid.UUID) (*domain.AnalysisJob, error) {
+	var job domain.AnalysisJob
+	err := q.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&job, "id = ?", jobID).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return domain.ErrJobNotFound
+			}
+			return fmt.Errorf("%w: %v", domain.ErrQueueFailed, err)
+		}
+		if job.Status != domain.AnalysisJobFailed {
+			return domain.ErrJobNotFailed
+		}
+
+		job.Status = domain.AnalysisJobQueued
+		job.Attempts = 0
+		job.RunAt = time.Now().UTC()
+		job.FinishedAt = nil
+		job.TextractJobID = nil
+		// A failed Textract job isn't resumed, the requeued job starts another
+		err = tx.Model(&job).Updates(map[string]interface{}{
+			"status":          job.Status,
+			"attempts":        job.Attempts,
+			"run_at":          job.RunAt,
+			"finished_at":     nil,
+			"textract_job_id": nil,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("%w: %v", domain.ErrQueueFailed, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
@@ -0,0 +1,62 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// notify sends payload encoded as JSON on a Postgres NOTIFY channel.
+func notify(ctx context.Context, db *gorm.DB, channel string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding notification: %w", err)
+	}
+	if err := db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", channel, string(data)).Error; err != nil {
+		return fmt.Errorf("error sending notification on %s: %w", channel, err)
+	}
+	return nil
+}
+
+// listenNotifications LISTENs on channel with its own connection and passes the
+// payloads to handle until ctx is done, reconnecting after errors.
+func listenNotifications(ctx context.Context, dsn, channel string, handle func(ctx context.Context, payload string)) {
+	for {
+		err := listenOnce(ctx, dsn, channel, handle)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Listener on %s stopped: %v. Reconnecting in 5s.", channel, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func listenOnce(ctx context.Context, dsn, channel string, handle func(ctx context.Context, payload string)) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return fmt.Errorf("error connecting: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return fmt.Errorf("error listening on %s: %w", channel, err)
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("error waiting for notification: %w", err)
+		}
+		handle(ctx, notification.Payload)
+	}
+}
@@ -0,0 +1,25 @@
+package texttrack
+
+import "context"
+
+// AsyncAnalysis lets an analysis resume the asynchronous Textract job an earlier
+// attempt started, instead of paying for another one. JobID is the job to resume,
+// empty when there is none, and Started records a new job so later attempts can
+// resume it.
+type AsyncAnalysis struct {
+	JobID   string
+	Started func(jobID string)
+}
+
+type asyncAnalysisKey struct{}
+
+// WithAsyncAnalysis returns a context whose analyses of multi-page documents
+// resume and record their Textract jobs through async
+func WithAsyncAnalysis(ctx context.Context, async AsyncAnalysis) context.Context {
+	return context.WithValue(ctx, asyncAnalysisKey{}, async)
+}
+
+func asyncAnalysisFrom(ctx context.Context) AsyncAnalysis {
+	async, _ := ctx.Value(asyncAnalysisKey{}).(AsyncAnalysis)
+	return async
+}
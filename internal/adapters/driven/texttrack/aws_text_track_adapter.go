@@ -2,27 +2,39 @@ package texttrack
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/textract"
 	"github.com/aws/aws-sdk-go-v2/service/textract/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
 	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
 )
 
 type AWSTextractAdapter struct {
 	textractClient *textract.Client
+	limiter        *concurrencyLimiter
+	pollInterval   time.Duration // how often asynchronous analyses are checked
+
+	// files reads the documents Textract can't read itself, because they aren't
+	// stored in AWS S3. Nil when Textract reads documents from S3.
+	files ports.FileStore
 }
 
 // TextDetectionConfig holds configuration for text detection
 type TextDetectionConfig struct {
 	Languages     []string // Supported languages (e.g., ["es", "en"])
-	MinConfidence float64  // Minimum confidence threshold (0.0 to 1.0)
+	MinConfidence float64  // Confidence below which values are marked for review (0.0 to 1.0), never below domain.ReviewConfidence
 	CurrencyCodes []string // Supported currency codes
 }
 
@@ -126,9 +138,40 @@ func EuropeanConfig() TextDetectionConfig {
 	}
 }
 
-func NewAWSTextractAdapter(cfg aws.Config) *AWSTextractAdapter {
+// ConfigByName returns the named configuration profile, as listed by the analysis configs endpoint
+func ConfigByName(name string) (TextDetectionConfig, bool) {
+	profiles := map[string]func() TextDetectionConfig{
+		"default":        DefaultConfig,
+		"spanish":        SpanishOptimizedConfig,
+		"english":        EnglishOptimizedConfig,
+		"french":         FrenchOptimizedConfig,
+		"german":         GermanOptimizedConfig,
+		"portuguese":     PortugueseOptimizedConfig,
+		"italian":        ItalianOptimizedConfig,
+		"latin_american": LatinAmericanConfig,
+		"european":       EuropeanConfig,
+		"high_accuracy":  HighAccuracyConfig,
+		"low_confidence": LowConfidenceConfig,
+	}
+	profile, ok := profiles[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return TextDetectionConfig{}, false
+	}
+	return profile(), true
+}
+
+// NewAWSTextractAdapter creates the adapter. At most maxConcurrency documents are
+// analyzed at once, fewer while AWS reports the provisioned throughput is exceeded.
+// Multi-page documents are analyzed asynchronously and checked every pollInterval.
+// When files is set, documents are read from it and sent to Textract instead, for
+// stores Textract can't read from: the local filesystem, an S3-compatible store or
+// a store encrypting its files.
+func NewAWSTextractAdapter(cfg aws.Config, maxConcurrency int, pollInterval time.Duration, files ports.FileStore) *AWSTextractAdapter {
 	return &AWSTextractAdapter{
 		textractClient: textract.NewFromConfig(cfg),
+		limiter:        newConcurrencyLimiter(maxConcurrency),
+		pollInterval:   pollInterval,
+		files:          files,
 	}
 }
 
@@ -137,33 +180,73 @@ func (a *AWSTextractAdapter) AnalyzeDocument(ctx context.Context, storagePath st
 }
 
 func (a *AWSTextractAdapter) AnalyzeDocumentWithConfig(ctx context.Context, storagePath string, config TextDetectionConfig) (*ports.ParsedTextractData, error) {
-	// storagePath is expected to be in the format "s3://bucket-name/key"
+	if a.files != nil {
+		documents, err := a.analyzeStoredExpense(ctx, storagePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze document with Textract: %w", classifyTextractError(err))
+		}
+		return parseExpenseDocuments(documents, config)
+	}
+
+	// S3 documents are in the format "s3://bucket-name/key"
 	bucket, key, err := parseS3Path(storagePath)
 	if err != nil {
 		return nil, fmt.Errorf("invalid S3 path: %w", err)
 	}
 
-	// Create the input with language hints for better Spanish detection
-	input := &textract.AnalyzeExpenseInput{
-		Document: &types.Document{
-			S3Object: &types.S3Object{
-				Bucket: aws.String(bucket),
-				Name:   aws.String(key),
-			},
-		},
+	// Only the asynchronous API reads documents with several pages. Files whose
+	// extension doesn't tell are retried with it when the synchronous API rejects them.
+	var documents []types.ExpenseDocument
+	if isMultiPageDocument(key) {
+		documents, err = a.startExpenseAnalysis(ctx, bucket, key)
+	} else {
+		documents, err = a.analyzeExpense(ctx, bucket, key)
+		if isUnsupportedDocument(err) {
+			documents, err = a.startExpenseAnalysis(ctx, bucket, key)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze document with Textract: %w", classifyTextractError(err))
 	}
 
-	// Note: LanguageHints is not available for AnalyzeExpense, only for DetectDocumentText
-	// We'll rely on the enhanced parsing logic instead
+	return parseExpenseDocuments(documents, config)
+}
 
-	output, err := a.textractClient.AnalyzeExpense(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("failed to analyze document with Textract: %w", err)
+// classifyTextractError wraps a Textract error with domain.ErrAnalysisTemporary when
+// the call may succeed later (throttling, server errors and timeouts), or with
+// domain.ErrUnsupportedDocument when the document itself cannot be analyzed.
+func classifyTextractError(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ThrottlingException", "ProvisionedThroughputExceededException",
+			"LimitExceededException", "InternalServerError":
+			return fmt.Errorf("%w: %w", domain.ErrAnalysisTemporary, err)
+		case "UnsupportedDocumentException", "BadDocumentException", "DocumentTooLargeException":
+			return fmt.Errorf("%w: %w", domain.ErrUnsupportedDocument, err)
+		}
 	}
 
-	fmt.Println(*output)
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() >= 500 {
+		return fmt.Errorf("%w: %w", domain.ErrAnalysisTemporary, err)
+	}
 
-	return parseTextractOutputWithConfig(output, config)
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %w", domain.ErrAnalysisTemporary, err)
+	}
+
+	return fmt.Errorf("%w: %w", domain.ErrTextractAnalysisFailed, err)
+}
+
+// isThroughputExceeded reports whether AWS rejected the call because too many
+// requests, or asynchronous jobs, are in flight
+func isThroughputExceeded(err error) bool {
+	var throughputErr *types.ProvisionedThroughputExceededException
+	var throttlingErr *types.ThrottlingException
+	var limitErr *types.LimitExceededException
+	return errors.As(err, &throughputErr) || errors.As(err, &throttlingErr) || errors.As(err, &limitErr)
 }
 
 // parseS3Path extracts bucket and key from an S3 path string (e.g., "s3://bucket/key")
@@ -179,22 +262,64 @@ func parseS3Path(s3Path string) (string, string, error) {
 	return parts[0], parts[1], nil
 }
 
-// parseTextractOutputWithConfig will convert the AWS Textract AnalyzeExpenseOutput to our internal ParsedTextractData
-// with improved parsing and confidence filtering
-func parseTextractOutputWithConfig(output *textract.AnalyzeExpenseOutput, config TextDetectionConfig) (*ports.ParsedTextractData, error) {
+// ParserVersion identifies the rules Textract responses are parsed with. Bump it
+// whenever they change, so stored responses parsed by older rules are parsed
+// again by the reparse-analyses command.
+const ParserVersion = 3
+
+// expenseResponse is the part of a Textract expense analysis response that is
+// stored to be parsed again, shaped like the AnalyzeExpense output
+type expenseResponse struct {
+	ExpenseDocuments []types.ExpenseDocument
+}
+
+// parseExpenseDocuments parses the expense documents of an analysis and keeps
+// their JSON as the raw response
+func parseExpenseDocuments(documents []types.ExpenseDocument, config TextDetectionConfig) (*ports.ParsedTextractData, error) {
+	rawResponse, err := json.Marshal(expenseResponse{ExpenseDocuments: documents})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Textract response: %w", err)
+	}
+	parsed, err := parseTextractOutputWithConfig(documents, config)
+	if err != nil {
+		return nil, err
+	}
+	parsed.RawResponse = rawResponse
+	return parsed, nil
+}
+
+// ParseRawResponse parses a Textract response stored from an earlier analysis
+// again, without calling AWS
+func ParseRawResponse(rawResponse []byte, config TextDetectionConfig) (*ports.ParsedTextractData, error) {
+	var response expenseResponse
+	if err := json.Unmarshal(rawResponse, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode stored Textract response: %w", err)
+	}
+	parsed, err := parseTextractOutputWithConfig(response.ExpenseDocuments, config)
+	if err != nil {
+		return nil, err
+	}
+	parsed.RawResponse = rawResponse
+	return parsed, nil
+}
+
+// parseTextractOutputWithConfig will convert the expense documents found by AWS Textract to our internal ParsedTextractData
+// with improved parsing and the confidence of each value. The documents of every page are combined into one bill.
+func parseTextractOutputWithConfig(documents []types.ExpenseDocument, config TextDetectionConfig) (*ports.ParsedTextractData, error) {
 	parsedData := &ports.ParsedTextractData{
-		LineItems: []ports.ParsedLineItem{},
+		LineItems:  []ports.ParsedLineItem{},
+		Detections: domain.FieldDetections{},
 	}
 	var rawTextBuilder strings.Builder
 
-	for _, expenseDoc := range output.ExpenseDocuments {
+	// Numbers are read in the format of the receipt's currency and language, and
+	// set once the ambiguous ones are settled against the rest of the receipt
+	format := newNumberFormat(config, receiptCurrency(documents, config))
+	numbers := &receiptNumbers{}
+
+	for documentIndex, expenseDoc := range documents {
 		// Collect text from summary fields for RawTextOutput
 		for _, summaryField := range expenseDoc.SummaryFields {
-			// Apply confidence filtering
-			if !meetsConfidenceThreshold(summaryField, config.MinConfidence) {
-				continue
-			}
-
 			if summaryField.LabelDetection != nil && summaryField.LabelDetection.Text != nil {
 				rawTextBuilder.WriteString(*summaryField.LabelDetection.Text)
 				rawTextBuilder.WriteString(": ")
@@ -217,34 +342,56 @@ func parseTextractOutputWithConfig(output *textract.AnalyzeExpenseOutput, config
 			switch {
 			case isVendorField(fieldType, fieldLabel):
 				parsedData.VendorName = aws.String(cleanVendorName(valueText))
+				parsedData.Detections[domain.BillFieldVendorName] = fieldDetection(summaryField, config.MinConfidence)
 			case isDateField(fieldType, fieldLabel):
 				parsedTime, err := parseDateEnhanced(valueText)
 				if err == nil {
 					parsedData.TransactionDate = parsedTime
+					parsedData.Detections[domain.BillFieldTransactionDate] = fieldDetection(summaryField, config.MinConfidence)
+				}
+			case isSubtotalField(fieldType, fieldLabel):
+				amount, err := format.parseAmount(valueText)
+				if err == nil {
+					numbers.subtotal = amount
+				}
+			case isTaxField(fieldType, fieldLabel):
+				amount, err := format.parseAmount(valueText)
+				if err == nil {
+					numbers.taxes = append(numbers.taxes, amount)
+				}
+			case isTipField(fieldType, fieldLabel):
+				amount, err := format.parseAmount(valueText)
+				if err == nil {
+					numbers.tips = append(numbers.tips, amount)
+				}
+			case isDiscountField(fieldType, fieldLabel):
+				amount, err := format.parseAmount(valueText)
+				if err == nil {
+					numbers.discounts = append(numbers.discounts, amount)
 				}
 			case isTotalField(fieldType, fieldLabel):
-				amount, err := parseFloatEnhanced(valueText, config.CurrencyCodes)
+				amount, err := format.parseAmount(valueText)
 				if err == nil {
-					parsedData.TotalAmount = aws.Float64(amount)
+					numbers.total = amount
+					parsedData.Detections[domain.BillFieldTotalAmount] = fieldDetection(summaryField, config.MinConfidence)
 				}
 			}
 		}
 
 		// Collect text from line items for RawTextOutput and parse line items
-		for _, itemGroup := range expenseDoc.LineItemGroups {
-			for _, lineItem := range itemGroup.LineItems {
-				parsedLineItem := ports.ParsedLineItem{}
+		for groupIndex, itemGroup := range expenseDoc.LineItemGroups {
+			for itemIndex, lineItem := range itemGroup.LineItems {
+				parsedLineItem := ports.ParsedLineItem{
+					Key:        fmt.Sprintf("%d/%d/%d", documentIndex, groupIndex, itemIndex),
+					Detections: domain.FieldDetections{},
+				}
+				var lineItemNumbers itemNumbers
 				var lineItemTextParts []string
 
-				// Track if this line item has sufficient confidence
+				// Track if this line item has any field read
 				hasValidFields := false
 
 				for _, field := range lineItem.LineItemExpenseFields {
-					// Apply confidence filtering
-					if !meetsConfidenceThreshold(field, config.MinConfidence) {
-						continue
-					}
-
 					fieldType := field.Type
 					fieldValue := field.ValueDetection
 
@@ -262,31 +409,40 @@ func parseTextractOutputWithConfig(output *textract.AnalyzeExpenseOutput, config
 					switch {
 					case isItemDescriptionField(fieldType):
 						parsedLineItem.Description = cleanDescription(valueText)
+						parsedLineItem.Detections[domain.LineItemFieldDescription] = fieldDetection(field, config.MinConfidence)
 						hasValidFields = true
 					case isQuantityField(fieldType):
-						qty, err := parseFloatEnhanced(valueText, config.CurrencyCodes)
+						qty, err := format.parseQuantity(valueText)
 						if err == nil {
-							parsedLineItem.Quantity = aws.Float64(qty)
+							lineItemNumbers.quantity = qty
+							parsedLineItem.Detections[domain.LineItemFieldQuantity] = fieldDetection(field, config.MinConfidence)
 							hasValidFields = true
 						}
 					case isUnitPriceField(fieldType):
-						price, err := parseFloatEnhanced(valueText, config.CurrencyCodes)
+						price, err := format.parseAmount(valueText)
 						if err == nil {
-							parsedLineItem.UnitPrice = aws.Float64(price)
+							lineItemNumbers.unitPrice = price
+							parsedLineItem.Detections[domain.LineItemFieldUnitPrice] = fieldDetection(field, config.MinConfidence)
 							hasValidFields = true
 						}
 					case isTotalPriceField(fieldType):
-						totalPrice, err := parseFloatEnhanced(valueText, config.CurrencyCodes)
+						totalPrice, err := format.parseAmount(valueText)
 						if err == nil {
-							parsedLineItem.TotalPrice = aws.Float64(totalPrice)
+							lineItemNumbers.totalPrice = totalPrice
+							parsedLineItem.Detections[domain.LineItemFieldTotalPrice] = fieldDetection(field, config.MinConfidence)
 							hasValidFields = true
 						}
 					}
+
+					if parsedLineItem.PageNumber == nil && field.PageNumber != nil {
+						parsedLineItem.PageNumber = aws.Int(int(*field.PageNumber))
+					}
 				}
 
-				// Only add line items that have valid fields and meet confidence requirements
+				// Only add line items that have valid fields
 				if hasValidFields && parsedLineItem.Description != "" {
 					parsedData.LineItems = append(parsedData.LineItems, parsedLineItem)
+					numbers.items = append(numbers.items, lineItemNumbers)
 					if len(lineItemTextParts) > 0 {
 						rawTextBuilder.WriteString(strings.Join(lineItemTextParts, ", "))
 						rawTextBuilder.WriteString("\n")
@@ -295,16 +451,53 @@ func parseTextractOutputWithConfig(output *textract.AnalyzeExpenseOutput, config
 			}
 		}
 	}
+	numbers.resolve()
+	numbers.apply(parsedData)
 	parsedData.RawTextOutput = strings.TrimSpace(rawTextBuilder.String())
 	return parsedData, nil
 }
 
-// meetsConfidenceThreshold checks if a field meets the minimum confidence threshold
-func meetsConfidenceThreshold(field interface{}, minConfidence float64) bool {
-	// For now, we'll assume the field is valid if no confidence data is available
-	// This avoids type assertion issues while still providing the framework for confidence filtering
-	// In a production environment, you would implement proper type checking based on the actual AWS SDK types
-	return true
+// fieldConfidence returns how confident Textract is of the value of a field, from
+// 0 to 1. Textract reports it from 0 to 100, for the value and for the type.
+func fieldConfidence(field types.ExpenseField) (float64, bool) {
+	switch {
+	case field.ValueDetection != nil && field.ValueDetection.Confidence != nil:
+		return float64(*field.ValueDetection.Confidence) / 100, true
+	case field.Type != nil && field.Type.Confidence != nil:
+		return float64(*field.Type.Confidence) / 100, true
+	}
+	return 0, false
+}
+
+// fieldDetection returns the confidence of the value of a field and where on the
+// document it was read. Values below minConfidence are marked for review rather
+// than dropped, like those of unknown confidence.
+func fieldDetection(field types.ExpenseField, minConfidence float64) domain.FieldDetection {
+	var detection domain.FieldDetection
+	if confidence, ok := fieldConfidence(field); ok {
+		detection.Confidence = aws.Float64(roundRatio(confidence))
+	}
+	if minConfidence > domain.ReviewConfidence {
+		detection.ReviewThreshold = aws.Float64(minConfidence)
+	}
+	if field.PageNumber != nil {
+		detection.PageNumber = aws.Int(int(*field.PageNumber))
+	}
+	if field.ValueDetection != nil && field.ValueDetection.Geometry != nil && field.ValueDetection.Geometry.BoundingBox != nil {
+		box := field.ValueDetection.Geometry.BoundingBox
+		detection.BoundingBox = &domain.BoundingBox{
+			Left:   roundRatio(float64(box.Left)),
+			Top:    roundRatio(float64(box.Top)),
+			Width:  roundRatio(float64(box.Width)),
+			Height: roundRatio(float64(box.Height)),
+		}
+	}
+	return detection
+}
+
+// roundRatio drops the noise of the single precision ratios Textract returns
+func roundRatio(ratio float64) float64 {
+	return math.Round(ratio*10000) / 10000
 }
 
 // Enhanced field detection functions with Spanish support
@@ -359,6 +552,61 @@ func isTotalField(fieldType *types.ExpenseType, fieldLabel *types.ExpenseDetecti
 	return false
 }
 
+func isSubtotalField(fieldType *types.ExpenseType, fieldLabel *types.ExpenseDetection) bool {
+	if fieldType != nil && fieldType.Text != nil {
+		return strings.ToUpper(*fieldType.Text) == "SUBTOTAL"
+	}
+	if fieldLabel != nil && fieldLabel.Text != nil {
+		text := strings.ToUpper(*fieldLabel.Text)
+		return strings.Contains(text, "SUBTOTAL") || strings.Contains(text, "SUB TOTAL")
+	}
+	return false
+}
+
+func isTaxField(fieldType *types.ExpenseType, fieldLabel *types.ExpenseDetection) bool {
+	if fieldType != nil && fieldType.Text != nil {
+		return strings.ToUpper(*fieldType.Text) == "TAX"
+	}
+	if fieldLabel != nil && fieldLabel.Text != nil {
+		text := strings.ToUpper(*fieldLabel.Text)
+		spanishTaxKeywords := []string{"IVA", "IMPUESTO", "IMPOCONSUMO", "TAX"}
+		for _, keyword := range spanishTaxKeywords {
+			if strings.Contains(text, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isTipField(fieldType *types.ExpenseType, fieldLabel *types.ExpenseDetection) bool {
+	if fieldType != nil && fieldType.Text != nil {
+		text := strings.ToUpper(*fieldType.Text)
+		return text == "GRATUITY" || text == "SERVICE_CHARGE"
+	}
+	if fieldLabel != nil && fieldLabel.Text != nil {
+		text := strings.ToUpper(*fieldLabel.Text)
+		spanishTipKeywords := []string{"PROPINA", "SERVICIO", "TIP", "GRATUITY"}
+		for _, keyword := range spanishTipKeywords {
+			if strings.Contains(text, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isDiscountField(fieldType *types.ExpenseType, fieldLabel *types.ExpenseDetection) bool {
+	if fieldType != nil && fieldType.Text != nil {
+		return strings.ToUpper(*fieldType.Text) == "DISCOUNT"
+	}
+	if fieldLabel != nil && fieldLabel.Text != nil {
+		text := strings.ToUpper(*fieldLabel.Text)
+		return strings.Contains(text, "DESCUENTO") || strings.Contains(text, "DISCOUNT")
+	}
+	return false
+}
+
 func isItemDescriptionField(fieldType *types.ExpenseType) bool {
 	if fieldType != nil && fieldType.Text != nil {
 		text := strings.ToUpper(*fieldType.Text)
@@ -412,52 +660,6 @@ func cleanDescription(desc string) string {
 	return desc
 }
 
-// parseFloatEnhanced attempts to parse a string into a float64 with enhanced currency support
-func parseFloatEnhanced(s string, currencyCodes []string) (float64, error) {
-	// Remove common currency symbols and codes
-	s = strings.ReplaceAll(s, ",", "")
-
-	// Remove currency symbols
-	currencySymbols := map[string]string{
-		"$": "", "€": "", "£": "", "¥": "", "₱": "", "₦": "", "₹": "", "₪": "", "₩": "", "₨": "",
-		"USD": "", "EUR": "", "GBP": "", "JPY": "", "PHP": "", "NGN": "", "INR": "", "ILS": "", "KRW": "", "PKR": "",
-	}
-
-	for symbol, replacement := range currencySymbols {
-		s = strings.ReplaceAll(s, symbol, replacement)
-	}
-
-	// Handle Spanish number formatting (comma as decimal separator)
-	// Check if comma is used as decimal separator (common in Spanish)
-	if strings.Contains(s, ",") && strings.Contains(s, ".") {
-		// If both comma and dot exist, assume comma is thousands separator
-		s = strings.ReplaceAll(s, ",", "")
-	} else if strings.Contains(s, ",") && !strings.Contains(s, ".") {
-		// If only comma exists, it might be decimal separator
-		// Check if it's likely a decimal separator (e.g., "1,50" vs "1,500")
-		parts := strings.Split(s, ",")
-		if len(parts) == 2 && len(parts[1]) <= 2 {
-			// Likely decimal separator
-			s = strings.ReplaceAll(s, ",", ".")
-		} else {
-			// Likely thousands separator
-			s = strings.ReplaceAll(s, ",", "")
-		}
-	}
-
-	s = strings.TrimSpace(s)
-
-	// Try to parse as float
-	var f float64
-	_, err := fmt.Sscanf(s, "%f", &f)
-	if err != nil {
-		// Try with strconv for better error handling
-		f, err = strconv.ParseFloat(s, 64)
-	}
-
-	return f, err
-}
-
 // parseDateEnhanced attempts to parse a date string with enhanced Spanish support
 func parseDateEnhanced(dateStr string) (*time.Time, error) {
 	formats := []string{
@@ -0,0 +1,88 @@
+package texttrack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+)
+
+// DocumentAnalyzer analyzes stored documents with a text detection configuration.
+// The parsed data it returns includes the raw Textract response.
+type DocumentAnalyzer interface {
+	ports.TextProcessor
+	AnalyzeDocumentWithConfig(ctx context.Context, storagePath string, config TextDetectionConfig) (*ports.ParsedTextractData, error)
+}
+
+// CachingAnalyzer caches the Textract responses of another analyzer by the hash
+// of the analyzed document. A document analyzed before, uploaded again or
+// analyzed with another configuration, is only parsed again from the cached
+// response, without calling Textract.
+type CachingAnalyzer struct {
+	analyzer DocumentAnalyzer
+	files    ports.FileStore
+	cache    ports.AnalysisCache
+}
+
+// NewCachingAnalyzer creates an analyzer caching the responses of analyzer in
+// cache. Documents are read from files to be hashed.
+func NewCachingAnalyzer(analyzer DocumentAnalyzer, files ports.FileStore, cache ports.AnalysisCache) *CachingAnalyzer {
+	return &CachingAnalyzer{analyzer: analyzer, files: files, cache: cache}
+}
+
+func (a *CachingAnalyzer) AnalyzeDocument(ctx context.Context, storagePath string) (*ports.ParsedTextractData, error) {
+	return a.AnalyzeDocumentWithConfig(ctx, storagePath, DefaultConfig())
+}
+
+// AnalyzeDocumentWithConfig parses the cached response for the document when
+// there is one, and otherwise analyzes it and caches the response. Cache errors
+// only cost an analysis.
+func (a *CachingAnalyzer) AnalyzeDocumentWithConfig(ctx context.Context, storagePath string, config TextDetectionConfig) (*ports.ParsedTextractData, error) {
+	contentHash, err := a.contentHash(ctx, storagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	response, ok, err := a.cache.Get(ctx, contentHash)
+	if err != nil {
+		log.Printf("Textract: failed to read the analysis cache for %s: %v", storagePath, err)
+	}
+	if ok {
+		parsed, err := ParseRawResponse(response, config)
+		if err == nil {
+			return parsed, nil
+		}
+		log.Printf("Textract: cached response for %s can't be parsed, analyzing it again: %v", storagePath, err)
+	}
+
+	parsed, err := a.analyzer.AnalyzeDocumentWithConfig(ctx, storagePath, config)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed.RawResponse) > 0 {
+		if err := a.cache.Put(ctx, contentHash, parsed.RawResponse); err != nil {
+			log.Printf("Textract: failed to cache the analysis of %s: %v", storagePath, err)
+		}
+	}
+	return parsed, nil
+}
+
+// contentHash returns the hex-encoded SHA-256 hash of a stored document
+func (a *CachingAnalyzer) contentHash(ctx context.Context, storagePath string) (string, error) {
+	file, err := a.files.OpenFile(ctx, storagePath)
+	if err != nil {
+		return "", fmt.Errorf("%w: error reading %s: %w", domain.ErrAnalysisTemporary, storagePath, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("%w: error reading %s: %w", domain.ErrAnalysisTemporary, storagePath, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
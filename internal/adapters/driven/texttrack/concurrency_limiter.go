@@ -0,0 +1,71 @@
+package texttrack
+
+import (
+	"context"
+	"sync"
+)
+
+// concurrencyLimiter bounds the Textract calls in flight. The limit is halved
+// whenever AWS reports the provisioned throughput is exceeded, and grows back
+// by one after a full window of successful calls, up to max.
+type concurrencyLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	max       int
+	inFlight  int
+	successes int
+	changed   chan struct{}
+}
+
+func newConcurrencyLimiter(max int) *concurrencyLimiter {
+	if max < 1 {
+		max = 1
+	}
+	return &concurrencyLimiter{
+		limit:   max,
+		max:     max,
+		changed: make(chan struct{}),
+	}
+}
+
+// acquire waits for a free slot or for ctx to be done
+func (l *concurrencyLimiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.inFlight < l.limit {
+			l.inFlight++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// release frees a slot and adapts the limit to the outcome of the call
+func (l *concurrencyLimiter) release(throttled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	if throttled {
+		l.limit = max(1, l.limit/2)
+		l.successes = 0
+	} else {
+		l.successes++
+		if l.successes >= l.limit && l.limit < l.max {
+			l.limit++
+			l.successes = 0
+		}
+	}
+
+	// Wake up the callers waiting for a slot
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
@@ -0,0 +1,170 @@
+package texttrack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/textract"
+	"github.com/aws/aws-sdk-go-v2/service/textract/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+)
+
+// isMultiPageDocument reports whether the stored file is a format that may hold
+// several pages, which the synchronous AnalyzeExpense call can't read
+func isMultiPageDocument(key string) bool {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".pdf", ".tif", ".tiff":
+		return true
+	}
+	return false
+}
+
+// isUnsupportedDocument reports whether Textract rejected the document for the
+// synchronous API, as it does for PDFs and TIFFs with more than one page
+func isUnsupportedDocument(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "UnsupportedDocumentException"
+}
+
+// analyzeExpense runs the synchronous AnalyzeExpense call, for single-page documents
+func (a *AWSTextractAdapter) analyzeExpense(ctx context.Context, bucket, key string) ([]types.ExpenseDocument, error) {
+	return a.analyzeExpenseDocument(ctx, &types.Document{
+		S3Object: &types.S3Object{
+			Bucket: aws.String(bucket),
+			Name:   aws.String(key),
+		},
+	})
+}
+
+// analyzeStoredExpense analyzes a document kept where Textract can't read it by
+// sending its bytes. Only the synchronous API accepts bytes, so documents
+// with several pages can't be analyzed this way.
+func (a *AWSTextractAdapter) analyzeStoredExpense(ctx context.Context, storagePath string) ([]types.ExpenseDocument, error) {
+	file, err := a.files.OpenFile(ctx, storagePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error reading %s: %w", domain.ErrAnalysisTemporary, storagePath, err)
+	}
+
+	return a.analyzeExpenseDocument(ctx, &types.Document{Bytes: content})
+}
+
+func (a *AWSTextractAdapter) analyzeExpenseDocument(ctx context.Context, document *types.Document) ([]types.ExpenseDocument, error) {
+	input := &textract.AnalyzeExpenseInput{
+		Document: document,
+	}
+
+	// Note: LanguageHints is not available for AnalyzeExpense, only for DetectDocumentText
+	// We'll rely on the enhanced parsing logic instead
+
+	if err := a.limiter.acquire(ctx); err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.ErrAnalysisTemporary, err)
+	}
+	output, err := a.textractClient.AnalyzeExpense(ctx, input)
+	a.limiter.release(isThroughputExceeded(err))
+	if err != nil {
+		return nil, err
+	}
+
+	return output.ExpenseDocuments, nil
+}
+
+// startExpenseAnalysis analyzes a multi-page document with the asynchronous
+// StartExpenseAnalysis call, polls GetExpenseAnalysis until the job is done and
+// returns the expense documents of every result page. The job holds a
+// concurrency slot the whole time, since AWS limits the jobs in progress too.
+// A job started by an earlier attempt, as told by the context, is resumed
+// rather than started again.
+func (a *AWSTextractAdapter) startExpenseAnalysis(ctx context.Context, bucket, key string) (documents []types.ExpenseDocument, err error) {
+	if err := a.limiter.acquire(ctx); err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.ErrAnalysisTemporary, err)
+	}
+	defer func() { a.limiter.release(isThroughputExceeded(err)) }()
+
+	async := asyncAnalysisFrom(ctx)
+	if async.JobID != "" {
+		documents, err = a.getExpenseAnalysis(ctx, async.JobID)
+		if !isInvalidJobID(err) {
+			return documents, err
+		}
+		log.Printf("Textract: expense analysis job %s can no longer be resumed, starting another: %v", async.JobID, err)
+	}
+
+	started, err := a.textractClient.StartExpenseAnalysis(ctx, &textract.StartExpenseAnalysisInput{
+		DocumentLocation: &types.DocumentLocation{
+			S3Object: &types.S3Object{
+				Bucket: aws.String(bucket),
+				Name:   aws.String(key),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	jobID := aws.ToString(started.JobId)
+	if async.Started != nil {
+		async.Started(jobID)
+	}
+
+	return a.getExpenseAnalysis(ctx, jobID)
+}
+
+// getExpenseAnalysis polls an asynchronous expense analysis job until it is done
+// and returns the expense documents of every result page
+func (a *AWSTextractAdapter) getExpenseAnalysis(ctx context.Context, jobID string) (documents []types.ExpenseDocument, err error) {
+	ticker := time.NewTicker(a.pollInterval)
+	defer ticker.Stop()
+
+	var nextToken *string
+	for {
+		output, err := a.textractClient.GetExpenseAnalysis(ctx, &textract.GetExpenseAnalysisInput{
+			JobId:     aws.String(jobID),
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		switch output.JobStatus {
+		case types.JobStatusInProgress:
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-ticker.C:
+			}
+			continue
+		case types.JobStatusFailed:
+			return nil, fmt.Errorf("expense analysis job %s failed: %s", jobID, aws.ToString(output.StatusMessage))
+		case types.JobStatusPartialSuccess:
+			if nextToken == nil {
+				log.Printf("Textract: expense analysis job %s only partially succeeded: %s", jobID, aws.ToString(output.StatusMessage))
+			}
+		}
+
+		documents = append(documents, output.ExpenseDocuments...)
+		if output.NextToken == nil {
+			return documents, nil
+		}
+		nextToken = output.NextToken
+	}
+}
+
+// isInvalidJobID reports whether Textract doesn't know an asynchronous job,
+// as happens once its results expired
+func isInvalidJobID(err error) bool {
+	var invalidErr *types.InvalidJobIdException
+	return errors.As(err, &invalidErr)
+}
@@ -0,0 +1,247 @@
+package texttrack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/textract/types"
+)
+
+// currencyFormat is how amounts in a currency are printed on receipts
+type currencyFormat struct {
+	exponent int      // digits of the minor unit printed on receipts
+	decimal  byte     // decimal separator, 0 when it depends on the language
+	symbols  []string // symbols amounts may be printed with, besides the code
+}
+
+// currencyFormats holds the currencies amounts are read in. Exponents follow
+// ISO 4217, except for COP, whose centavos are never printed on receipts.
+var currencyFormats = map[string]currencyFormat{
+	"USD": {exponent: 2, decimal: '.', symbols: []string{"US$", "$"}},
+	"EUR": {exponent: 2, symbols: []string{"€"}},
+	"GBP": {exponent: 2, decimal: '.', symbols: []string{"£"}},
+	"CAD": {exponent: 2, symbols: []string{"CA$", "C$", "$"}},
+	"AUD": {exponent: 2, decimal: '.', symbols: []string{"AU$", "A$", "$"}},
+	"JPY": {exponent: 0, decimal: '.', symbols: []string{"¥", "円"}},
+	"CHF": {exponent: 2, decimal: '.', symbols: []string{"Fr."}},
+	"SEK": {exponent: 2, decimal: ',', symbols: []string{"kr"}},
+	"NOK": {exponent: 2, decimal: ',', symbols: []string{"kr"}},
+	"DKK": {exponent: 2, decimal: ',', symbols: []string{"kr"}},
+	"MXN": {exponent: 2, decimal: '.', symbols: []string{"MX$", "$"}},
+	"ARS": {exponent: 2, decimal: ',', symbols: []string{"$"}},
+	"CLP": {exponent: 0, decimal: ',', symbols: []string{"$"}},
+	"COP": {exponent: 0, decimal: ',', symbols: []string{"$"}},
+	"PEN": {exponent: 2, decimal: '.', symbols: []string{"S/"}},
+	"UYU": {exponent: 2, decimal: ',', symbols: []string{"$U", "$"}},
+	"BRL": {exponent: 2, decimal: ',', symbols: []string{"R$"}},
+	"MZN": {exponent: 2, decimal: ',', symbols: []string{"MT"}},
+	"AOA": {exponent: 2, decimal: ',', symbols: []string{"Kz"}},
+	"INR": {exponent: 2, decimal: '.', symbols: []string{"₹"}},
+	"PHP": {exponent: 2, decimal: '.', symbols: []string{"₱"}},
+	"NGN": {exponent: 2, decimal: '.', symbols: []string{"₦"}},
+	"ILS": {exponent: 2, decimal: '.', symbols: []string{"₪"}},
+	"KRW": {exponent: 0, decimal: '.', symbols: []string{"₩"}},
+	"PKR": {exponent: 2, decimal: '.', symbols: []string{"₨"}},
+}
+
+// languageDecimals holds the decimal separator of each language, for currencies
+// printed differently depending on the country
+var languageDecimals = map[string]byte{
+	"en": '.',
+	"es": ',',
+	"fr": ',',
+	"de": ',',
+	"it": ',',
+	"pt": ',',
+}
+
+// numberFormat is how the numbers on a receipt are printed
+type numberFormat struct {
+	exponent int  // digits of the minor unit of amounts
+	decimal  byte // decimal separator
+}
+
+// newNumberFormat returns the format of a receipt in currency, falling back to
+// the languages of the configuration for what the currency doesn't settle
+func newNumberFormat(config TextDetectionConfig, currency string) numberFormat {
+	format := numberFormat{exponent: 2, decimal: '.'}
+	for _, language := range config.Languages {
+		language = strings.ToLower(strings.TrimSpace(language))
+		if decimal, ok := languageDecimals[language]; ok {
+			format.decimal = decimal
+			break
+		}
+	}
+	if currencyFormat, ok := currencyFormats[currency]; ok {
+		format.exponent = currencyFormat.exponent
+		if currencyFormat.decimal != 0 {
+			format.decimal = currencyFormat.decimal
+		}
+	}
+	return format
+}
+
+// receiptCurrency returns the currency of the amounts on a receipt: the configured
+// currency whose code or symbol the amounts are printed with, else the one Textract
+// detected when it is configured, else the first configured currency. The longest
+// match wins, so "R$" is read as reais rather than dollars.
+func receiptCurrency(documents []types.ExpenseDocument, config TextDetectionConfig) string {
+	var codes []string
+	for _, code := range config.CurrencyCodes {
+		codes = append(codes, strings.ToUpper(strings.TrimSpace(code)))
+	}
+
+	var texts, detected []string
+	collect := func(field types.ExpenseField) {
+		if field.ValueDetection != nil && field.ValueDetection.Text != nil {
+			texts = append(texts, *field.ValueDetection.Text)
+		}
+		if field.Currency != nil && field.Currency.Code != nil {
+			detected = append(detected, strings.ToUpper(*field.Currency.Code))
+		}
+	}
+	for _, document := range documents {
+		for _, field := range document.SummaryFields {
+			collect(field)
+		}
+		for _, group := range document.LineItemGroups {
+			for _, lineItem := range group.LineItems {
+				for _, field := range lineItem.LineItemExpenseFields {
+					collect(field)
+				}
+			}
+		}
+	}
+
+	currency, matched := "", 0
+	for _, code := range codes {
+		for _, marker := range append([]string{code}, currencyFormats[code].symbols...) {
+			if len(marker) <= matched {
+				continue
+			}
+			for _, text := range texts {
+				if strings.Contains(text, marker) {
+					currency, matched = code, len(marker)
+					break
+				}
+			}
+		}
+	}
+	if currency != "" {
+		return currency
+	}
+
+	for _, code := range detected {
+		for _, configured := range codes {
+			if code == configured {
+				return code
+			}
+		}
+	}
+	if len(codes) > 0 {
+		return codes[0]
+	}
+	return ""
+}
+
+// number is a number read from a receipt. A number printed as "1.500" may be
+// one and a half or fifteen hundred; readings holds every value the text can
+// be read as, the most likely first.
+type number struct {
+	readings []float64
+	chosen   int // index of the reading used
+}
+
+func (n *number) value() float64 {
+	return n.readings[n.chosen]
+}
+
+func (n *number) ambiguous() bool {
+	return len(n.readings) > 1
+}
+
+// parseAmount reads an amount of money, which has at most as many decimals as
+// the minor unit of the currency
+func (f numberFormat) parseAmount(text string) (*number, error) {
+	return f.parse(text, f.exponent >= 3)
+}
+
+// parseQuantity reads a quantity, such as a weight, whose decimals aren't bound
+// by the currency
+func (f numberFormat) parseQuantity(text string) (*number, error) {
+	return f.parse(text, true)
+}
+
+// parse reads a number printed in the format. The separator is unambiguous when
+// both are used, when one is used more than once or when it isn't followed by
+// exactly three digits. A single separator followed by three digits is read as
+// a decimal separator when it is the decimal separator of the format and
+// threeDecimals allows it, and as a group separator otherwise, keeping the
+// other reading as an alternative.
+func (f numberFormat) parse(text string, threeDecimals bool) (*number, error) {
+	negative := strings.ContainsAny(text, "-(")
+
+	// Currency symbols and codes are dropped, as are the spaces and apostrophes
+	// some locales separate digit groups with
+	var cleaned strings.Builder
+	for _, r := range text {
+		if r >= '0' && r <= '9' || r == '.' || r == ',' {
+			cleaned.WriteRune(r)
+		}
+	}
+	s := strings.Trim(cleaned.String(), ".,")
+	if s == "" {
+		return nil, fmt.Errorf("no number in '%s'", text)
+	}
+
+	var readings []string
+	last := strings.LastIndexAny(s, ".,")
+	if last < 0 {
+		readings = []string{s}
+	} else {
+		separator := s[last]
+		whole := strings.NewReplacer(".", "", ",", "").Replace(s[:last])
+		fraction := s[last+1:]
+		decimal := whole + "." + fraction
+		grouped := whole + fraction
+
+		mixed := strings.ContainsAny(s[:last], string(otherSeparator(separator)))
+		repeated := strings.Count(s, string(separator)) > 1
+		switch {
+		case mixed, whole == "0", len(fraction) != 3 && !repeated:
+			readings = []string{decimal}
+		case repeated:
+			// "1.234.567", or a decimal separator misread as a group separator
+			if len(fraction) == 3 {
+				readings = []string{grouped}
+			} else {
+				readings = []string{decimal}
+			}
+		case separator == f.decimal && threeDecimals:
+			readings = []string{decimal, grouped}
+		default:
+			readings = []string{grouped, decimal}
+		}
+	}
+
+	n := &number{}
+	for _, reading := range readings {
+		value, err := strconv.ParseFloat(reading, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse number: '%s'", text)
+		}
+		if negative {
+			value = -value
+		}
+		n.readings = append(n.readings, value)
+	}
+	return n, nil
+}
+
+func otherSeparator(separator byte) byte {
+	if separator == '.' {
+		return ','
+	}
+	return '.'
+}
@@ -0,0 +1,82 @@
+package texttrack
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewNumberFormat(t *testing.T) {
+	tests := []struct {
+		name      string
+		languages []string
+		currency  string
+		want      numberFormat
+	}{
+		{name: "COP prints no centavos with a decimal comma", languages: []string{"en"}, currency: "COP", want: numberFormat{exponent: 0, decimal: ','}},
+		{name: "USD uses a decimal point", languages: []string{"es"}, currency: "USD", want: numberFormat{exponent: 2, decimal: '.'}},
+		{name: "EUR follows a Spanish receipt", languages: []string{"es", "en"}, currency: "EUR", want: numberFormat{exponent: 2, decimal: ','}},
+		{name: "EUR follows an English receipt", languages: []string{"en", "es"}, currency: "EUR", want: numberFormat{exponent: 2, decimal: '.'}},
+		{name: "languages are matched loosely", languages: []string{" DE "}, currency: "EUR", want: numberFormat{exponent: 2, decimal: ','}},
+		{name: "unknown languages are skipped", languages: []string{"xx", "fr"}, currency: "XYZ", want: numberFormat{exponent: 2, decimal: ','}},
+		{name: "nothing configured", want: numberFormat{exponent: 2, decimal: '.'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newNumberFormat(TextDetectionConfig{Languages: tt.languages}, tt.currency)
+			if got != tt.want {
+				t.Errorf("newNumberFormat(%v, %q) = %+v, want %+v", tt.languages, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumberFormatParse(t *testing.T) {
+	cop := newNumberFormat(TextDetectionConfig{Languages: []string{"es"}}, "COP")
+	eur := newNumberFormat(TextDetectionConfig{Languages: []string{"es"}}, "EUR")
+	usd := newNumberFormat(TextDetectionConfig{Languages: []string{"en"}}, "USD")
+	kwd := numberFormat{exponent: 3, decimal: '.'}
+
+	tests := []struct {
+		name          string
+		format        numberFormat
+		text          string
+		threeDecimals bool
+		want          []float64
+	}{
+		{name: "COP thousands", format: cop, text: "12.500", want: []float64{12500, 12.5}},
+		{name: "COP with symbol", format: cop, text: "$ 12.500", want: []float64{12500, 12.5}},
+		{name: "COP millions", format: cop, text: "1.234.567", want: []float64{1234567}},
+		{name: "COP quantity", format: cop, text: "1.500", threeDecimals: true, want: []float64{1500, 1.5}},
+		{name: "EUR amount", format: eur, text: "1.234,56", want: []float64{1234.56}},
+		{name: "EUR cents", format: eur, text: "4,50 €", want: []float64{4.5}},
+		{name: "EUR negative", format: eur, text: "-5,00", want: []float64{-5}},
+		{name: "USD amount", format: usd, text: "1,234.56", want: []float64{1234.56}},
+		{name: "USD thousands", format: usd, text: "$1,234", want: []float64{1234, 1.234}},
+		{name: "USD in parentheses", format: usd, text: "(12.00)", want: []float64{-12}},
+		{name: "USD quantity", format: usd, text: "1.500", threeDecimals: true, want: []float64{1.5, 1500}},
+		{name: "leading zero", format: usd, text: "0.500", want: []float64{0.5}},
+		{name: "three decimal currency", format: kwd, text: "1.250", threeDecimals: true, want: []float64{1.25, 1250}},
+		{name: "no separator", format: cop, text: "8900", want: []float64{8900}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.format.parse(tt.text, tt.threeDecimals)
+			if err != nil {
+				t.Fatalf("parse(%q) returned error: %v", tt.text, err)
+			}
+			if !reflect.DeepEqual(got.readings, tt.want) {
+				t.Errorf("parse(%q) = %v, want %v", tt.text, got.readings, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumberFormatParseRejectsText(t *testing.T) {
+	for _, text := range []string{"", "TOTAL", "$", ".,"} {
+		if _, err := newNumberFormat(TextDetectionConfig{}, "USD").parse(text, false); err == nil {
+			t.Errorf("parse(%q) returned no error", text)
+		}
+	}
+}
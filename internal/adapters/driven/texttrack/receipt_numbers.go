@@ -0,0 +1,283 @@
+package texttrack
+
+import (
+	"math"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+)
+
+// maxReadingCombinations bounds the combinations of readings of ambiguous numbers
+// tried on a receipt. Receipts with more settle one number at a time instead.
+const maxReadingCombinations = 4096
+
+// receiptNumbers holds the numbers read from a receipt, so that ambiguous ones can
+// be settled by how they add up with the others before they are parsed into the bill
+type receiptNumbers struct {
+	subtotal  *number
+	total     *number
+	taxes     []*number // a receipt can list several taxes, tips and discounts
+	tips      []*number
+	discounts []*number
+	items     []itemNumbers // by index of the parsed line item
+}
+
+type itemNumbers struct {
+	quantity   *number
+	unitPrice  *number
+	totalPrice *number
+}
+
+// price returns what the item adds to the bill
+func (i itemNumbers) price() (float64, bool) {
+	switch {
+	case i.totalPrice != nil:
+		return i.totalPrice.value(), true
+	case i.unitPrice != nil && i.quantity != nil:
+		return i.unitPrice.value() * i.quantity.value(), true
+	case i.unitPrice != nil:
+		return i.unitPrice.value(), true
+	}
+	return 0, false
+}
+
+// resolve picks the reading of every ambiguous number that makes the receipt most
+// consistent: items whose quantity times unit price is their total, items adding
+// up to the subtotal or total, a subtotal plus charges making the total, and no
+// item or charge larger than the total. Ties keep the most likely readings. Items
+// that settle their own numbers are resolved first, so that only the numbers
+// still ambiguous are tried together.
+func (r *receiptNumbers) resolve() {
+	settled := r.resolveItems()
+
+	var ambiguous []*number
+	combinations := 1
+	for _, n := range r.all() {
+		if n.ambiguous() && !settled[n] {
+			ambiguous = append(ambiguous, n)
+			if combinations <= maxReadingCombinations {
+				combinations *= len(n.readings)
+			}
+		}
+	}
+	if len(ambiguous) == 0 {
+		return
+	}
+	if combinations > maxReadingCombinations {
+		r.improve(ambiguous)
+		return
+	}
+
+	best := make([]int, len(ambiguous))
+	bestScore, bestChanges := r.score(), 0
+	for combination := 1; combination < combinations; combination++ {
+		rest, changes := combination, 0
+		for _, n := range ambiguous {
+			n.chosen = rest % len(n.readings)
+			rest /= len(n.readings)
+			if n.chosen != 0 {
+				changes++
+			}
+		}
+		if score := r.score(); score > bestScore || score == bestScore && changes < bestChanges {
+			bestScore, bestChanges = score, changes
+			for i, n := range ambiguous {
+				best[i] = n.chosen
+			}
+		}
+	}
+	for i, n := range ambiguous {
+		n.chosen = best[i]
+	}
+}
+
+// resolveItems picks the readings of the numbers of each item with a quantity,
+// unit price and total when only one combination of them makes quantity times
+// unit price the total, and returns the numbers it settled
+func (r *receiptNumbers) resolveItems() map[*number]bool {
+	settled := make(map[*number]bool)
+	for _, item := range r.items {
+		if item.quantity == nil || item.unitPrice == nil || item.totalPrice == nil {
+			continue
+		}
+		numbers := []*number{item.quantity, item.unitPrice, item.totalPrice}
+		combinations := 1
+		for _, n := range numbers {
+			combinations *= len(n.readings)
+		}
+		if combinations == 1 {
+			continue
+		}
+
+		match, matches := 0, 0
+		for combination := 0; combination < combinations; combination++ {
+			rest := combination
+			for _, n := range numbers {
+				n.chosen = rest % len(n.readings)
+				rest /= len(n.readings)
+			}
+			if amountsMatch(item.quantity.value()*item.unitPrice.value(), item.totalPrice.value()) {
+				match = combination
+				matches++
+			}
+		}
+
+		// Items consistent under several readings are left to the whole receipt
+		if matches != 1 {
+			match = 0
+		}
+		for _, n := range numbers {
+			n.chosen = match % len(n.readings)
+			match /= len(n.readings)
+			if matches == 1 {
+				settled[n] = true
+			}
+		}
+	}
+	return settled
+}
+
+// improve picks readings one number at a time, keeping every change that makes
+// the receipt more consistent, for receipts with too many ambiguous numbers to
+// try every combination of their readings
+func (r *receiptNumbers) improve(ambiguous []*number) {
+	score := r.score()
+	for improved := true; improved; {
+		improved = false
+		for _, n := range ambiguous {
+			chosen := n.chosen
+			for reading := range n.readings {
+				if reading == chosen {
+					continue
+				}
+				n.chosen = reading
+				if candidate := r.score(); candidate > score {
+					score, chosen, improved = candidate, reading, true
+				}
+			}
+			n.chosen = chosen
+		}
+	}
+}
+
+// score counts how consistent the chosen readings of the receipt are
+func (r *receiptNumbers) score() int {
+	score := 0
+	charges := sum(r.taxes) + sum(r.tips) - r.discount()
+
+	var itemsTotal float64
+	hasItems := false
+	for _, item := range r.items {
+		if item.quantity != nil && item.unitPrice != nil && item.totalPrice != nil &&
+			amountsMatch(item.quantity.value()*item.unitPrice.value(), item.totalPrice.value()) {
+			score += 2
+		}
+		if price, ok := item.price(); ok {
+			itemsTotal += price
+			hasItems = true
+			if r.total != nil && price > r.total.value() {
+				score--
+			}
+		}
+		if r.total != nil && item.unitPrice != nil && item.unitPrice.value() > r.total.value() {
+			score--
+		}
+	}
+
+	if hasItems {
+		switch {
+		case r.subtotal != nil && amountsMatch(itemsTotal, r.subtotal.value()),
+			r.total != nil && amountsMatch(itemsTotal, r.total.value()),
+			r.total != nil && amountsMatch(itemsTotal+charges, r.total.value()):
+			score += 2
+		}
+	}
+	if r.subtotal != nil && r.total != nil && amountsMatch(r.subtotal.value()+charges, r.total.value()) {
+		score += 2
+	}
+	if r.total != nil {
+		for _, charges := range [][]*number{r.taxes, r.tips} {
+			for _, charge := range charges {
+				if charge.value() > r.total.value() {
+					score--
+				}
+			}
+		}
+	}
+	return score
+}
+
+// all returns every number read from the receipt
+func (r *receiptNumbers) all() []*number {
+	var numbers []*number
+	for _, n := range []*number{r.subtotal, r.total} {
+		if n != nil {
+			numbers = append(numbers, n)
+		}
+	}
+	numbers = append(numbers, r.taxes...)
+	numbers = append(numbers, r.tips...)
+	numbers = append(numbers, r.discounts...)
+	for _, item := range r.items {
+		for _, n := range []*number{item.quantity, item.unitPrice, item.totalPrice} {
+			if n != nil {
+				numbers = append(numbers, n)
+			}
+		}
+	}
+	return numbers
+}
+
+// apply sets the chosen readings on the parsed data
+func (r *receiptNumbers) apply(parsedData *ports.ParsedTextractData) {
+	if r.subtotal != nil {
+		parsedData.SubtotalAmount = aws.Float64(r.subtotal.value())
+	}
+	if r.total != nil {
+		parsedData.TotalAmount = aws.Float64(r.total.value())
+	}
+	if len(r.taxes) > 0 {
+		parsedData.TaxAmount = aws.Float64(sum(r.taxes))
+	}
+	if len(r.tips) > 0 {
+		parsedData.TipAmount = aws.Float64(sum(r.tips))
+	}
+	if len(r.discounts) > 0 {
+		parsedData.DiscountAmount = aws.Float64(r.discount())
+	}
+	for i, item := range r.items {
+		lineItem := &parsedData.LineItems[i]
+		if item.quantity != nil {
+			lineItem.Quantity = aws.Float64(item.quantity.value())
+		}
+		if item.unitPrice != nil {
+			lineItem.UnitPrice = aws.Float64(item.unitPrice.value())
+		}
+		if item.totalPrice != nil {
+			lineItem.TotalPrice = aws.Float64(item.totalPrice.value())
+		}
+	}
+}
+
+// discount returns the discounts of the receipt, however their sign was printed
+func (r *receiptNumbers) discount() float64 {
+	discount := 0.0
+	for _, n := range r.discounts {
+		discount += math.Abs(n.value())
+	}
+	return discount
+}
+
+func sum(numbers []*number) float64 {
+	total := 0.0
+	for _, n := range numbers {
+		total += n.value()
+	}
+	return total
+}
+
+// amountsMatch reports whether two amounts are equal but for rounding
+func amountsMatch(a, b float64) bool {
+	return math.Abs(a-b) <= math.Max(0.01, 0.01*math.Max(math.Abs(a), math.Abs(b)))
+}
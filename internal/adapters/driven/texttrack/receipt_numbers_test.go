@@ -0,0 +1,55 @@
+package texttrack
+
+import "testing"
+
+func TestResolveSettlesItemsOfLongReceipts(t *testing.T) {
+	format := newNumberFormat(TextDetectionConfig{Languages: []string{"en"}}, "USD")
+	mustParse := func(text string, quantity bool) *number {
+		t.Helper()
+		parse := format.parseAmount
+		if quantity {
+			parse = format.parseQuantity
+		}
+		n, err := parse(text)
+		if err != nil {
+			t.Fatalf("parse(%q) returned error: %v", text, err)
+		}
+		return n
+	}
+
+	// 1.500 kg at 2.000 a kg is 3.00: more ambiguous numbers than combinations tried
+	receipt := &receiptNumbers{total: mustParse("42.00", false)}
+	for i := 0; i < 14; i++ {
+		receipt.items = append(receipt.items, itemNumbers{
+			quantity:   mustParse("1.500", true),
+			unitPrice:  mustParse("2.000", false),
+			totalPrice: mustParse("3.00", false),
+		})
+	}
+	receipt.resolve()
+
+	for i, item := range receipt.items {
+		if item.quantity.value() != 1.5 || item.unitPrice.value() != 2 {
+			t.Errorf("item %d read as %v x %v, want 1.5 x 2", i, item.quantity.value(), item.unitPrice.value())
+		}
+	}
+}
+
+func TestResolveImprovesReceiptsWithTooManyReadings(t *testing.T) {
+	format := newNumberFormat(TextDetectionConfig{Languages: []string{"es"}}, "EUR")
+
+	// Prices printed as 2.000 are thousands at first, but add up to the total as two euros
+	receipt := &receiptNumbers{}
+	receipt.total, _ = format.parseAmount("30,00")
+	for i := 0; i < 15; i++ {
+		price, _ := format.parseAmount("2.000")
+		receipt.items = append(receipt.items, itemNumbers{totalPrice: price})
+	}
+	receipt.resolve()
+
+	for i, item := range receipt.items {
+		if item.totalPrice.value() != 2 {
+			t.Errorf("item %d read as %v, want 2", i, item.totalPrice.value())
+		}
+	}
+}
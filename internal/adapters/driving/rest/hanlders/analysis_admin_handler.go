@@ -0,0 +1,104 @@
+package hanlders
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/application"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AnalysisAdminHandler handles admin HTTP requests for background bill analyses
+type AnalysisAdminHandler struct {
+	billService *application.BillService
+}
+
+// NewAnalysisAdminHandler creates a new AnalysisAdminHandler
+func NewAnalysisAdminHandler(billService *application.BillService) *AnalysisAdminHandler {
+	if billService == nil {
+		panic("BillService cannot be nil in NewAnalysisAdminHandler")
+	}
+	return &AnalysisAdminHandler{billService: billService}
+}
+
+// ListDeadLetteredJobs godoc
+// @Summary List dead-lettered bill analyses
+// @Description List the analysis jobs that failed permanently or ran out of retries, most recent first. Requires the admin claim.
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} domain.AnalysisJobDTO "Dead-lettered analysis jobs"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 403 {object} gin.H{"error": string} "Forbidden - admin access required"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - queue error"
+// @Router /admin/analysis-jobs/dead-letter [get]
+func (h *AnalysisAdminHandler) ListDeadLetteredJobs(c *gin.Context) {
+	jobs, err := h.billService.ListDeadLetteredAnalyses(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list analysis jobs: " + err.Error()})
+		return
+	}
+
+	response := make([]domain.AnalysisJobDTO, len(jobs))
+	for i, job := range jobs {
+		response[i] = formatAnalysisJobResponse(job)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RetryJob godoc
+// @Summary Retry a dead-lettered bill analysis
+// @Description Queue a dead-lettered analysis job again with a fresh attempt count and put its bill back to pending. Requires the admin claim.
+// @Tags Admin
+// @Produce json
+// @Param job_id path string true "UUID of the analysis job"
+// @Success 202 {object} domain.AnalysisJobDTO "Queued analysis job"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid job ID format"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 403 {object} gin.H{"error": string} "Forbidden - admin access required"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - job not found"
+// @Failure 409 {object} gin.H{"error": string} "Conflict - job is not dead-lettered"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - queue or database error"
+// @Router /admin/analysis-jobs/{job_id}/retry [post]
+func (h *AnalysisAdminHandler) RetryJob(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID format"})
+		return
+	}
+
+	job, err := h.billService.RetryAnalysis(c, jobID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrJobNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		case errors.Is(err, domain.ErrJobNotFailed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry analysis job: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, formatAnalysisJobResponse(job))
+}
+
+func formatAnalysisJobResponse(job *domain.AnalysisJob) domain.AnalysisJobDTO {
+	response := domain.AnalysisJobDTO{
+		ID:        job.ID.String(),
+		BillID:    job.BillID.String(),
+		Status:    string(job.Status),
+		Attempts:  job.Attempts,
+		LastError: job.LastError,
+		RunAt:     job.RunAt.Format(time.RFC3339),
+		CreatedAt: job.CreatedAt.Format(time.RFC3339),
+	}
+	if job.FinishedAt != nil {
+		finishedAt := job.FinishedAt.Format(time.RFC3339)
+		response.FinishedAt = &finishedAt
+	}
+	return response
+}
@@ -0,0 +1,273 @@
+package hanlders
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// UploadBillBatch godoc
+// @Summary Upload several bill images at once
+// @Description Upload many receipts in one request, either as several files or as a single ZIP archive, and queue them for analysis with a shared configuration. One bill is created per file. When group_id is set every bill is attached to the group as paid by paid_by_member_id. Files are handled independently: the response lists the status of each one, and a rejected file doesn't affect the others.
+// @Tags Bills
+// @Accept mpfd
+// @Produce json
+// @Param files formData file false "Image files of the bills (repeat the field for each file)"
+// @Param archive formData file false "ZIP archive of image files, instead of files"
+// @Param group_id formData string false "UUID of a group owned by the user to attach the bills to"
+// @Param paid_by_member_id formData string false "UUID of the group member who paid the bills, required with group_id"
+// @Param profile formData string false "Name of a configuration from /bills/analysis-configs used as the base configuration (default: 'default')"
+// @Param languages formData string false "Comma-separated list of language codes (e.g., 'es,en' for Spanish and English)"
+// @Param min_confidence formData number false "Confidence below which values are marked for review, when above 0.9 (0.0 to 1.0)"
+// @Param currency_codes formData string false "Comma-separated list of currency codes (e.g., 'EUR,USD,MXN')"
+// @Success 202 {object} domain.BillBatchDTO "Batch with the status of each file"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - no files, too many files, invalid archive, group or configuration"
+// @Failure 413 {object} gin.H{"error": string, "code": string} "Request Entity Too Large - request_too_large; files over the size limit are rejected one by one with file_too_large"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - group or member not found"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - database error"
+// @Router /bills/batch [post]
+func (h *BillHandler) UploadBillBatch(c *gin.Context) {
+	userIDStr, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userID, ok := userIDStr.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	h.limits.limitRequestBody(c)
+	form, err := c.MultipartForm()
+	if err != nil {
+		if respondUploadError(c, formFileError(err)) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read files from request: " + err.Error()})
+		return
+	}
+
+	config, ok := formAnalysisConfig(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown analysis profile, see /bills/analysis-configs"})
+		return
+	}
+
+	req := domain.UploadBillBatchRequest{UserID: userID}
+	if groupIDStr := c.PostForm("group_id"); groupIDStr != "" {
+		groupID, err := uuid.Parse(groupIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID format"})
+			return
+		}
+		paidByMemberID, err := uuid.Parse(c.PostForm("paid_by_member_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "paid_by_member_id is required with group_id"})
+			return
+		}
+		req.GroupID = &groupID
+		req.PaidByMemberID = &paidByMemberID
+	}
+
+	files, closeFiles, err := h.batchFiles(form)
+	defer closeFiles()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Files = files
+
+	batch, err := h.billService.UploadBillBatch(c, req, config)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrEmptyBatch), errors.Is(err, domain.ErrBatchTooLarge), errors.Is(err, domain.ErrInvalidInput):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, domain.ErrGroupNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		case errors.Is(err, domain.ErrGroupMemberNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group member not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload batch: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, formatBillBatchResponse(batch))
+}
+
+// GetBillBatch godoc
+// @Summary Get the status of a batch upload
+// @Description Retrieve the files of a batch upload with the current analysis status of each bill.
+// @Tags Bills
+// @Produce json
+// @Param batch_id path string true "UUID of the batch"
+// @Success 200 {object} domain.BillBatchDTO "Batch with the status of each file"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid batch ID format"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - batch not found or not owned by user"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - database error"
+// @Router /bills/batch/{batch_id} [get]
+func (h *BillHandler) GetBillBatch(c *gin.Context) {
+	userIDStr, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userID, ok := userIDStr.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	batchID, err := uuid.Parse(c.Param("batch_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch ID format"})
+		return
+	}
+
+	batch, err := h.billService.GetBillBatch(c, batchID, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrBatchNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Batch not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve batch: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, formatBillBatchResponse(batch))
+}
+
+// batchFiles opens the uploaded files, or the entries of the uploaded ZIP archive.
+// Files over the size limit fail when they are read, so only they are rejected.
+// The returned function closes them and must be called even on error.
+func (h *BillHandler) batchFiles(form *multipart.Form) ([]domain.UploadBillRequest, func(), error) {
+	var closers []io.Closer
+	closeAll := func() {
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}
+
+	archives := form.File["archive"]
+	headers := form.File["files"]
+	if len(archives) > 0 && len(headers) > 0 {
+		return nil, closeAll, fmt.Errorf("send either files or an archive, not both")
+	}
+	if len(archives) > 1 {
+		return nil, closeAll, fmt.Errorf("only one archive can be uploaded at a time")
+	}
+	if len(headers) > domain.MaxBatchFiles {
+		return nil, closeAll, domain.ErrBatchTooLarge
+	}
+
+	var files []domain.UploadBillRequest
+	for _, header := range headers {
+		file, err := header.Open()
+		if err != nil {
+			return nil, closeAll, fmt.Errorf("failed to read %s: %v", header.Filename, err)
+		}
+		closers = append(closers, file)
+
+		// Only a hint, the service detects the real type from the file bytes
+		contentType := header.Header.Get("Content-Type")
+		files = append(files, domain.UploadBillRequest{
+			File:        h.limits.limitFile(file),
+			Filename:    header.Filename,
+			ContentType: contentType,
+		})
+	}
+
+	if len(archives) == 1 {
+		archive, err := archives[0].Open()
+		if err != nil {
+			return nil, closeAll, fmt.Errorf("failed to read archive: %v", err)
+		}
+		closers = append(closers, archive)
+
+		reader, err := zip.NewReader(archive, archives[0].Size)
+		if err != nil {
+			return nil, closeAll, fmt.Errorf("archive is not a valid ZIP file")
+		}
+
+		for _, entry := range reader.File {
+			name := path.Base(entry.Name)
+			// Skip folders and the metadata files added by macOS and Windows
+			if entry.FileInfo().IsDir() || strings.HasPrefix(name, ".") ||
+				strings.HasPrefix(entry.Name, "__MACOSX/") || strings.EqualFold(name, "Thumbs.db") {
+				continue
+			}
+			if len(files) == domain.MaxBatchFiles {
+				return nil, closeAll, domain.ErrBatchTooLarge
+			}
+
+			file, err := entry.Open()
+			if err != nil {
+				return nil, closeAll, fmt.Errorf("failed to read %s from archive: %v", entry.Name, err)
+			}
+			closers = append(closers, file)
+
+			contentType := mime.TypeByExtension(strings.ToLower(path.Ext(name)))
+			files = append(files, domain.UploadBillRequest{
+				File:        h.limits.limitFile(file),
+				Filename:    name,
+				ContentType: contentType,
+			})
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, closeAll, domain.ErrEmptyBatch
+	}
+	return files, closeAll, nil
+}
+
+func formatBillBatchResponse(batch *domain.BillBatch) domain.BillBatchDTO {
+	response := domain.BillBatchDTO{
+		ID:        batch.ID.String(),
+		CreatedAt: batch.CreatedAt.Format(time.RFC3339),
+		Files:     make([]domain.BillBatchFileDTO, len(batch.Files)),
+	}
+	if batch.GroupID != nil {
+		groupID := batch.GroupID.String()
+		response.GroupID = &groupID
+	}
+	if batch.PaidByMemberID != nil {
+		paidByMemberID := batch.PaidByMemberID.String()
+		response.PaidByMemberID = &paidByMemberID
+	}
+
+	for i, file := range batch.Files {
+		response.Files[i] = domain.BillBatchFileDTO{
+			Filename:   file.Filename,
+			Status:     string(file.Status),
+			BillStatus: string(file.BillStatus),
+			Error:      file.Error,
+			ErrorCode:  file.ErrorCode,
+		}
+		if file.BillID != nil {
+			billID := file.BillID.String()
+			response.Files[i].BillID = &billID
+		}
+		if file.DuplicateOfBillID != nil {
+			duplicateOfBillID := file.DuplicateOfBillID.String()
+			response.Files[i].DuplicateOfBillID = &duplicateOfBillID
+		}
+	}
+	return response
+}
@@ -0,0 +1,248 @@
+package hanlders
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/application"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// BillCorrectionHandler handles HTTP requests for correcting OCR results
+type BillCorrectionHandler struct {
+	correctionService *application.BillCorrectionService
+}
+
+// NewBillCorrectionHandler creates a new BillCorrectionHandler
+func NewBillCorrectionHandler(correctionService *application.BillCorrectionService) *BillCorrectionHandler {
+	if correctionService == nil {
+		panic("BillCorrectionService cannot be nil in NewBillCorrectionHandler")
+	}
+	return &BillCorrectionHandler{correctionService: correctionService}
+}
+
+// UpdateBill godoc
+// @Summary Correct the extracted fields of a bill
+// @Description Fix the vendor, transaction date or amounts read by OCR. Only the provided fields change. The original OCR value and every correction are kept in the bill history, and the bill is reconciled again.
+// @Tags Bills
+// @Accept json
+// @Produce json
+// @Param bill_id path string true "UUID of the bill"
+// @Param request body domain.UpdateBillRequest true "Corrected fields"
+// @Success 200 {object} domain.BillDTO "Corrected bill"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid values or nothing to update"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - bill not found or not owned by user"
+// @Failure 409 {object} gin.H{"error": string} "Conflict - bill is being analyzed or was modified concurrently"
+// @Router /bills/{bill_id} [patch]
+func (h *BillCorrectionHandler) UpdateBill(c *gin.Context) {
+	userID, billID, ok := billRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req domain.UpdateBillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+		return
+	}
+
+	billWithURL, err := h.correctionService.UpdateBill(c, billID, userID, req)
+	if err != nil {
+		respondCorrectionError(c, "Failed to update bill", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatBillResponse(billWithURL))
+}
+
+// AddLineItem godoc
+// @Summary Add a line item to a bill
+// @Description Add a line item the OCR missed. The item is marked as entered by the user.
+// @Tags Bills
+// @Accept json
+// @Produce json
+// @Param bill_id path string true "UUID of the bill"
+// @Param request body domain.CreateLineItemRequest true "Line item"
+// @Success 201 {object} domain.BillDTO "Bill with the new line item"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid values"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - bill not found or not owned by user"
+// @Failure 409 {object} gin.H{"error": string} "Conflict - bill is being analyzed or was modified concurrently"
+// @Router /bills/{bill_id}/line-items [post]
+func (h *BillCorrectionHandler) AddLineItem(c *gin.Context) {
+	userID, billID, ok := billRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req domain.CreateLineItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+		return
+	}
+
+	billWithURL, err := h.correctionService.AddLineItem(c, billID, userID, req)
+	if err != nil {
+		respondCorrectionError(c, "Failed to add line item", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, formatBillResponse(billWithURL))
+}
+
+// UpdateLineItem godoc
+// @Summary Correct a line item
+// @Description Fix the description, quantity or prices of a line item. Only the provided fields change and the previous values are kept in the bill history.
+// @Tags Bills
+// @Accept json
+// @Produce json
+// @Param bill_id path string true "UUID of the bill"
+// @Param line_item_id path string true "UUID of the line item"
+// @Param request body domain.UpdateLineItemRequest true "Corrected fields"
+// @Success 200 {object} domain.BillDTO "Bill with the corrected line item"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid values or nothing to update"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - bill or line item not found"
+// @Failure 409 {object} gin.H{"error": string} "Conflict - bill is being analyzed or was modified concurrently"
+// @Router /bills/{bill_id}/line-items/{line_item_id} [patch]
+func (h *BillCorrectionHandler) UpdateLineItem(c *gin.Context) {
+	userID, billID, ok := billRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	lineItemID, err := uuid.Parse(c.Param("line_item_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid line item ID format"})
+		return
+	}
+
+	var req domain.UpdateLineItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+		return
+	}
+
+	billWithURL, err := h.correctionService.UpdateLineItem(c, billID, lineItemID, userID, req)
+	if err != nil {
+		respondCorrectionError(c, "Failed to update line item", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatBillResponse(billWithURL))
+}
+
+// DeleteLineItem godoc
+// @Summary Remove a line item from a bill
+// @Description Remove a line item that the OCR read by mistake. The deletion is recorded in the bill history.
+// @Tags Bills
+// @Produce json
+// @Param bill_id path string true "UUID of the bill"
+// @Param line_item_id path string true "UUID of the line item"
+// @Success 200 {object} domain.BillDTO "Bill without the line item"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid IDs"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - bill or line item not found"
+// @Failure 409 {object} gin.H{"error": string} "Conflict - bill is being analyzed or was modified concurrently"
+// @Router /bills/{bill_id}/line-items/{line_item_id} [delete]
+func (h *BillCorrectionHandler) DeleteLineItem(c *gin.Context) {
+	userID, billID, ok := billRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	lineItemID, err := uuid.Parse(c.Param("line_item_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid line item ID format"})
+		return
+	}
+
+	billWithURL, err := h.correctionService.DeleteLineItem(c, billID, lineItemID, userID)
+	if err != nil {
+		respondCorrectionError(c, "Failed to delete line item", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatBillResponse(billWithURL))
+}
+
+// GetBillHistory godoc
+// @Summary Get the edit history of a bill
+// @Description List every recorded value of the corrected fields, oldest first, with whether it came from OCR or a user.
+// @Tags Bills
+// @Produce json
+// @Param bill_id path string true "UUID of the bill"
+// @Success 200 {object} domain.BillHistoryDTO "Bill revisions"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid bill ID format"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - bill not found or not owned by user"
+// @Router /bills/{bill_id}/history [get]
+func (h *BillCorrectionHandler) GetBillHistory(c *gin.Context) {
+	userID, billID, ok := billRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	bill, revisions, err := h.correctionService.GetBillHistory(c, billID, userID)
+	if err != nil {
+		respondCorrectionError(c, "Failed to retrieve bill history", err)
+		return
+	}
+
+	response := domain.BillHistoryDTO{
+		BillID:    bill.ID.String(),
+		Version:   bill.Version,
+		Revisions: make([]domain.BillRevisionDTO, len(revisions)),
+	}
+	for i, revision := range revisions {
+		response.Revisions[i] = formatBillRevisionResponse(revision)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// respondCorrectionError maps bill correction errors to HTTP responses
+func respondCorrectionError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, domain.ErrBillNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+	case errors.Is(err, domain.ErrLineItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Line item not found"})
+	case errors.Is(err, domain.ErrBillNotEditable),
+		errors.Is(err, domain.ErrBillVersionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidInput),
+		errors.Is(err, domain.ErrEmptyCorrection),
+		errors.Is(err, domain.ErrInvalidTransactionDate),
+		errors.Is(err, domain.ErrInvalidLineItemQuantity),
+		errors.Is(err, domain.ErrInvalidLineItemPrice),
+		errors.Is(err, domain.ErrLineItemDescriptionEmpty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message + ": " + err.Error()})
+	}
+}
+
+func formatBillRevisionResponse(revision *domain.BillRevision) domain.BillRevisionDTO {
+	response := domain.BillRevisionDTO{
+		ID:        revision.ID.String(),
+		Version:   revision.Version,
+		Field:     revision.Field,
+		Value:     revision.Value,
+		Source:    string(revision.Source),
+		CreatedAt: revision.CreatedAt.Format(time.RFC3339),
+	}
+	if revision.LineItemID != nil {
+		lineItemID := revision.LineItemID.String()
+		response.LineItemID = &lineItemID
+	}
+	if revision.EditedByUserID != nil {
+		editedBy := revision.EditedByUserID.String()
+		response.EditedByUserID = &editedBy
+	}
+	return response
+}
@@ -0,0 +1,124 @@
+package hanlders
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateBillUploadURL godoc
+// @Summary Get a URL to upload a bill file directly to storage
+// @Description Create a bill awaiting its file and return a presigned URL the client uploads the file to directly, without streaming it through the API. Send the file with the returned method and headers, then call /bills/{bill_id}/complete to have it checked and analyzed. The declared size must be the exact size of the file.
+// @Tags Bills
+// @Accept json
+// @Produce json
+// @Param request body domain.BillUploadURLRequest true "Name, type and size of the file"
+// @Success 201 {object} domain.BillUploadDTO "Bill awaiting its file, with the upload URL"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - missing filename, content type or size"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 413 {object} gin.H{"error": string, "code": string} "Request Entity Too Large - file_too_large"
+// @Failure 415 {object} gin.H{"error": string, "code": string} "Unsupported Media Type - unsupported_file_type, the file is not a JPEG, PNG, PDF or TIFF"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - file storage or database error"
+// @Router /bills/upload-url [post]
+func (h *BillHandler) CreateBillUploadURL(c *gin.Context) {
+	userIDStr, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userID, ok := userIDStr.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var req domain.BillUploadURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+		return
+	}
+
+	upload, err := h.billService.CreateBillUpload(c, userID, req, h.limits.MaxFileBytes)
+	if err != nil {
+		if respondUploadError(c, err) {
+			return
+		}
+		if errors.Is(err, domain.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload URL: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, domain.BillUploadDTO{
+		BillID:    upload.Bill.ID.String(),
+		Status:    string(upload.Bill.Status),
+		UploadURL: upload.URL,
+		Method:    upload.Method,
+		Headers:   upload.Headers,
+		ExpiresAt: upload.ExpiresAt.Format(time.RFC3339),
+	})
+}
+
+// CompleteBillUpload godoc
+// @Summary Complete a direct bill upload and queue it for analysis
+// @Description Check the file uploaded to the URL from /bills/upload-url like an upload through the API: its size, real type and integrity, and whether it duplicates another bill. A valid file is queued for analysis with the given configuration and the bill is returned in pending status. A rejected file is deleted with its bill.
+// @Tags Bills
+// @Accept json
+// @Produce json
+// @Param bill_id path string true "UUID of the bill"
+// @Param request body domain.CompleteBillUploadRequest false "Analysis configuration"
+// @Success 202 {object} domain.BillDTO "Bill uploaded and queued for analysis"
+// @Failure 400 {object} gin.H{"error": string, "code": string} "Bad Request - missing_file, the file was not uploaded yet, empty_file, or unknown profile"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - bill not found or not owned by user"
+// @Failure 409 {object} gin.H{"error": string, "code": string} "Conflict - the upload was already completed, or duplicate_bill when DUPLICATE_POLICY is block"
+// @Failure 413 {object} gin.H{"error": string, "code": string} "Request Entity Too Large - file_too_large"
+// @Failure 415 {object} gin.H{"error": string, "code": string} "Unsupported Media Type - unsupported_file_type"
+// @Failure 422 {object} gin.H{"error": string, "code": string} "Unprocessable Entity - corrupt_file"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - file storage, queue, or database error"
+// @Router /bills/{bill_id}/complete [post]
+func (h *BillHandler) CompleteBillUpload(c *gin.Context) {
+	userID, billID, ok := billRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req domain.CompleteBillUploadRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+			return
+		}
+	}
+
+	config, ok := buildAnalysisConfig(req.Profile, req.Languages, req.MinConfidence, req.CurrencyCodes)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown analysis profile, see /bills/analysis-configs"})
+		return
+	}
+
+	billWithURL, err := h.billService.CompleteBillUpload(c, billID, userID, h.limits.MaxFileBytes, config)
+	if err != nil {
+		if respondUploadError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, domain.ErrBillNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+		case errors.Is(err, domain.ErrBillUploadCompleted):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete upload: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, formatBillResponse(billWithURL))
+}
@@ -0,0 +1,135 @@
+package hanlders
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// sseKeepAliveInterval is how often a comment is sent on idle event streams so
+// proxies and load balancers don't close them
+const sseKeepAliveInterval = 15 * time.Second
+
+// StreamBillEvents godoc
+// @Summary Stream the status of a bill
+// @Description Server-Sent Events stream of the status transitions of a bill (pending, processing, analyzed or failed). The current status is sent first, and the parsed summary is included once the bill is analyzed. The stream ends after the bill is analyzed or failed.
+// @Tags Bills
+// @Produce text/event-stream
+// @Param bill_id path string true "UUID of the bill"
+// @Success 200 {object} domain.BillEvent "bill.status events"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid bill ID format"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - bill not found or not owned by user"
+// @Router /bills/{bill_id}/events [get]
+func (h *BillHandler) StreamBillEvents(c *gin.Context) {
+	userID, billID, ok := billRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	current, events, cancel, err := h.billService.SubscribeBillEvents(c, billID, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrBillNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe to bill events: " + err.Error()})
+		return
+	}
+	defer cancel()
+
+	writeBillEvent(c, current)
+	if current.IsFinal() {
+		return
+	}
+
+	streamBillEvents(c, events, func(event *domain.BillEvent) (send, more bool) {
+		if event.BillID != billID {
+			return false, true
+		}
+		return true, !event.IsFinal()
+	})
+}
+
+// StreamUserBillEvents godoc
+// @Summary Stream the status of all bills of the user
+// @Description Server-Sent Events stream of the status transitions of every bill of the authenticated user, including the parsed summary once a bill is analyzed. The stream stays open until the client disconnects.
+// @Tags Bills
+// @Produce text/event-stream
+// @Success 200 {object} domain.BillEvent "bill.status events"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Router /bills/events [get]
+func (h *BillHandler) StreamUserBillEvents(c *gin.Context) {
+	userIDStr, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userID, ok := userIDStr.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	events, cancel, err := h.billService.SubscribeUserEvents(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe to bill events: " + err.Error()})
+		return
+	}
+	defer cancel()
+
+	// Send the headers right away so the client knows the stream is open
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	setEventStreamHeaders(c)
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Flush()
+
+	streamBillEvents(c, events, func(event *domain.BillEvent) (send, more bool) {
+		return true, true
+	})
+}
+
+// streamBillEvents writes the events accepted by filter until filter stops the
+// stream, the subscription closes or the client disconnects
+func streamBillEvents(c *gin.Context, events <-chan *domain.BillEvent, filter func(event *domain.BillEvent) (send, more bool)) {
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			send, more := filter(event)
+			if send {
+				writeBillEvent(c, event)
+			}
+			return more
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			return true
+		}
+	})
+}
+
+func writeBillEvent(c *gin.Context, event *domain.BillEvent) {
+	setEventStreamHeaders(c)
+	c.SSEvent(string(event.Type), event)
+	c.Writer.Flush()
+}
+
+func setEventStreamHeaders(c *gin.Context) {
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+}
@@ -1,6 +1,7 @@
 package hanlders
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -16,14 +17,15 @@ import (
 // BillHandler handles HTTP requests for bill operations
 type BillHandler struct {
 	billService *application.BillService
+	limits      UploadLimits
 }
 
 // NewBillHandler creates a new BillHandler
-func NewBillHandler(billService *application.BillService) *BillHandler {
+func NewBillHandler(billService *application.BillService, limits UploadLimits) *BillHandler {
 	if billService == nil {
 		panic("BillService cannot be nil in NewBillHandler")
 	}
-	return &BillHandler{billService: billService}
+	return &BillHandler{billService: billService, limits: limits}
 }
 
 // GetBill godoc
@@ -130,6 +132,7 @@ func (h *BillHandler) ListBills(c *gin.Context) {
 		Total: total,
 	}
 
+	previews := h.billService.BillPreviewURLs(c, bills)
 	for i, bill := range bills {
 		response.Bills[i] = domain.BillSummaryDTO{
 			ID:              bill.ID.String(),
@@ -139,6 +142,8 @@ func (h *BillHandler) ListBills(c *gin.Context) {
 			VendorName:      safeString(bill.VendorName),
 			TotalAmount:     bill.TotalAmount,
 			TransactionDate: bill.TransactionDate,
+			ThumbnailURL:    previews[bill.ID].ThumbnailURL,
+			PreviewURL:      previews[bill.ID].PreviewURL,
 		}
 	}
 
@@ -238,19 +243,24 @@ func (h *BillHandler) DeleteBill(c *gin.Context) {
 }
 
 // UploadAndAnalyzeBillWithConfig godoc
-// @Summary Upload and analyze a bill image with custom language and analysis configuration
-// @Description Upload a bill image file and immediately process it with AWS Textract OCR using custom language settings, confidence thresholds, and currency codes. Ideal for multi-language documents and specific regional requirements.
+// @Summary Upload a bill image and queue it for analysis with custom language and analysis configuration
+// @Description Upload a bill image file and queue it for AWS Textract OCR using custom language settings, confidence thresholds, and currency codes. The bill is returned right away in pending status and analyzed in the background; poll /bills/{bill_id} for the result. Ideal for multi-language documents and specific regional requirements.
 // @Tags Bills
 // @Accept mpfd
 // @Produce json
-// @Param image formData file true "Image file of the bill to upload and analyze (JPEG, PNG, PDF supported)"
+// @Param image formData file true "Image file of the bill to upload and analyze (JPEG, PNG, and single or multi-page PDF and TIFF supported)"
+// @Param profile formData string false "Name of a configuration from /bills/analysis-configs used as the base configuration (default: 'default')"
 // @Param languages formData string false "Comma-separated list of language codes (e.g., 'es,en' for Spanish and English)"
-// @Param min_confidence formData number false "Minimum confidence threshold (0.0 to 1.0, default: 0.7)"
+// @Param min_confidence formData number false "Confidence below which values are marked for review, when above 0.9 (0.0 to 1.0)"
 // @Param currency_codes formData string false "Comma-separated list of currency codes (e.g., 'EUR,USD,MXN')"
-// @Success 200 {object} domain.BillDTO "Successfully uploaded and analyzed bill with extracted data"
-// @Failure 400 {object} gin.H{"error": string} "Bad Request - missing file, invalid file format, or malformed request"
+// @Success 202 {object} domain.BillDTO "Bill uploaded and queued for analysis"
+// @Failure 400 {object} gin.H{"error": string, "code": string} "Bad Request - missing_file, empty_file, or malformed request"
 // @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
-// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - file storage, Textract processing, or database error"
+// @Failure 409 {object} gin.H{"error": string, "code": string, "duplicate_of_bill_id": string, "duplicate_reason": string} "Conflict - duplicate_bill, the file duplicates a bill of the user, when DUPLICATE_POLICY is block"
+// @Failure 413 {object} gin.H{"error": string, "code": string} "Request Entity Too Large - file_too_large or request_too_large"
+// @Failure 415 {object} gin.H{"error": string, "code": string} "Unsupported Media Type - unsupported_file_type, the file is not a JPEG, PNG, PDF or TIFF"
+// @Failure 422 {object} gin.H{"error": string, "code": string} "Unprocessable Entity - corrupt_file, the file is damaged or incomplete"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - file storage, queue, or database error"
 // @Router /bills/upload-analyze-config [post]
 func (h *BillHandler) UploadAndAnalyzeBillWithConfig(c *gin.Context) {
 	// Get user ID from context (set by auth middleware)
@@ -266,60 +276,153 @@ func (h *BillHandler) UploadAndAnalyzeBillWithConfig(c *gin.Context) {
 		return
 	}
 
+	h.limits.limitRequestBody(c)
 	file, header, err := c.Request.FormFile("image")
 	if err != nil {
+		if respondUploadError(c, formFileError(err)) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read image from request: " + err.Error()})
 		return
 	}
 	defer file.Close()
 
-	contentType := header.Header.Get("Content-Type")
-	if contentType == "" {
-		contentType = "application/octet-stream" // Default content type
+	if h.limits.MaxFileBytes > 0 && header.Size > h.limits.MaxFileBytes {
+		respondUploadError(c, domain.ErrFileTooLarge)
+		return
 	}
 
+	// Only a hint, the service detects the real type from the file bytes
+	contentType := header.Header.Get("Content-Type")
+
 	// Parse configuration parameters
-	config := texttrack.DefaultConfig() // Start with default config
+	config, ok := formAnalysisConfig(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown analysis profile, see /bills/analysis-configs"})
+		return
+	}
 
-	// Parse languages
-	if languagesStr := c.PostForm("languages"); languagesStr != "" {
-		languages := strings.Split(languagesStr, ",")
-		for i, lang := range languages {
-			languages[i] = strings.TrimSpace(lang)
+	// Create upload request
+	uploadReq := domain.UploadBillRequest{
+		UserID:      userID,
+		File:        h.limits.limitFile(file),
+		Filename:    header.Filename,
+		ContentType: contentType,
+	}
+
+	// Upload the bill and queue its analysis with custom configuration
+	billWithURL, err := h.billService.UploadBillWithConfig(c, uploadReq, config)
+	if err != nil {
+		if respondUploadError(c, err) {
+			return
 		}
-		config.Languages = languages
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload bill: " + err.Error()})
+		return
 	}
 
-	// Parse min_confidence
-	if minConfidenceStr := c.PostForm("min_confidence"); minConfidenceStr != "" {
-		if minConfidence, err := strconv.ParseFloat(minConfidenceStr, 64); err == nil {
-			if minConfidence >= 0.0 && minConfidence <= 1.0 {
-				config.MinConfidence = minConfidence
-			}
+	c.JSON(http.StatusAccepted, formatBillResponse(billWithURL))
+}
+
+// ReanalyzeBill godoc
+// @Summary Analyze a stored bill again with a different configuration
+// @Description Run OCR again on the stored file of a bill, e.g. with a profile better suited to its language. Extracted values and line items are replaced in a single transaction. Fields and line items corrected by the user are kept, and deleted items are not added back, unless overwrite_corrections is true.
+// @Tags Bills
+// @Accept json
+// @Produce json
+// @Param bill_id path string true "UUID of the bill to analyze again"
+// @Param request body domain.ReanalyzeBillRequest false "Analysis configuration"
+// @Success 200 {object} domain.BillDTO "Bill with the new extracted data"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid bill ID or unknown profile"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - bill not found or not owned by user"
+// @Failure 409 {object} gin.H{"error": string} "Conflict - bill is already being analyzed or was modified concurrently"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - Textract processing or database error"
+// @Router /bills/{bill_id}/reanalyze [post]
+func (h *BillHandler) ReanalyzeBill(c *gin.Context) {
+	userID, billID, ok := billRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req domain.ReanalyzeBillRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+			return
 		}
 	}
 
-	// Parse currency_codes
-	if currencyCodesStr := c.PostForm("currency_codes"); currencyCodesStr != "" {
-		currencyCodes := strings.Split(currencyCodesStr, ",")
-		for i, code := range currencyCodes {
-			currencyCodes[i] = strings.TrimSpace(code)
+	config, ok := buildAnalysisConfig(req.Profile, req.Languages, req.MinConfidence, req.CurrencyCodes)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown analysis profile, see /bills/analysis-configs"})
+		return
+	}
+
+	billWithURL, err := h.billService.ReanalyzeBill(c, billID, userID, config, req.OverwriteCorrections)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrBillNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+		case errors.Is(err, domain.ErrBillNotEditable),
+			errors.Is(err, domain.ErrBillVersionConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reanalyze bill: " + err.Error()})
 		}
-		config.CurrencyCodes = currencyCodes
+		return
 	}
 
-	// Create upload request
-	uploadReq := domain.UploadBillRequest{
-		UserID:      userID,
-		File:        file,
-		Filename:    header.Filename,
-		ContentType: contentType,
+	c.JSON(http.StatusOK, formatBillResponse(billWithURL))
+}
+
+// ReparseBill godoc
+// @Summary Parse the stored analysis of a bill again with a different configuration
+// @Description Parse the Textract response stored from the last analysis of a bill again, e.g. with another confidence threshold or currencies, without analyzing the file again. Extracted values and line items are replaced like /reanalyze does. Fields and line items corrected by the user are kept, and deleted items are not added back, unless overwrite_corrections is true.
+// @Tags Bills
+// @Accept json
+// @Produce json
+// @Param bill_id path string true "UUID of the bill to parse again"
+// @Param request body domain.ReanalyzeBillRequest false "Analysis configuration"
+// @Success 200 {object} domain.BillDTO "Bill with the new extracted data"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid bill ID or unknown profile"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - bill not found, not owned by user or without a stored analysis"
+// @Failure 409 {object} gin.H{"error": string} "Conflict - bill is already being analyzed or was modified concurrently"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - parsing or database error"
+// @Router /bills/{bill_id}/reparse [post]
+func (h *BillHandler) ReparseBill(c *gin.Context) {
+	userID, billID, ok := billRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req domain.ReanalyzeBillRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+			return
+		}
+	}
+
+	config, ok := buildAnalysisConfig(req.Profile, req.Languages, req.MinConfidence, req.CurrencyCodes)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown analysis profile, see /bills/analysis-configs"})
+		return
 	}
 
-	// Upload and analyze the bill with custom configuration
-	billWithURL, err := h.billService.UploadAndAnalyzeBillWithConfig(c, uploadReq, config)
+	billWithURL, err := h.billService.ReparseBill(c, billID, userID, config, req.OverwriteCorrections)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload and analyze bill: " + err.Error()})
+		switch {
+		case errors.Is(err, domain.ErrBillNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+		case errors.Is(err, domain.ErrAnalysisRunNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bill has no stored analysis, reanalyze it instead"})
+		case errors.Is(err, domain.ErrBillNotEditable),
+			errors.Is(err, domain.ErrBillVersionConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse bill again: " + err.Error()})
+		}
 		return
 	}
 
@@ -420,6 +523,7 @@ func (h *BillHandler) GetAnalysisConfigs(c *gin.Context) {
 		"usage": gin.H{
 			"endpoint": "/bills/upload-analyze-config",
 			"parameters": gin.H{
+				"profile":        "Name of one of the configurations above to start from (e.g., 'spanish')",
 				"languages":      "Comma-separated list of language codes (e.g., 'es,en')",
 				"min_confidence": "Minimum confidence threshold (0.0 to 1.0)",
 				"currency_codes": "Comma-separated list of currency codes (e.g., 'EUR,USD,MXN')",
@@ -430,6 +534,84 @@ func (h *BillHandler) GetAnalysisConfigs(c *gin.Context) {
 
 // Helper functions
 
+// buildAnalysisConfig starts from the named profile, or the default configuration, and applies the given overrides.
+// It returns false when the profile is unknown.
+// formAnalysisConfig builds the analysis configuration from the profile,
+// languages, min_confidence and currency_codes form fields
+func formAnalysisConfig(c *gin.Context) (texttrack.TextDetectionConfig, bool) {
+	var minConfidence *float64
+	if minConfidenceStr := c.PostForm("min_confidence"); minConfidenceStr != "" {
+		if value, err := strconv.ParseFloat(minConfidenceStr, 64); err == nil {
+			minConfidence = &value
+		}
+	}
+
+	return buildAnalysisConfig(
+		c.PostForm("profile"),
+		splitFormList(c.PostForm("languages")),
+		minConfidence,
+		splitFormList(c.PostForm("currency_codes")),
+	)
+}
+
+func buildAnalysisConfig(profile string, languages []string, minConfidence *float64, currencyCodes []string) (texttrack.TextDetectionConfig, bool) {
+	config := texttrack.DefaultConfig()
+	if profile != "" {
+		var ok bool
+		if config, ok = texttrack.ConfigByName(profile); !ok {
+			return texttrack.TextDetectionConfig{}, false
+		}
+	}
+
+	if len(languages) > 0 {
+		config.Languages = languages
+	}
+	if minConfidence != nil && *minConfidence >= 0.0 && *minConfidence <= 1.0 {
+		config.MinConfidence = *minConfidence
+	}
+	if len(currencyCodes) > 0 {
+		config.CurrencyCodes = currencyCodes
+	}
+
+	return config, true
+}
+
+// billRequestIDs extracts the authenticated user and bill IDs, writing an error response on failure
+func billRequestIDs(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	// Get user ID from context (set by auth middleware)
+	userIDStr, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID, ok := userIDStr.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	billID, err := uuid.Parse(c.Param("bill_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, billID, true
+}
+
+// splitFormList parses a comma-separated form value
+func splitFormList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	items := strings.Split(value, ",")
+	for i, item := range items {
+		items[i] = strings.TrimSpace(item)
+	}
+	return items
+}
+
 func formatBillResponse(billWithURL *domain.BillWithURL) domain.BillDTO {
 	bill := billWithURL.Bill
 	response := domain.BillDTO{
@@ -438,9 +620,23 @@ func formatBillResponse(billWithURL *domain.BillWithURL) domain.BillDTO {
 		Status:          string(bill.Status),
 		UploadedAt:      bill.UploadedAt.Format(time.RFC3339),
 		FileURL:         billWithURL.FileURL,
+		ThumbnailURL:    billWithURL.ThumbnailURL,
+		PreviewURL:      billWithURL.PreviewURL,
 		VendorName:      safeString(bill.VendorName),
 		TotalAmount:     bill.TotalAmount,
+		SubtotalAmount:  bill.SubtotalAmount,
+		TaxAmount:       bill.TaxAmount,
+		TipAmount:       bill.TipAmount,
+		DiscountAmount:  bill.DiscountAmount,
 		TextTrackOutput: safeString(bill.TextTrackOutput),
+
+		ReconciliationStatus: string(bill.ReconciliationStatus),
+		Version:              bill.Version,
+		FieldSources:         formatFieldSources(bill.FieldSources()),
+		FieldDetections:      formatFieldDetections(bill.FieldDetections, bill.EditedFields),
+
+		AnalysisAttempts:  bill.AnalysisAttempts,
+		LastAnalysisError: bill.LastAnalysisError,
 	}
 
 	if bill.ProcessedAt != nil {
@@ -453,17 +649,103 @@ func formatBillResponse(billWithURL *domain.BillWithURL) domain.BillDTO {
 		response.TransactionDate = &transactionDate
 	}
 
+	if bill.GroupID != nil {
+		groupID := bill.GroupID.String()
+		response.GroupID = &groupID
+	}
+
+	if bill.PaidByMemberID != nil {
+		paidByMemberID := bill.PaidByMemberID.String()
+		response.PaidByMemberID = &paidByMemberID
+	}
+
+	if bill.BatchID != nil {
+		batchID := bill.BatchID.String()
+		response.BatchID = &batchID
+	}
+	if bill.DuplicateOfBillID != nil {
+		duplicateOfBillID := bill.DuplicateOfBillID.String()
+		response.DuplicateOfBillID = &duplicateOfBillID
+		response.DuplicateReason = string(bill.DuplicateReason)
+	}
+
+	for _, discrepancy := range bill.Discrepancies {
+		response.Discrepancies = append(response.Discrepancies, formatDiscrepancyResponse(discrepancy))
+	}
+
 	response.LineItems = make([]domain.LineItemDTO, len(bill.LineItems))
 	for i, item := range bill.LineItems {
-		response.LineItems[i] = domain.LineItemDTO{
-			ID:          item.ID.String(),
-			Description: item.Description,
-			Quantity:    item.Quantity,
-			UnitPrice:   item.UnitPrice,
-			TotalPrice:  item.TotalPrice,
+		response.LineItems[i] = formatLineItemResponse(item)
+	}
+
+	return response
+}
+
+func formatLineItemResponse(item domain.LineItem) domain.LineItemDTO {
+	response := domain.LineItemDTO{
+		ID:          item.ID.String(),
+		Description: item.Description,
+		Quantity:    item.Quantity,
+		UnitPrice:   item.UnitPrice,
+		TotalPrice:  item.TotalPrice,
+		PageNumber:  item.PageNumber,
+
+		Source:          string(item.Source),
+		FieldSources:    formatFieldSources(item.FieldSources()),
+		FieldDetections: formatFieldDetections(item.FieldDetections, item.EditedFields),
+	}
+
+	for _, assignment := range item.Assignments {
+		response.Assignments = append(response.Assignments, domain.LineItemAssignmentDTO{
+			MemberID: assignment.GroupMemberID.String(),
+			Units:    assignment.Units,
+		})
+	}
+
+	return response
+}
+
+func formatFieldSources(sources map[string]domain.FieldSource) map[string]string {
+	if len(sources) == 0 {
+		return nil
+	}
+	response := make(map[string]string, len(sources))
+	for field, source := range sources {
+		response[field] = string(source)
+	}
+	return response
+}
+
+// formatFieldDetections returns the detections of extracted fields. Values a
+// user entered don't need a review, whatever the confidence of what they replaced.
+func formatFieldDetections(detections domain.FieldDetections, edited domain.FieldNames) map[string]domain.FieldDetectionDTO {
+	if len(detections) == 0 {
+		return nil
+	}
+	formatted := make(map[string]domain.FieldDetectionDTO, len(detections))
+	for field, detection := range detections {
+		formatted[field] = domain.FieldDetectionDTO{
+			Confidence:  detection.Confidence,
+			PageNumber:  detection.PageNumber,
+			BoundingBox: detection.BoundingBox,
+			NeedsReview: detection.NeedsReview() && !edited.Contains(field),
 		}
 	}
+	return formatted
+}
 
+func formatDiscrepancyResponse(discrepancy domain.Discrepancy) domain.DiscrepancyDTO {
+	response := domain.DiscrepancyDTO{
+		Field:      discrepancy.Field,
+		Expected:   discrepancy.Expected,
+		Actual:     discrepancy.Actual,
+		Difference: discrepancy.Difference(),
+		Message:    discrepancy.Message,
+	}
+	if discrepancy.LineItemID != nil {
+		lineItemID := discrepancy.LineItemID.String()
+		response.LineItemID = &lineItemID
+	}
 	return response
 }
 
@@ -0,0 +1,327 @@
+package hanlders
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Resumable uploads follow the tus protocol (https://tus.io/protocols/resumable-upload)
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,expiration,termination"
+	tusContentType = "application/offset+octet-stream"
+)
+
+// billIDHeader names the header a complete resumable upload reports its bill in
+const billIDHeader = "Bill-ID"
+
+// GetResumableUploadOptions godoc
+// @Summary Describe the resumable upload server
+// @Description Report the tus version, extensions and largest file supported by the resumable upload endpoints.
+// @Tags Bills
+// @Success 204 "Tus-Version, Tus-Extension and Tus-Max-Size headers"
+// @Router /bills/uploads [options]
+func (h *BillHandler) GetResumableUploadOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	if h.limits.MaxFileBytes > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(h.limits.MaxFileBytes, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreateResumableUpload godoc
+// @Summary Start a resumable bill upload
+// @Description Start an upload the client sends in chunks and can resume after losing its connection, following the tus 1.0 protocol with the creation, expiration and termination extensions. Upload-Metadata must include the filename and filetype; profile, languages and currency_codes (comma-separated) set the analysis configuration. Uploads that receive no chunk for UPLOAD_RESUMABLE_TTL expire.
+// @Tags Bills
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Param Upload-Length header int true "Size of the file in bytes"
+// @Param Upload-Metadata header string true "Comma-separated keys with base64 values"
+// @Success 201 "Location of the upload, and Upload-Expires"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - missing or invalid Upload-Length or Upload-Metadata, or unknown profile"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 412 {object} gin.H{"error": string} "Precondition Failed - unsupported tus version"
+// @Failure 413 {object} gin.H{"error": string, "code": string} "Request Entity Too Large - file_too_large"
+// @Failure 415 {object} gin.H{"error": string, "code": string} "Unsupported Media Type - unsupported_file_type"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - upload staging or database error"
+// @Router /bills/uploads [post]
+func (h *BillHandler) CreateResumableUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+
+	userIDStr, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userID, ok := userIDStr.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Uploads of unknown length are not supported, send Upload-Length"})
+		return
+	}
+	size, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Length must be the size of the file in bytes"})
+		return
+	}
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	config, ok := buildAnalysisConfig(metadata["profile"], splitFormList(metadata["languages"]), nil, splitFormList(metadata["currency_codes"]))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown analysis profile, see /bills/analysis-configs"})
+		return
+	}
+
+	upload, err := h.billService.CreateResumableUpload(c, domain.ResumableUploadRequest{
+		UserID:      userID,
+		Filename:    metadata["filename"],
+		ContentType: metadata["filetype"],
+		Size:        size,
+		Metadata:    c.GetHeader("Upload-Metadata"),
+	}, h.limits.MaxFileBytes, config)
+	if err != nil {
+		if respondUploadError(c, err) {
+			return
+		}
+		if errors.Is(err, domain.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Metadata must include the filename"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start upload: " + err.Error()})
+		return
+	}
+
+	c.Header("Location", strings.TrimRight(c.Request.URL.Path, "/")+"/"+upload.ID.String())
+	setUploadExpires(c, upload)
+	c.Status(http.StatusCreated)
+}
+
+// GetResumableUploadOffset godoc
+// @Summary Get how much of a resumable upload was received
+// @Description Report the offset to resume an upload from. Complete uploads also report their bill in the Bill-ID header.
+// @Tags Bills
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Param upload_id path string true "UUID of the upload"
+// @Success 200 "Upload-Offset, Upload-Length, Upload-Metadata and Upload-Expires"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid upload ID"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - upload not found or not owned by user"
+// @Failure 410 {object} gin.H{"error": string} "Gone - the upload expired"
+// @Failure 412 {object} gin.H{"error": string} "Precondition Failed - unsupported tus version"
+// @Router /bills/uploads/{upload_id} [head]
+func (h *BillHandler) GetResumableUploadOffset(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	userID, uploadID, ok := resumableUploadRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	upload, err := h.billService.GetResumableUpload(c, uploadID, userID)
+	if err != nil {
+		respondResumableUploadError(c, err, "Failed to get upload")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.UploadedBytes, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	if upload.Metadata != "" {
+		c.Header("Upload-Metadata", upload.Metadata)
+	}
+	setUploadExpires(c, upload)
+	c.Status(http.StatusOK)
+}
+
+// WriteResumableUpload godoc
+// @Summary Upload a chunk of a resumable upload
+// @Description Append a chunk to an upload at Upload-Offset, the number of bytes already received. Bytes received before a connection drops are kept, so the client can resume from the offset reported by HEAD. Once the whole file is received it is checked like any upload and becomes a bill queued for analysis, reported in the Bill-ID header. A complete upload whose bill could not be created is retried with an empty chunk.
+// @Tags Bills
+// @Accept application/offset+octet-stream
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Param Upload-Offset header int true "Offset of the chunk in the file"
+// @Param upload_id path string true "UUID of the upload"
+// @Success 204 "Upload-Offset, Upload-Expires and, once complete, Bill-ID"
+// @Failure 400 {object} gin.H{"error": string, "code": string} "Bad Request - invalid upload ID or Upload-Offset, or empty_file"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - upload not found or not owned by user"
+// @Failure 409 {object} gin.H{"error": string, "code": string} "Conflict - Upload-Offset is not the number of bytes received, or duplicate_bill when DUPLICATE_POLICY is block"
+// @Failure 410 {object} gin.H{"error": string} "Gone - the upload expired"
+// @Failure 412 {object} gin.H{"error": string} "Precondition Failed - unsupported tus version"
+// @Failure 413 {object} gin.H{"error": string, "code": string} "Request Entity Too Large - file_too_large"
+// @Failure 415 {object} gin.H{"error": string, "code": string} "Unsupported Media Type - the chunk is not application/offset+octet-stream, or unsupported_file_type"
+// @Failure 422 {object} gin.H{"error": string, "code": string} "Unprocessable Entity - corrupt_file"
+// @Failure 423 {object} gin.H{"error": string} "Locked - another chunk of the upload is being received"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - upload staging, file storage, queue, or database error"
+// @Router /bills/uploads/{upload_id} [patch]
+func (h *BillHandler) WriteResumableUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	userID, uploadID, ok := resumableUploadRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	if c.ContentType() != tusContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Chunks must be sent as " + tusContentType})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Offset must be the number of bytes already uploaded"})
+		return
+	}
+
+	upload, billWithURL, err := h.billService.WriteResumableUpload(c, uploadID, userID, offset, c.Request.Body, h.limits.MaxFileBytes)
+	if upload != nil {
+		c.Header("Upload-Offset", strconv.FormatInt(upload.UploadedBytes, 10))
+	}
+	if err != nil {
+		if respondUploadError(c, err) {
+			return
+		}
+		respondResumableUploadError(c, err, "Failed to upload chunk")
+		return
+	}
+
+	setUploadExpires(c, upload)
+	if billWithURL != nil {
+		c.Header(billIDHeader, billWithURL.Bill.ID.String())
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteResumableUpload godoc
+// @Summary Cancel a resumable upload
+// @Description Discard an upload and the chunks received so far. The bill of a complete upload is kept.
+// @Tags Bills
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Param upload_id path string true "UUID of the upload"
+// @Success 204 "Upload discarded"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid upload ID"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - upload not found or not owned by user"
+// @Failure 412 {object} gin.H{"error": string} "Precondition Failed - unsupported tus version"
+// @Failure 423 {object} gin.H{"error": string} "Locked - a chunk of the upload is being received"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - upload staging or database error"
+// @Router /bills/uploads/{upload_id} [delete]
+func (h *BillHandler) DeleteResumableUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	userID, uploadID, ok := resumableUploadRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	if err := h.billService.DeleteResumableUpload(c, uploadID, userID); err != nil {
+		respondResumableUploadError(c, err, "Failed to delete upload")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// checkTusResumable sets the protocol version on the response and checks the
+// client speaks it, writing an error response otherwise
+func checkTusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Unsupported Tus-Resumable version, expected " + tusVersion})
+		return false
+	}
+	return true
+}
+
+// resumableUploadRequestIDs extracts the authenticated user and upload IDs, writing an error response on failure
+func resumableUploadRequestIDs(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userIDStr, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID, ok := userIDStr.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	uploadID, err := uuid.Parse(c.Param("upload_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, uploadID, true
+}
+
+// respondResumableUploadError replies to a failed resumable upload request,
+// prefixing unexpected errors with message
+func respondResumableUploadError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, domain.ErrResumableUploadNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+	case errors.Is(err, domain.ErrResumableUploadExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrUploadOffsetMismatch):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrResumableUploadLocked):
+		c.JSON(http.StatusLocked, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message + ": " + err.Error()})
+	}
+}
+
+// setUploadExpires reports when an incomplete upload expires, and the bill of a complete one
+func setUploadExpires(c *gin.Context, upload *domain.ResumableUpload) {
+	if upload.BillID != nil {
+		c.Header(billIDHeader, upload.BillID.String())
+	}
+	if !upload.IsComplete() {
+		c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// parseUploadMetadata decodes an Upload-Metadata header: comma-separated pairs
+// of a key and a base64 value, which may be left out
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, fmt.Errorf("invalid Upload-Metadata: empty key")
+		}
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata: value of %s is not base64", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
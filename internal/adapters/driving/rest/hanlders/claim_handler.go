@@ -0,0 +1,319 @@
+package hanlders
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/application"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ClaimHandler handles HTTP requests for collaborative line item claiming
+type ClaimHandler struct {
+	claimService     *application.ClaimService
+	shareLinkService *application.ShareLinkService // nil when guest links are disabled
+}
+
+// NewClaimHandler creates a new ClaimHandler. shareLinkService may be nil, in
+// which case guest claiming is unavailable.
+func NewClaimHandler(claimService *application.ClaimService, shareLinkService *application.ShareLinkService) *ClaimHandler {
+	if claimService == nil {
+		panic("ClaimService cannot be nil in NewClaimHandler")
+	}
+	return &ClaimHandler{claimService: claimService, shareLinkService: shareLinkService}
+}
+
+// OpenSession godoc
+// @Summary Open a bill for claiming
+// @Description Open a claim session on a group bill so members can pick the items they had. Over-claimed items are either split proportionally ("split", default) or flagged and must be fixed before finalizing ("flag").
+// @Tags Claims
+// @Accept json
+// @Produce json
+// @Param bill_id path string true "UUID of the bill"
+// @Param request body domain.OpenClaimSessionRequest false "Session options"
+// @Success 201 {object} domain.ClaimSessionDTO "Opened claim session"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid policy or bill not attached to a group"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - bill not found or not owned by user"
+// @Failure 409 {object} gin.H{"error": string} "Conflict - bill already has a claim session"
+// @Router /bills/{bill_id}/claims [post]
+func (h *ClaimHandler) OpenSession(c *gin.Context) {
+	userID, billID, ok := billRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req domain.OpenClaimSessionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+			return
+		}
+	}
+
+	view, err := h.claimService.OpenSession(c, billID, userID, req)
+	if err != nil {
+		respondClaimError(c, "Failed to open claim session", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, formatClaimSessionResponse(view))
+}
+
+// GetSession godoc
+// @Summary Get the claim session of a bill
+// @Description Return each line item with its available, claimed and unclaimed units and whether it is over-claimed. Available to the bill owner and to group members linked to the user.
+// @Tags Claims
+// @Produce json
+// @Param bill_id path string true "UUID of the bill"
+// @Success 200 {object} domain.ClaimSessionDTO "Claim session state"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - bill or claim session not found"
+// @Router /bills/{bill_id}/claims [get]
+func (h *ClaimHandler) GetSession(c *gin.Context) {
+	userID, billID, ok := billRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	view, err := h.claimService.GetSessionForUser(c, billID, userID)
+	if err != nil {
+		respondClaimError(c, "Failed to retrieve claim session", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatClaimSessionResponse(view))
+}
+
+// SubmitClaims godoc
+// @Summary Claim line items as the linked group member
+// @Description Replace the claims of the group member linked to the authenticated user. Units may be fractional to share an item.
+// @Tags Claims
+// @Accept json
+// @Produce json
+// @Param bill_id path string true "UUID of the bill"
+// @Param request body domain.SubmitClaimsRequest true "Claimed items"
+// @Success 200 {object} domain.ClaimSessionDTO "Updated claim session state"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid items or units"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 403 {object} gin.H{"error": string} "Forbidden - user is not linked to a member of the group"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - bill, claim session or line item not found"
+// @Failure 409 {object} gin.H{"error": string} "Conflict - claim session is finalized"
+// @Router /bills/{bill_id}/claims/me [put]
+func (h *ClaimHandler) SubmitClaims(c *gin.Context) {
+	userID, billID, ok := billRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req domain.SubmitClaimsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+		return
+	}
+
+	view, err := h.claimService.SubmitUserClaims(c, billID, userID, req)
+	if err != nil {
+		respondClaimError(c, "Failed to submit claims", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatClaimSessionResponse(view))
+}
+
+// FinalizeSession godoc
+// @Summary Finalize and lock a claim session
+// @Description Lock the session and turn the claims into line item assignments. Unclaimed leftover units are spread evenly across all members. Only the bill owner can finalize.
+// @Tags Claims
+// @Produce json
+// @Param bill_id path string true "UUID of the bill"
+// @Success 200 {object} domain.ClaimSessionDTO "Finalized claim session"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - bill or claim session not found"
+// @Failure 409 {object} gin.H{"error": string} "Conflict - session already finalized or items over-claimed under the flag policy"
+// @Router /bills/{bill_id}/claims/finalize [post]
+func (h *ClaimHandler) FinalizeSession(c *gin.Context) {
+	userID, billID, ok := billRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	view, err := h.claimService.FinalizeSession(c, billID, userID)
+	if err != nil {
+		respondClaimError(c, "Failed to finalize claim session", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatClaimSessionResponse(view))
+}
+
+// GetGuestSession godoc
+// @Summary Get a claim session through a guest link
+// @Description Public endpoint for members without the app. Access is granted by the signed share link token.
+// @Tags Share
+// @Produce json
+// @Param token path string true "Signed share link token"
+// @Param bill_id path string true "UUID of the bill"
+// @Success 200 {object} domain.ClaimSessionDTO "Claim session state"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - invalid token, bill or claim session not found"
+// @Failure 410 {object} gin.H{"error": string} "Gone - link expired"
+// @Router /share/{token}/bills/{bill_id}/claims [get]
+func (h *ClaimHandler) GetGuestSession(c *gin.Context) {
+	claims, billID, ok := h.guestRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	view, err := h.claimService.GetSessionForMember(c, billID, claims.GroupID, claims.MemberID)
+	if err != nil {
+		respondClaimError(c, "Failed to retrieve claim session", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatClaimSessionResponse(view))
+}
+
+// SubmitGuestClaims godoc
+// @Summary Claim line items through a guest link
+// @Description Replace the claims of the member the share link was issued for.
+// @Tags Share
+// @Accept json
+// @Produce json
+// @Param token path string true "Signed share link token"
+// @Param bill_id path string true "UUID of the bill"
+// @Param request body domain.SubmitClaimsRequest true "Claimed items"
+// @Success 200 {object} domain.ClaimSessionDTO "Updated claim session state"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid items or units"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - invalid token, bill or claim session not found"
+// @Failure 409 {object} gin.H{"error": string} "Conflict - claim session is finalized"
+// @Failure 410 {object} gin.H{"error": string} "Gone - link expired"
+// @Router /share/{token}/bills/{bill_id}/claims [put]
+func (h *ClaimHandler) SubmitGuestClaims(c *gin.Context) {
+	claims, billID, ok := h.guestRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req domain.SubmitClaimsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+		return
+	}
+
+	view, err := h.claimService.SubmitMemberClaims(c, billID, claims.GroupID, claims.MemberID, req)
+	if err != nil {
+		respondClaimError(c, "Failed to submit claims", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatClaimSessionResponse(view))
+}
+
+// guestRequestIDs verifies the share link token and extracts the bill ID, writing an error response on failure
+func (h *ClaimHandler) guestRequestIDs(c *gin.Context) (*domain.ShareLinkClaims, uuid.UUID, bool) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+
+	if h.shareLinkService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": domain.ErrShareLinkDisabled.Error()})
+		return nil, uuid.Nil, false
+	}
+
+	claims, err := h.shareLinkService.VerifyLink(c.Param("token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrShareLinkExpired):
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		case errors.Is(err, domain.ErrShareLinkDisabled):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		}
+		return nil, uuid.Nil, false
+	}
+
+	billID, err := uuid.Parse(c.Param("bill_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID format"})
+		return nil, uuid.Nil, false
+	}
+
+	return claims, billID, true
+}
+
+// respondClaimError maps claim errors to HTTP responses
+func respondClaimError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, domain.ErrBillNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+	case errors.Is(err, domain.ErrClaimSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Claim session not found"})
+	case errors.Is(err, domain.ErrLineItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Line item not found"})
+	case errors.Is(err, domain.ErrGroupNotFound),
+		errors.Is(err, domain.ErrGroupMemberNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group member not found"})
+	case errors.Is(err, domain.ErrNotGroupMember):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrClaimSessionExists),
+		errors.Is(err, domain.ErrClaimSessionLocked),
+		errors.Is(err, domain.ErrClaimsOverClaimed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidInput),
+		errors.Is(err, domain.ErrInvalidAssignmentUnits),
+		errors.Is(err, domain.ErrClaimExceedsQuantity),
+		errors.Is(err, domain.ErrBillNotInGroup):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message + ": " + err.Error()})
+	}
+}
+
+func formatClaimSessionResponse(view *application.ClaimSessionView) domain.ClaimSessionDTO {
+	session := view.Session
+	response := domain.ClaimSessionDTO{
+		ID:              session.ID.String(),
+		BillID:          session.BillID.String(),
+		GroupID:         session.GroupID.String(),
+		Status:          string(session.Status),
+		OverClaimPolicy: string(session.OverClaimPolicy),
+		CreatedAt:       session.CreatedAt.Format(time.RFC3339),
+		Items:           []domain.ItemClaimStateDTO{},
+	}
+
+	if session.FinalizedAt != nil {
+		finalizedAt := session.FinalizedAt.Format(time.RFC3339)
+		response.FinalizedAt = &finalizedAt
+	}
+
+	memberNames := make(map[uuid.UUID]string, len(view.Group.Members))
+	for _, member := range view.Group.Members {
+		memberNames[member.ID] = member.Name
+	}
+
+	for _, state := range session.Summarize(view.Bill) {
+		item := domain.ItemClaimStateDTO{
+			LineItemID:     state.LineItem.ID.String(),
+			Description:    state.LineItem.Description,
+			TotalPrice:     state.LineItem.Amount(),
+			AvailableUnits: state.AvailableUnits,
+			ClaimedUnits:   state.ClaimedUnits,
+			UnclaimedUnits: state.UnclaimedUnits,
+			OverClaimed:    state.OverClaimed,
+			Claims:         make([]domain.MemberClaimDTO, len(state.Claims)),
+		}
+		for i, claim := range state.Claims {
+			item.Claims[i] = domain.MemberClaimDTO{
+				MemberID:   claim.GroupMemberID.String(),
+				MemberName: memberNames[claim.GroupMemberID],
+				Units:      claim.Units,
+			}
+		}
+		response.Items = append(response.Items, item)
+	}
+
+	return response
+}
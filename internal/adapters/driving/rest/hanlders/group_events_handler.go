@@ -0,0 +1,186 @@
+package hanlders
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/application"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 50 * time.Second // must be shorter than wsPongTimeout
+)
+
+var groupEventsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Any origin may connect: the connection is authorized by the Firebase token, not by cookies
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// GroupEventsHandler streams live group changes over WebSocket
+type GroupEventsHandler struct {
+	groupEvents *application.GroupEventService
+}
+
+// NewGroupEventsHandler creates a new GroupEventsHandler
+func NewGroupEventsHandler(groupEvents *application.GroupEventService) *GroupEventsHandler {
+	if groupEvents == nil {
+		panic("GroupEventService cannot be nil in NewGroupEventsHandler")
+	}
+	return &GroupEventsHandler{groupEvents: groupEvents}
+}
+
+// StreamGroupEvents godoc
+// @Summary Follow the changes of a group over WebSocket
+// @Description Open a WebSocket that receives a JSON message for every change to the group: expenses added, assignments, claims and group updates. Browsers that can't set the Authorization header may pass the Firebase ID token in the access_token query parameter. After a reconnect, pass the ID of the last event received as last_event_id to get the missed events first. The group owner and members linked to the user can connect.
+// @Tags Groups
+// @Param group_id path string true "UUID of the group"
+// @Param last_event_id query int false "ID of the last event received before reconnecting"
+// @Param access_token query string false "Firebase ID token, when the Authorization header can't be set"
+// @Success 101 {object} domain.GroupEventDTO "Switching protocols, then one message per group event"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid group ID or last event ID"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - group not found or not visible to the user"
+// @Router /groups/{group_id}/ws [get]
+func (h *GroupEventsHandler) StreamGroupEvents(c *gin.Context) {
+	userIDStr, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userID, ok := userIDStr.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID format"})
+		return
+	}
+
+	var lastEventID int64
+	if value := c.Query("last_event_id"); value != "" {
+		lastEventID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || lastEventID < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid last event ID"})
+			return
+		}
+	}
+
+	subscription, err := h.groupEvents.Subscribe(c, groupID, userID, lastEventID)
+	if err != nil {
+		if errors.Is(err, domain.ErrGroupNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe to group events: " + err.Error()})
+		return
+	}
+	defer subscription.Cancel()
+
+	conn, err := groupEventsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade already replied with an error
+		log.Printf("Group events: WebSocket upgrade failed for group %s: %v", groupID, err)
+		return
+	}
+	defer conn.Close()
+
+	// Clients only send control frames; reading handles pongs and notices when they leave
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	lastSentID := lastEventID
+	send := func(event *domain.GroupEvent) bool {
+		// Live events may repeat the missed ones, and deletions carry no ID
+		if event.ID != 0 && event.ID <= lastSentID {
+			return true
+		}
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := conn.WriteJSON(formatGroupEventResponse(event)); err != nil {
+			return false
+		}
+		if event.ID > lastSentID {
+			lastSentID = event.ID
+		}
+		return true
+	}
+
+	for _, event := range subscription.Missed {
+		if !send(event) {
+			return
+		}
+	}
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				// The server is shutting down
+				closeWebSocket(conn, websocket.CloseGoingAway, "server shutting down")
+				return
+			}
+			if !send(event) {
+				return
+			}
+			if event.Type == domain.GroupEventGroupDeleted {
+				closeWebSocket(conn, websocket.CloseNormalClosure, "group deleted")
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func closeWebSocket(conn *websocket.Conn, code int, reason string) {
+	message := websocket.FormatCloseMessage(code, reason)
+	conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(wsWriteTimeout))
+}
+
+func formatGroupEventResponse(event *domain.GroupEvent) domain.GroupEventDTO {
+	response := domain.GroupEventDTO{
+		ID:        event.ID,
+		GroupID:   event.GroupID.String(),
+		Type:      string(event.Type),
+		Payload:   event.Payload,
+		CreatedAt: event.CreatedAt.Format(time.RFC3339),
+	}
+	if event.ActorUserID != nil {
+		actorUserID := event.ActorUserID.String()
+		response.ActorUserID = &actorUserID
+	}
+	return response
+}
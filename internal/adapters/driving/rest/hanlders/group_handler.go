@@ -1,6 +1,7 @@
 package hanlders
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -281,6 +282,74 @@ func (h *GroupHandler) DeleteGroup(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Group deleted successfully"})
 }
 
+// LinkMember godoc
+// @Summary Link a group member to a registered user
+// @Description Link a member of the group to the registered user with the given email, so they can claim items and see group bills from the app. Only the group owner can link members.
+// @Tags Groups
+// @Accept json
+// @Produce json
+// @Param group_id path string true "UUID of the group"
+// @Param member_id path string true "UUID of the group member"
+// @Param request body domain.LinkMemberRequest true "Email of the registered user"
+// @Success 200 {object} domain.GroupDTO "Group with the linked member"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid IDs or email"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - group, member or user not found"
+// @Failure 409 {object} gin.H{"error": string} "Conflict - user already linked to another member"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - database error"
+// @Router /groups/{group_id}/members/{member_id}/user [put]
+func (h *GroupHandler) LinkMember(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userIDStr, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userID, ok := userIDStr.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID format"})
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("member_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid member ID format"})
+		return
+	}
+
+	var req domain.LinkMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+		return
+	}
+
+	group, err := h.groupService.LinkMember(c, groupID, memberID, userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrGroupNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		case errors.Is(err, domain.ErrGroupMemberNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group member not found"})
+		case errors.Is(err, domain.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case errors.Is(err, domain.ErrMemberAlreadyLinked):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link group member: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, formatGroupResponse(group))
+}
+
 // Helper function to format group response
 func formatGroupResponse(group *domain.Group) domain.GroupDTO {
 	response := domain.GroupDTO{
@@ -299,6 +368,10 @@ func formatGroupResponse(group *domain.Group) domain.GroupDTO {
 			Name:      member.Name,
 			CreatedAt: member.CreatedAt.Format(time.RFC3339),
 		}
+		if member.UserID != nil {
+			userID := member.UserID.String()
+			response.Members[i].UserID = &userID
+		}
 	}
 
 	return response
@@ -0,0 +1,250 @@
+package hanlders
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/application"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// guestShareTemplate renders the read-only page served to guest share links.
+var guestShareTemplate = template.Must(template.New("guest_share").Parse(`<!DOCTYPE html>
+<html lang="es">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.GroupName}} · {{.MemberName}}</title>
+<style>
+body{font-family:system-ui,sans-serif;max-width:40rem;margin:1rem auto;padding:0 1rem;color:#222}
+table{width:100%;border-collapse:collapse;margin-bottom:1rem}
+td,th{padding:.25rem;border-bottom:1px solid #ddd;text-align:left}
+td.amount,th.amount{text-align:right}
+.shared{color:#777}
+</style>
+</head>
+<body>
+<h1>{{.MemberName}}</h1>
+<p>{{.GroupName}}</p>
+<p>Paid: <strong>{{printf "%.2f" .TotalPaid}}</strong> · Owes: <strong>{{printf "%.2f" .TotalOwed}}</strong> · Balance: <strong>{{printf "%.2f" .Balance}}</strong></p>
+{{if .PaysTo}}<h2>Pay</h2>
+<ul>{{range .PaysTo}}<li>{{.MemberName}}: {{printf "%.2f" .Amount}}</li>{{end}}</ul>{{end}}
+{{if .ReceivesFrom}}<h2>Receive</h2>
+<ul>{{range .ReceivesFrom}}<li>{{.MemberName}}: {{printf "%.2f" .Amount}}</li>{{end}}</ul>{{end}}
+<h2>Bills</h2>
+{{range .Bills}}<h3>{{if .VendorName}}{{.VendorName}}{{else}}Bill{{end}}{{if .TransactionDate}} · {{.TransactionDate}}{{end}}</h3>
+<p>Total {{printf "%.2f" .BillTotal}} · paid by {{.PaidBy}}</p>
+<table>
+<tr><th>Item</th><th class="amount">Units</th><th class="amount">Amount</th></tr>
+{{range .Items}}<tr{{if .Shared}} class="shared"{{end}}><td>{{.Description}}</td><td class="amount">{{printf "%.2f" .Units}}</td><td class="amount">{{printf "%.2f" .Amount}}</td></tr>
+{{end}}<tr><th>Your share</th><th></th><th class="amount">{{printf "%.2f" .Amount}}</th></tr>
+</table>
+{{else}}<p>No bills yet.</p>{{end}}
+<p><small>Link valid until {{.ExpiresAt}}</small></p>
+</body>
+</html>
+`))
+
+// ShareHandler handles HTTP requests for guest share links
+type ShareHandler struct {
+	shareLinkService *application.ShareLinkService
+}
+
+// NewShareHandler creates a new ShareHandler
+func NewShareHandler(shareLinkService *application.ShareLinkService) *ShareHandler {
+	if shareLinkService == nil {
+		panic("ShareLinkService cannot be nil in NewShareHandler")
+	}
+	return &ShareHandler{shareLinkService: shareLinkService}
+}
+
+// CreateShareLink godoc
+// @Summary Create a guest share link for a group member
+// @Description Generate a signed, expiring, read-only link that shows a member's share of each group bill, their balance and who they should pay. Only the group owner can create links.
+// @Tags Groups
+// @Produce json
+// @Param group_id path string true "UUID of the group"
+// @Param member_id path string true "UUID of the group member"
+// @Success 201 {object} domain.ShareLinkDTO "Signed share link"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid group or member ID format"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - group or member not found"
+// @Failure 503 {object} gin.H{"error": string} "Service Unavailable - share links are not configured"
+// @Router /groups/{group_id}/members/{member_id}/share-links [post]
+func (h *ShareHandler) CreateShareLink(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userIDStr, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userID, ok := userIDStr.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID format"})
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("member_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid member ID format"})
+		return
+	}
+
+	link, err := h.shareLinkService.CreateLink(c, groupID, memberID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrGroupNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		case errors.Is(err, domain.ErrGroupMemberNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group member not found"})
+		case errors.Is(err, domain.ErrShareLinkDisabled):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, link)
+}
+
+// GetGuestShare godoc
+// @Summary View a member's shares through a guest link
+// @Description Public, read-only endpoint that returns the member's share of each group bill, their balance and the payments needed to settle up. No authentication required; access is granted by the signed token.
+// @Tags Share
+// @Produce json
+// @Param token path string true "Signed share link token"
+// @Success 200 {object} domain.GuestShareDTO "Member shares and balance"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - invalid token"
+// @Failure 410 {object} gin.H{"error": string} "Gone - link expired"
+// @Router /share/{token} [get]
+func (h *ShareHandler) GetGuestShare(c *gin.Context) {
+	share, ok := h.resolveGuestShare(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, share)
+}
+
+// ViewGuestShare godoc
+// @Summary Render a member's shares as an HTML page
+// @Description Public, read-only, server-rendered page for members who do not use the app.
+// @Tags Share
+// @Produce html
+// @Param token path string true "Signed share link token"
+// @Success 200 {string} string "HTML page"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - invalid token"
+// @Failure 410 {object} gin.H{"error": string} "Gone - link expired"
+// @Router /share/{token}/view [get]
+func (h *ShareHandler) ViewGuestShare(c *gin.Context) {
+	share, ok := h.resolveGuestShare(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := guestShareTemplate.Execute(c.Writer, share); err != nil {
+		log.Printf("Error rendering guest share page: %v", err)
+	}
+}
+
+// resolveGuestShare verifies the token and writes an error response when it is not valid
+func (h *ShareHandler) resolveGuestShare(c *gin.Context) (*domain.GuestShareDTO, bool) {
+	// The token grants access on its own, so keep it out of caches and referrers
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Robots-Tag", "noindex")
+
+	ledger, claims, err := h.shareLinkService.ResolveLink(c, c.Param("token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrShareLinkInvalid):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		case errors.Is(err, domain.ErrShareLinkExpired):
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		case errors.Is(err, domain.ErrShareLinkDisabled):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load share link"})
+		}
+		return nil, false
+	}
+
+	return formatGuestShareResponse(ledger, claims), true
+}
+
+// formatGuestShareResponse builds the guest view of a single member from the group ledger
+func formatGuestShareResponse(ledger *domain.GroupLedger, claims *domain.ShareLinkClaims) *domain.GuestShareDTO {
+	memberNames := make(map[uuid.UUID]string, len(ledger.Group.Members))
+	for _, member := range ledger.Group.Members {
+		memberNames[member.ID] = member.Name
+	}
+
+	balance := ledger.Balances[claims.MemberID]
+	response := &domain.GuestShareDTO{
+		GroupName:    ledger.Group.Name,
+		MemberID:     claims.MemberID.String(),
+		MemberName:   memberNames[claims.MemberID],
+		Bills:        []domain.GuestBillShareDTO{},
+		TotalPaid:    balance.Paid,
+		TotalOwed:    balance.Owed,
+		Balance:      balance.Net(),
+		PaysTo:       []domain.GuestTransferDTO{},
+		ReceivesFrom: []domain.GuestTransferDTO{},
+		ExpiresAt:    claims.ExpiresAt.Format(time.RFC3339),
+	}
+
+	for _, share := range ledger.Shares[claims.MemberID] {
+		billShare := domain.GuestBillShareDTO{
+			BillID:     share.BillID.String(),
+			VendorName: share.VendorName,
+			BillTotal:  share.BillTotal,
+			PaidBy:     memberNames[share.PaidByMemberID],
+			Items:      make([]domain.GuestItemShareDTO, len(share.Items)),
+			Amount:     share.Amount,
+		}
+		if share.TransactionDate != nil {
+			transactionDate := share.TransactionDate.Format("2006-01-02")
+			billShare.TransactionDate = &transactionDate
+		}
+		for i, item := range share.Items {
+			billShare.Items[i] = domain.GuestItemShareDTO{
+				Description: item.Description,
+				Units:       item.Units,
+				Amount:      item.Amount,
+				Shared:      item.Shared,
+			}
+		}
+		response.Bills = append(response.Bills, billShare)
+	}
+
+	for _, transfer := range ledger.TransfersFrom(claims.MemberID) {
+		response.PaysTo = append(response.PaysTo, domain.GuestTransferDTO{
+			MemberName: memberNames[transfer.ToMemberID],
+			Amount:     transfer.Amount,
+		})
+	}
+	for _, transfer := range ledger.TransfersTo(claims.MemberID) {
+		response.ReceivesFrom = append(response.ReceivesFrom, domain.GuestTransferDTO{
+			MemberName: memberNames[transfer.FromMemberID],
+			Amount:     transfer.Amount,
+		})
+	}
+
+	return response
+}
@@ -0,0 +1,102 @@
+package hanlders
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+	"github.com/gin-gonic/gin"
+)
+
+// SignedFileHandler serves downloads and direct uploads of bill files through
+// the signed URLs of file stores that don't have their own, like the local
+// filesystem store
+type SignedFileHandler struct {
+	files ports.SignedFileServer
+}
+
+// NewSignedFileHandler creates a new SignedFileHandler
+func NewSignedFileHandler(files ports.SignedFileServer) *SignedFileHandler {
+	if files == nil {
+		panic("SignedFileServer cannot be nil in NewSignedFileHandler")
+	}
+	return &SignedFileHandler{files: files}
+}
+
+// DownloadFile godoc
+// @Summary Download a stored bill file
+// @Description Serve a stored bill file through the signed, expiring URL returned as file_url. The URL itself authorizes the download, no token is needed.
+// @Tags Files
+// @Produce octet-stream
+// @Param key path string true "Storage key of the file"
+// @Param expires query int true "Unix time the URL expires at"
+// @Param signature query string true "Signature of the URL"
+// @Success 200 {file} file "The file"
+// @Failure 403 {object} gin.H{"error": string} "Forbidden - invalid or expired URL"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - file not found"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - storage error"
+// @Router /files/{key} [get]
+func (h *SignedFileHandler) DownloadFile(c *gin.Context) {
+	file, err := h.files.OpenSignedFile(c, fileSignature(c))
+	if err != nil {
+		respondSignedFileError(c, err)
+		return
+	}
+	defer file.Content.Close()
+
+	c.Header("Content-Type", file.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": file.Filename}))
+	// The link is only valid for a while, so it shouldn't be cached longer by shared caches
+	c.Header("Cache-Control", "private")
+	http.ServeContent(c.Writer, c.Request, file.Filename, file.ModTime, file.Content)
+}
+
+// UploadFile godoc
+// @Summary Upload a bill file directly
+// @Description Store the file of a bill created with /bills/upload-url, through the signed, expiring upload_url it returned. The URL itself authorizes the upload, no token is needed. The body is the file, of exactly the size declared when the URL was created. Call /bills/{bill_id}/complete afterwards.
+// @Tags Files
+// @Accept octet-stream
+// @Param key path string true "Storage key of the file"
+// @Param expires query int true "Unix time the URL expires at"
+// @Param size query int true "Size of the file in bytes"
+// @Param signature query string true "Signature of the URL"
+// @Success 204 "File stored"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - the file doesn't have the declared size"
+// @Failure 403 {object} gin.H{"error": string} "Forbidden - invalid or expired URL"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - storage error"
+// @Router /files/{key} [put]
+func (h *SignedFileHandler) UploadFile(c *gin.Context) {
+	if err := h.files.StoreSignedFile(c, fileSignature(c), c.Request.Body); err != nil {
+		respondSignedFileError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// fileSignature reads the key and signature of a signed file URL
+func fileSignature(c *gin.Context) ports.FileSignature {
+	return ports.FileSignature{
+		Key:       strings.TrimPrefix(c.Param("key"), "/"),
+		Expires:   c.Query("expires"),
+		Size:      c.Query("size"),
+		Signature: c.Query("signature"),
+	}
+}
+
+func respondSignedFileError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrFileLinkInvalid):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid file link"})
+	case errors.Is(err, domain.ErrFileLinkExpired):
+		c.JSON(http.StatusForbidden, gin.H{"error": "File link has expired"})
+	case errors.Is(err, domain.ErrStoredFileNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+	case errors.Is(err, domain.ErrInvalidInput):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to access file: " + err.Error()})
+	}
+}
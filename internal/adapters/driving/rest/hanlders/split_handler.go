@@ -0,0 +1,213 @@
+package hanlders
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/application"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SplitHandler handles HTTP requests for splitting bills among group members
+type SplitHandler struct {
+	splitService *application.SplitService
+}
+
+// NewSplitHandler creates a new SplitHandler
+func NewSplitHandler(splitService *application.SplitService) *SplitHandler {
+	if splitService == nil {
+		panic("SplitService cannot be nil in NewSplitHandler")
+	}
+	return &SplitHandler{splitService: splitService}
+}
+
+// AttachBillToGroup godoc
+// @Summary Attach a bill to a group
+// @Description Link a bill to one of the user's groups and record which member paid it. Line items can then be assigned to group members.
+// @Tags Bills
+// @Accept json
+// @Produce json
+// @Param bill_id path string true "UUID of the bill"
+// @Param request body domain.AttachBillToGroupRequest true "Group and payer"
+// @Success 200 {object} gin.H{"bill_id": string, "group_id": string, "paid_by_member_id": string} "Bill attached to group"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid IDs or payer is not a group member"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - bill or group not found or not owned by user"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - database error"
+// @Router /bills/{bill_id}/group [put]
+func (h *SplitHandler) AttachBillToGroup(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userIDStr, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userID, ok := userIDStr.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	billID, err := uuid.Parse(c.Param("bill_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID format"})
+		return
+	}
+
+	var req domain.AttachBillToGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+		return
+	}
+
+	bill, err := h.splitService.AttachBillToGroup(c, billID, userID, req)
+	if err != nil {
+		respondSplitError(c, "Failed to attach bill to group", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bill_id":           bill.ID.String(),
+		"group_id":          bill.GroupID.String(),
+		"paid_by_member_id": bill.PaidByMemberID.String(),
+	})
+}
+
+// AssignLineItem godoc
+// @Summary Assign a line item to group members
+// @Description Replace the member assignments of a line item on a group bill. Units default to 1; items without assignments are split evenly among all members.
+// @Tags Bills
+// @Accept json
+// @Produce json
+// @Param bill_id path string true "UUID of the bill"
+// @Param line_item_id path string true "UUID of the line item"
+// @Param request body domain.AssignLineItemRequest true "Member assignments"
+// @Success 200 {object} domain.LineItemDTO "Line item with its assignments"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid IDs, units, or bill not attached to a group"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - bill, line item or member not found"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - database error"
+// @Router /bills/{bill_id}/line-items/{line_item_id}/assignments [put]
+func (h *SplitHandler) AssignLineItem(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userIDStr, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userID, ok := userIDStr.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	billID, err := uuid.Parse(c.Param("bill_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID format"})
+		return
+	}
+
+	lineItemID, err := uuid.Parse(c.Param("line_item_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid line item ID format"})
+		return
+	}
+
+	var req domain.AssignLineItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+		return
+	}
+
+	lineItem, err := h.splitService.AssignLineItem(c, billID, lineItemID, userID, req)
+	if err != nil {
+		respondSplitError(c, "Failed to assign line item", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatLineItemResponse(*lineItem))
+}
+
+// respondSplitError maps split errors to HTTP responses
+// RecordSettlement godoc
+// @Summary Record a settlement between group members
+// @Description Record that a group member paid another one to settle up. The payment counts towards both members' balances and is published to the group as a settlement.recorded event.
+// @Tags Groups
+// @Accept json
+// @Produce json
+// @Param group_id path string true "UUID of the group"
+// @Param request body domain.RecordSettlementRequest true "Payer, payee and amount"
+// @Success 201 {object} domain.SettlementDTO "Settlement recorded"
+// @Failure 400 {object} gin.H{"error": string} "Bad Request - invalid IDs, non-positive amount or same payer and payee"
+// @Failure 401 {object} gin.H{"error": string} "Unauthorized - invalid or missing authentication token"
+// @Failure 404 {object} gin.H{"error": string} "Not Found - group or member not found"
+// @Failure 500 {object} gin.H{"error": string} "Internal Server Error - database error"
+// @Router /groups/{group_id}/settlements [post]
+func (h *SplitHandler) RecordSettlement(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userIDStr, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userID, ok := userIDStr.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID format"})
+		return
+	}
+
+	var req domain.RecordSettlementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+		return
+	}
+
+	settlement, err := h.splitService.RecordSettlement(c, groupID, userID, req)
+	if err != nil {
+		respondSplitError(c, "Failed to record settlement", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, domain.SettlementDTO{
+		ID:           settlement.ID.String(),
+		GroupID:      settlement.GroupID.String(),
+		FromMemberID: settlement.FromMemberID.String(),
+		ToMemberID:   settlement.ToMemberID.String(),
+		Amount:       settlement.Amount,
+		Note:         settlement.Note,
+		CreatedAt:    settlement.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+func respondSplitError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, domain.ErrBillNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+	case errors.Is(err, domain.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+	case errors.Is(err, domain.ErrLineItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Line item not found"})
+	case errors.Is(err, domain.ErrGroupMemberNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group member not found"})
+	case errors.Is(err, domain.ErrInvalidInput),
+		errors.Is(err, domain.ErrInvalidAssignmentUnits),
+		errors.Is(err, domain.ErrInvalidSettlementAmount),
+		errors.Is(err, domain.ErrSettlementSameMember),
+		errors.Is(err, domain.ErrBillNotInGroup):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message + ": " + err.Error()})
+	}
+}
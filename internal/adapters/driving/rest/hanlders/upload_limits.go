@@ -0,0 +1,96 @@
+package hanlders
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// UploadLimits caps the size of uploaded bill files
+type UploadLimits struct {
+	MaxFileBytes    int64 // largest file accepted
+	MaxRequestBytes int64 // largest upload request, all files included
+}
+
+// limitRequestBody stops reading the request body past the request limit
+func (l UploadLimits) limitRequestBody(c *gin.Context) {
+	if l.MaxRequestBytes > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, l.MaxRequestBytes)
+	}
+}
+
+// limitFile fails reads of a file past the file limit with domain.ErrFileTooLarge.
+// Unlike the size in a multipart header, this also holds for extracted archive entries.
+func (l UploadLimits) limitFile(file io.Reader) io.Reader {
+	if l.MaxFileBytes <= 0 {
+		return file
+	}
+	return &limitedFile{file: file, remaining: l.MaxFileBytes}
+}
+
+type limitedFile struct {
+	file      io.Reader
+	remaining int64
+}
+
+func (f *limitedFile) Read(p []byte) (int, error) {
+	if f.remaining < 0 {
+		return 0, domain.ErrFileTooLarge
+	}
+	// Read one byte more than allowed to tell a file of exactly the limit from a larger one
+	if int64(len(p)) > f.remaining+1 {
+		p = p[:f.remaining+1]
+	}
+	n, err := f.file.Read(p)
+	f.remaining -= int64(n)
+	if f.remaining < 0 {
+		return 0, domain.ErrFileTooLarge
+	}
+	return n, err
+}
+
+// formFileError converts an error reading an uploaded file from the request
+// into an upload validation error
+func formFileError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return domain.ErrRequestTooLarge
+	case errors.Is(err, http.ErrMissingFile):
+		return domain.ErrMissingFile
+	}
+	return err
+}
+
+// respondUploadError replies to an upload that failed validation with the error
+// and its code, and reports whether err was such an error
+func respondUploadError(c *gin.Context, err error) bool {
+	code := domain.UploadErrorCode(err)
+	if code == "" {
+		return false
+	}
+
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, domain.ErrFileTooLarge), errors.Is(err, domain.ErrRequestTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, domain.ErrUnsupportedFileType):
+		status = http.StatusUnsupportedMediaType
+	case errors.Is(err, domain.ErrCorruptFile):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, domain.ErrDuplicateBill):
+		status = http.StatusConflict
+	}
+
+	response := gin.H{"error": err.Error(), "code": code}
+	var duplicateErr *domain.DuplicateBillError
+	if errors.As(err, &duplicateErr) {
+		response["duplicate_of_bill_id"] = duplicateErr.BillID.String()
+		response["duplicate_reason"] = string(duplicateErr.Reason)
+	}
+	c.JSON(status, response)
+	return true
+}
@@ -17,15 +17,21 @@ const UserContextKey string = "authenticatedUser"
 
 // AuthenticatedUser holds information about the verified user.
 type AuthenticatedUser struct {
-	UID string // Firebase User ID
+	UID   string // Firebase User ID
+	Admin bool   // set by the "admin" custom claim
 	// Email string
 	// Name string
 }
 
 // FirebaseAuthMiddleware creates a Gin middleware handler that verifies Firebase ID tokens.
+// Browsers can't set headers on WebSocket connections, so WebSocket upgrade
+// requests may pass the token in the access_token query parameter instead.
 func FirebaseAuthMiddleware(authClient *auth.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" && isWebSocketUpgrade(c) && c.Query("access_token") != "" {
+			authHeader = "Bearer " + c.Query("access_token")
+		}
 		if authHeader == "" {
 			log.Println("Auth Middleware: Missing Authorization header")
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing Authorization header"})
@@ -47,8 +53,10 @@ func FirebaseAuthMiddleware(authClient *auth.Client) gin.HandlerFunc {
 			return
 		}
 
+		isAdmin, _ := token.Claims["admin"].(bool)
 		authUser := AuthenticatedUser{
-			UID: token.UID,
+			UID:   token.UID,
+			Admin: isAdmin,
 		}
 
 		c.Set(UserContextKey, authUser)
@@ -77,6 +85,28 @@ func UserLookupMiddleware(userService *application.UserService) gin.HandlerFunc
 	}
 }
 
+func isWebSocketUpgrade(c *gin.Context) bool {
+	return strings.EqualFold(c.GetHeader("Upgrade"), "websocket")
+}
+
+// AdminOnlyMiddleware rejects requests from users without the "admin" custom claim.
+// It must run after FirebaseAuthMiddleware.
+func AdminOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authUser, exists := GetUserFromGinContext(c)
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		if !authUser.Admin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // GetUserFromGinContext retrieves the authenticated user from the Gin request context.
 func GetUserFromGinContext(c *gin.Context) (AuthenticatedUser, bool) {
 	user, exists := c.Get(UserContextKey)
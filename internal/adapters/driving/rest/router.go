@@ -4,6 +4,7 @@ import (
 	"log"
 
 	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driving/rest/hanlders"
+	middleware "github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driving/rest/middlewares"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,6 +15,13 @@ func SetupAppRoutes(
 	userHandler *hanlders.UserHandler,
 	billHandler *hanlders.BillHandler,
 	groupHandler *hanlders.GroupHandler,
+	splitHandler *hanlders.SplitHandler,
+	shareHandler *hanlders.ShareHandler,
+	claimHandler *hanlders.ClaimHandler,
+	correctionHandler *hanlders.BillCorrectionHandler,
+	analysisAdminHandler *hanlders.AnalysisAdminHandler,
+	groupEventsHandler *hanlders.GroupEventsHandler,
+	signedFileHandler *hanlders.SignedFileHandler,
 ) {
 	// --- User Routes --- //
 	if userHandler != nil {
@@ -36,19 +44,45 @@ func SetupAppRoutes(
 
 	// --- Bill Routes --- //
 	if billHandler != nil {
+		// Public so tus clients can discover the server before authenticating
+		publicRoutes.OPTIONS("/bills/uploads", billHandler.GetResumableUploadOptions)
+
 		billProtected := protectedRoutes.Group("/bills")
 		{
 			billProtected.POST("/upload-analyze-config", billHandler.UploadAndAnalyzeBillWithConfig)
+			billProtected.POST("/upload-url", billHandler.CreateBillUploadURL)
+			billProtected.POST("/uploads", billHandler.CreateResumableUpload)
+			billProtected.HEAD("/uploads/:upload_id", billHandler.GetResumableUploadOffset)
+			billProtected.PATCH("/uploads/:upload_id", billHandler.WriteResumableUpload)
+			billProtected.DELETE("/uploads/:upload_id", billHandler.DeleteResumableUpload)
+			billProtected.POST("/batch", billHandler.UploadBillBatch)
+			billProtected.GET("/batch/:batch_id", billHandler.GetBillBatch)
 			billProtected.GET("/analysis-configs", billHandler.GetAnalysisConfigs)
+			billProtected.GET("/events", billHandler.StreamUserBillEvents)
 			billProtected.GET("", billHandler.ListBills)
 			billProtected.GET("/:bill_id", billHandler.GetBill)
 			billProtected.GET("/:bill_id/status", billHandler.GetBillStatus)
+			billProtected.GET("/:bill_id/events", billHandler.StreamBillEvents)
+			billProtected.POST("/:bill_id/complete", billHandler.CompleteBillUpload)
+			billProtected.POST("/:bill_id/reanalyze", billHandler.ReanalyzeBill)
+			billProtected.POST("/:bill_id/reparse", billHandler.ReparseBill)
 			billProtected.DELETE("/:bill_id", billHandler.DeleteBill)
 		}
 	} else {
 		log.Println("WARN: BillHandler is nil, Bill routes not configured in SetupAppRoutes.")
 	}
 
+	// --- Signed File Routes --- //
+	// Only set up for file stores whose download and upload URLs point at the API
+	if signedFileHandler != nil {
+		// Public file routes, authorized by the signed URL instead of Firebase
+		filePublic := publicRoutes.Group("/files")
+		{
+			filePublic.GET("/*key", signedFileHandler.DownloadFile)
+			filePublic.PUT("/*key", signedFileHandler.UploadFile)
+		}
+	}
+
 	// --- Group Routes --- //
 	if groupHandler != nil {
 		groupProtected := protectedRoutes.Group("/groups")
@@ -58,8 +92,96 @@ func SetupAppRoutes(
 			groupProtected.GET("/:group_id", groupHandler.GetGroup)
 			groupProtected.PUT("/:group_id", groupHandler.UpdateGroup)
 			groupProtected.DELETE("/:group_id", groupHandler.DeleteGroup)
+			groupProtected.PUT("/:group_id/members/:member_id/user", groupHandler.LinkMember)
 		}
 	} else {
 		log.Println("WARN: GroupHandler is nil, Group routes not configured in SetupAppRoutes.")
 	}
+
+	// --- Group Event Routes --- //
+	if groupEventsHandler != nil {
+		groupEventsProtected := protectedRoutes.Group("/groups")
+		{
+			groupEventsProtected.GET("/:group_id/ws", groupEventsHandler.StreamGroupEvents)
+		}
+	} else {
+		log.Println("WARN: GroupEventsHandler is nil, Group event routes not configured in SetupAppRoutes.")
+	}
+
+	// --- Split Routes --- //
+	if splitHandler != nil {
+		splitProtected := protectedRoutes.Group("/bills")
+		{
+			splitProtected.PUT("/:bill_id/group", splitHandler.AttachBillToGroup)
+			splitProtected.PUT("/:bill_id/line-items/:line_item_id/assignments", splitHandler.AssignLineItem)
+		}
+		settlementsProtected := protectedRoutes.Group("/groups")
+		{
+			settlementsProtected.POST("/:group_id/settlements", splitHandler.RecordSettlement)
+		}
+	} else {
+		log.Println("WARN: SplitHandler is nil, Split routes not configured in SetupAppRoutes.")
+	}
+
+	// --- Share Link Routes --- //
+	if shareHandler != nil {
+		// Public share routes, authorized by the signed token instead of Firebase
+		sharePublic := publicRoutes.Group("/share")
+		{
+			sharePublic.GET("/:token", shareHandler.GetGuestShare)
+			sharePublic.GET("/:token/view", shareHandler.ViewGuestShare)
+		}
+
+		shareProtected := protectedRoutes.Group("/groups")
+		{
+			shareProtected.POST("/:group_id/members/:member_id/share-links", shareHandler.CreateShareLink)
+		}
+	} else {
+		log.Println("WARN: ShareHandler is nil, Share link routes not configured in SetupAppRoutes.")
+	}
+
+	// --- Claim Routes --- //
+	if claimHandler != nil {
+		claimProtected := protectedRoutes.Group("/bills")
+		{
+			claimProtected.POST("/:bill_id/claims", claimHandler.OpenSession)
+			claimProtected.GET("/:bill_id/claims", claimHandler.GetSession)
+			claimProtected.PUT("/:bill_id/claims/me", claimHandler.SubmitClaims)
+			claimProtected.POST("/:bill_id/claims/finalize", claimHandler.FinalizeSession)
+		}
+
+		// Public claim routes for guests, authorized by the signed share link token
+		claimPublic := publicRoutes.Group("/share")
+		{
+			claimPublic.GET("/:token/bills/:bill_id/claims", claimHandler.GetGuestSession)
+			claimPublic.PUT("/:token/bills/:bill_id/claims", claimHandler.SubmitGuestClaims)
+		}
+	} else {
+		log.Println("WARN: ClaimHandler is nil, Claim routes not configured in SetupAppRoutes.")
+	}
+
+	// --- Bill Correction Routes --- //
+	if correctionHandler != nil {
+		correctionProtected := protectedRoutes.Group("/bills")
+		{
+			correctionProtected.PATCH("/:bill_id", correctionHandler.UpdateBill)
+			correctionProtected.GET("/:bill_id/history", correctionHandler.GetBillHistory)
+			correctionProtected.POST("/:bill_id/line-items", correctionHandler.AddLineItem)
+			correctionProtected.PATCH("/:bill_id/line-items/:line_item_id", correctionHandler.UpdateLineItem)
+			correctionProtected.DELETE("/:bill_id/line-items/:line_item_id", correctionHandler.DeleteLineItem)
+		}
+	} else {
+		log.Println("WARN: BillCorrectionHandler is nil, Bill correction routes not configured in SetupAppRoutes.")
+	}
+
+	// --- Admin Routes --- //
+	if analysisAdminHandler != nil {
+		adminProtected := protectedRoutes.Group("/admin", middleware.AdminOnlyMiddleware())
+		{
+			adminProtected.GET("/analysis-jobs/dead-letter", analysisAdminHandler.ListDeadLetteredJobs)
+			adminProtected.POST("/analysis-jobs/:job_id/retry", analysisAdminHandler.RetryJob)
+		}
+	} else {
+		log.Println("WARN: AnalysisAdminHandler is nil, Admin routes not configured in SetupAppRoutes.")
+	}
 }
@@ -0,0 +1,144 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+)
+
+// AnalysisJobHandler runs a single analysis job
+type AnalysisJobHandler func(ctx context.Context, job *domain.AnalysisJob) error
+
+// AnalysisWorkerPool runs queued analysis jobs with a bounded number of workers
+type AnalysisWorkerPool struct {
+	queue        ports.JobQueue
+	handle       AnalysisJobHandler
+	workers      int
+	pollInterval time.Duration
+	jobTimeout   time.Duration
+
+	stop       chan struct{}
+	wg         sync.WaitGroup
+	jobCtx     context.Context
+	cancelJobs context.CancelFunc
+}
+
+func NewAnalysisWorkerPool(queue ports.JobQueue, handle AnalysisJobHandler, workers int, pollInterval, jobTimeout time.Duration) *AnalysisWorkerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	return &AnalysisWorkerPool{
+		queue:        queue,
+		handle:       handle,
+		workers:      workers,
+		pollInterval: pollInterval,
+		jobTimeout:   jobTimeout,
+		stop:         make(chan struct{}),
+		jobCtx:       jobCtx,
+		cancelJobs:   cancelJobs,
+	}
+}
+
+// Start launches the workers. They run until Shutdown is called.
+func (p *AnalysisWorkerPool) Start() {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	log.Printf("Started %d analysis workers", p.workers)
+}
+
+// Shutdown stops taking new jobs and waits for the running ones to finish.
+// If ctx expires first, the running jobs are cancelled; their leases expire
+// and they are picked up again on the next start.
+func (p *AnalysisWorkerPool) Shutdown(ctx context.Context) error {
+	close(p.stop)
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancelJobs()
+		return nil
+	case <-ctx.Done():
+		p.cancelJobs()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (p *AnalysisWorkerPool) work() {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		job, err := p.queue.Dequeue(p.jobCtx)
+		if err != nil {
+			if !errors.Is(err, domain.ErrNoJobs) {
+				log.Printf("Error dequeuing analysis job: %v", err)
+			}
+			select {
+			case <-p.stop:
+				return
+			case <-time.After(p.pollInterval):
+			}
+			continue
+		}
+
+		p.run(job)
+	}
+}
+
+func (p *AnalysisWorkerPool) run(job *domain.AnalysisJob) {
+	ctx, cancel := context.WithTimeout(p.jobCtx, p.jobTimeout)
+	jobErr := p.handle(ctx, job)
+	cancel()
+
+	// Record the outcome even if the job was cancelled by a shutdown
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Another worker runs the analysis, the job is left to it
+	if errors.Is(jobErr, domain.ErrAnalysisSuperseded) {
+		log.Printf("Analysis job %s for bill %s was superseded by another analysis", job.ID, job.BillID)
+		return
+	}
+
+	var retryErr *domain.AnalysisRetryError
+	if errors.As(jobErr, &retryErr) {
+		log.Printf("Analysis job %s for bill %s failed on attempt %d, retrying in %s: %v",
+			job.ID, job.BillID, job.Attempts, retryErr.Delay, retryErr.Err)
+		if err := p.queue.Retry(ctx, job, retryErr.Err, time.Now().Add(retryErr.Delay)); err != nil {
+			log.Printf("Error scheduling retry of analysis job %s: %v", job.ID, err)
+		}
+		return
+	}
+
+	if jobErr != nil {
+		log.Printf("Analysis job %s for bill %s failed after %d attempts, dead-lettering it: %v",
+			job.ID, job.BillID, job.Attempts, jobErr)
+		if err := p.queue.Fail(ctx, job, jobErr); err != nil {
+			log.Printf("Error marking analysis job %s as failed: %v", job.ID, err)
+		}
+		return
+	}
+
+	if err := p.queue.Complete(ctx, job); err != nil {
+		log.Printf("Error marking analysis job %s as completed: %v", job.ID, err)
+	}
+}
@@ -0,0 +1,154 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/texttrack"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// batchUploadConcurrency is how many files of a batch are stored at the same time
+const batchUploadConcurrency = 4
+
+// UploadBillBatch creates one bill per file and queues their analyses with a shared configuration.
+// Files are handled independently: a file that can't be stored is reported as rejected in the
+// batch without affecting the others. The request fails as a whole only when it is invalid.
+func (s *BillService) UploadBillBatch(ctx context.Context, req domain.UploadBillBatchRequest, config texttrack.TextDetectionConfig) (*domain.BillBatch, error) {
+	if req.UserID == uuid.Nil {
+		return nil, domain.ErrUserIDEmpty
+	}
+	if len(req.Files) == 0 {
+		return nil, domain.ErrEmptyBatch
+	}
+	if len(req.Files) > domain.MaxBatchFiles {
+		return nil, domain.ErrBatchTooLarge
+	}
+
+	batch := &domain.BillBatch{
+		UserID:         req.UserID,
+		GroupID:        req.GroupID,
+		PaidByMemberID: req.PaidByMemberID,
+	}
+
+	// Check the target group once for the whole batch
+	var group *domain.Group
+	if req.GroupID != nil {
+		if req.PaidByMemberID == nil {
+			return nil, domain.ErrInvalidInput
+		}
+		group = &domain.Group{}
+		err := s.db.WithContext(ctx).Preload("Members").First(group, "id = ? AND owner_id = ?", *req.GroupID, req.UserID).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, domain.ErrGroupNotFound
+			}
+			return nil, fmt.Errorf("error retrieving group: %w", err)
+		}
+		if _, ok := group.FindMember(*req.PaidByMemberID); !ok {
+			return nil, domain.ErrGroupMemberNotFound
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Omit("Files").Create(batch).Error; err != nil {
+		return nil, fmt.Errorf("error saving batch: %w", err)
+	}
+
+	prepare := func(bill *domain.Bill) error {
+		bill.BatchID = &batch.ID
+		if group != nil {
+			return bill.AttachToGroup(group, *req.PaidByMemberID)
+		}
+		return nil
+	}
+
+	batch.Files = make([]domain.BillBatchFile, len(req.Files))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, batchUploadConcurrency)
+	for i, file := range req.Files {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, file domain.UploadBillRequest) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			file.UserID = req.UserID
+			result := domain.BillBatchFile{
+				BatchID:  batch.ID,
+				Position: i,
+				Filename: file.Filename,
+				Status:   domain.BatchFileQueued,
+			}
+
+			bill, err := s.storeBill(ctx, file, config, prepare)
+			if err != nil {
+				message := err.Error()
+				result.Status = domain.BatchFileRejected
+				result.Error = &message
+				result.ErrorCode = domain.UploadErrorCode(err)
+
+				var duplicateErr *domain.DuplicateBillError
+				if errors.As(err, &duplicateErr) {
+					result.DuplicateOfBillID = &duplicateErr.BillID
+				}
+			} else {
+				result.BillID = &bill.ID
+				result.BillStatus = bill.Status
+				if group != nil {
+					s.groupEvents.Publish(group.ID, domain.GroupEventExpenseAdded, req.UserID, domain.ExpenseAddedPayload{
+						BillID:         bill.ID.String(),
+						TotalAmount:    bill.TotalAmount,
+						PaidByMemberID: req.PaidByMemberID.String(),
+					})
+				}
+			}
+			batch.Files[i] = result
+		}(i, file)
+	}
+	wg.Wait()
+
+	// The bills are already stored, so a failure here only loses the per-file report
+	if err := s.db.WithContext(ctx).Create(&batch.Files).Error; err != nil {
+		fmt.Printf("Warning: Failed to save file results of batch %s: %v\n", batch.ID, err)
+	}
+
+	return batch, nil
+}
+
+// GetBillBatch returns a batch of the user with the current status of each of its bills
+func (s *BillService) GetBillBatch(ctx context.Context, batchID, userID uuid.UUID) (*domain.BillBatch, error) {
+	if batchID == uuid.Nil {
+		return nil, domain.ErrInvalidInput
+	}
+
+	var batch domain.BillBatch
+	err := s.db.WithContext(ctx).
+		Preload("Files", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		First(&batch, "id = ? AND user_id = ?", batchID, userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrBatchNotFound
+		}
+		return nil, fmt.Errorf("error retrieving batch: %w", err)
+	}
+
+	var bills []domain.Bill
+	if err := s.db.WithContext(ctx).Select("id", "status").Where("batch_id = ?", batch.ID).Find(&bills).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving batch bills: %w", err)
+	}
+	statuses := make(map[uuid.UUID]domain.BillStatus, len(bills))
+	for _, bill := range bills {
+		statuses[bill.ID] = bill.Status
+	}
+	for i := range batch.Files {
+		if batch.Files[i].BillID != nil {
+			batch.Files[i].BillStatus = statuses[*batch.Files[i].BillID]
+		}
+	}
+
+	return &batch, nil
+}
@@ -0,0 +1,136 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+	"github.com/google/uuid"
+)
+
+// BillCorrectionService lets users fix the values extracted by OCR while
+// keeping every previous value in the bill's revision history.
+type BillCorrectionService struct {
+	billRepo  ports.BillRepository
+	fileStore ports.FileStore // optional, used to sign the file URL of the returned bill
+}
+
+func NewBillCorrectionService(billRepo ports.BillRepository, fileStore ports.FileStore) *BillCorrectionService {
+	return &BillCorrectionService{
+		billRepo:  billRepo,
+		fileStore: fileStore,
+	}
+}
+
+// UpdateBill corrects the vendor, date or amounts of a bill owned by the user
+func (s *BillCorrectionService) UpdateBill(ctx context.Context, billID, userID uuid.UUID, req domain.UpdateBillRequest) (*domain.BillWithURL, error) {
+	return s.correct(ctx, billID, userID, func(correction *domain.BillCorrection) error {
+		return correction.ApplyBillChanges(req)
+	})
+}
+
+// AddLineItem adds a line item the OCR missed to a bill owned by the user
+func (s *BillCorrectionService) AddLineItem(ctx context.Context, billID, userID uuid.UUID, req domain.CreateLineItemRequest) (*domain.BillWithURL, error) {
+	return s.correct(ctx, billID, userID, func(correction *domain.BillCorrection) error {
+		_, err := correction.AddLineItem(req)
+		return err
+	})
+}
+
+// UpdateLineItem corrects a line item of a bill owned by the user
+func (s *BillCorrectionService) UpdateLineItem(ctx context.Context, billID, lineItemID, userID uuid.UUID, req domain.UpdateLineItemRequest) (*domain.BillWithURL, error) {
+	return s.correct(ctx, billID, userID, func(correction *domain.BillCorrection) error {
+		_, err := correction.UpdateLineItem(lineItemID, req)
+		return err
+	})
+}
+
+// DeleteLineItem removes a line item from a bill owned by the user
+func (s *BillCorrectionService) DeleteLineItem(ctx context.Context, billID, lineItemID, userID uuid.UUID) (*domain.BillWithURL, error) {
+	return s.correct(ctx, billID, userID, func(correction *domain.BillCorrection) error {
+		return correction.DeleteLineItem(lineItemID)
+	})
+}
+
+// GetBillHistory returns a bill owned by the user with its revision history
+func (s *BillCorrectionService) GetBillHistory(ctx context.Context, billID, userID uuid.UUID) (*domain.Bill, []*domain.BillRevision, error) {
+	bill, err := s.getOwnedBill(ctx, billID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	revisions, err := s.billRepo.GetBillRevisions(ctx, billID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return bill, revisions, nil
+}
+
+func (s *BillCorrectionService) correct(ctx context.Context, billID, userID uuid.UUID, apply func(correction *domain.BillCorrection) error) (*domain.BillWithURL, error) {
+	bill, err := s.getOwnedBill(ctx, billID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	correction, err := bill.NewCorrection(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := apply(correction); err != nil {
+		return nil, err
+	}
+
+	if err := s.billRepo.SaveBillCorrection(ctx, correction); err != nil {
+		return nil, err
+	}
+
+	// Reload so the response has the stored line items and timestamps
+	updated, err := s.billRepo.GetBillByID(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.BillWithURL{Bill: updated, FileURL: s.fileURL(ctx, updated), BillPreviewURLs: s.previewURLs(ctx, updated)}, nil
+}
+
+func (s *BillCorrectionService) getOwnedBill(ctx context.Context, billID, userID uuid.UUID) (*domain.Bill, error) {
+	if billID == uuid.Nil {
+		return nil, domain.ErrInvalidInput
+	}
+	if userID == uuid.Nil {
+		return nil, domain.ErrUserIDEmpty
+	}
+
+	bill, err := s.billRepo.GetBillByID(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+	if bill.UserID != userID {
+		return nil, domain.ErrBillNotFound
+	}
+	return bill, nil
+}
+
+func (s *BillCorrectionService) fileURL(ctx context.Context, bill *domain.Bill) string {
+	if s.fileStore == nil {
+		return ""
+	}
+	fileURL, err := s.fileStore.GetFileURL(ctx, bill.FileStoragePath)
+	if err != nil {
+		// Log the error but continue as this is not critical
+		fmt.Printf("Warning: Failed to generate pre-signed URL for bill %s: %v\n", bill.ID, err)
+		return ""
+	}
+	return fileURL
+}
+
+// previewURLs returns the URLs of the renditions the bill file already has; the
+// bill service renders missing ones
+func (s *BillCorrectionService) previewURLs(ctx context.Context, bill *domain.Bill) domain.BillPreviewURLs {
+	if s.fileStore == nil {
+		return domain.BillPreviewURLs{}
+	}
+	return storedPreviewURLs(ctx, s.fileStore, bill)
+}
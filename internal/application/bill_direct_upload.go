@@ -0,0 +1,250 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/texttrack"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+)
+
+// uploadBillStorageKey is where a client uploads a bill file directly. The file
+// is moved to the keys of billStorageKey once the upload is completed.
+func uploadBillStorageKey(userID, fileID uuid.UUID, extension string) string {
+	return fmt.Sprintf("bills/%s/uploads/%s%s", userID, fileID, extension)
+}
+
+// CreateBillUpload creates a bill awaiting its file and returns a URL the client
+// uploads the file to directly, without streaming it through the API. The
+// declared type and size are checked here, and the file itself once the upload
+// is completed with CompleteBillUpload. maxFileBytes, if positive, limits the size.
+func (s *BillService) CreateBillUpload(ctx context.Context, userID uuid.UUID, req domain.BillUploadURLRequest, maxFileBytes int64) (*domain.BillUpload, error) {
+	if userID == uuid.Nil {
+		return nil, domain.ErrUserIDEmpty
+	}
+	if req.Filename == "" || req.Size <= 0 {
+		return nil, domain.ErrInvalidInput
+	}
+	if err := s.checkDeclaredBillFile(req.ContentType, req.Size, maxFileBytes); err != nil {
+		return nil, err
+	}
+
+	filename := filepath.Base(req.Filename)
+	uploadURL, err := s.fileStore.CreateUploadURL(ctx,
+		uploadBillStorageKey(userID, uuid.New(), billFileExtensions[req.ContentType]),
+		ports.FileMetadata{ContentType: req.ContentType, Filename: filename}, req.Size)
+	if err != nil {
+		return nil, fmt.Errorf("error creating upload URL: %w", err)
+	}
+
+	bill, err := domain.NewBill(userID, req.Filename, uploadURL.StoragePath, req.ContentType)
+	if err != nil {
+		return nil, fmt.Errorf("error creating bill record: %w", err)
+	}
+	bill.Status = domain.BillStatusAwaitingUpload
+	if err := s.db.WithContext(ctx).Create(bill).Error; err != nil {
+		return nil, fmt.Errorf("error saving bill to database: %w", err)
+	}
+	s.publishBillEvent(bill)
+
+	return &domain.BillUpload{
+		Bill:      bill,
+		URL:       uploadURL.URL,
+		Method:    uploadURL.Method,
+		Headers:   uploadURL.Headers,
+		ExpiresAt: uploadURL.ExpiresAt,
+	}, nil
+}
+
+// CompleteBillUpload checks the file a client uploaded directly for a bill
+// created with CreateBillUpload, like an upload through the API, stores it under
+// the bill's keys and queues the analysis. Files that fail validation are
+// deleted with their bill; a missing file is reported with domain.ErrMissingFile
+// and the bill keeps waiting for it.
+func (s *BillService) CompleteBillUpload(ctx context.Context, billID, userID uuid.UUID, maxFileBytes int64, config texttrack.TextDetectionConfig) (*domain.BillWithURL, error) {
+	var bill domain.Bill
+	err := s.db.WithContext(ctx).First(&bill, "id = ? AND user_id = ?", billID, userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrBillNotFound
+		}
+		return nil, fmt.Errorf("error retrieving bill: %w", err)
+	}
+	if bill.Status != domain.BillStatusAwaitingUpload {
+		return nil, domain.ErrBillUploadCompleted
+	}
+	uploadPath := bill.FileStoragePath
+
+	content, err := s.readUploadedBillFile(ctx, uploadPath, maxFileBytes)
+	if err != nil {
+		if errors.Is(err, domain.ErrFileTooLarge) {
+			s.rejectBillUpload(ctx, bill.ID, uploadPath)
+		}
+		return nil, err
+	}
+
+	prepared, err := s.prepareBillFile(ctx, content, bill.Filename)
+	if err != nil {
+		if domain.UploadErrorCode(err) != "" {
+			s.rejectBillUpload(ctx, bill.ID, uploadPath)
+		}
+		return nil, err
+	}
+
+	// The uploaded file is copied within the store rather than uploaded again
+	stored, err := s.storeBillFile(ctx, bill.UserID, bill.Filename, prepared,
+		func(key string, metadata ports.FileMetadata) (string, error) {
+			return s.fileStore.CopyFile(ctx, uploadPath, key, metadata)
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	bill.FileStoragePath = stored.path
+	bill.OriginalStoragePath = stored.originalPath
+	bill.FileType = stored.contentType
+	bill.ContentHash = &stored.contentHash
+	bill.PerceptualHash = stored.perceptualHash
+	bill.Status = domain.BillStatusPending
+
+	// Look for the same upload among the user's bills
+	if err := s.checkUploadDuplicate(ctx, &bill); err != nil {
+		s.deleteBillFiles(ctx, stored.path, stored.originalPath)
+		s.rejectBillUpload(ctx, bill.ID, uploadPath)
+		return nil, err
+	}
+
+	// Only one completion of the upload can move the bill out of awaiting_upload
+	result := s.db.WithContext(ctx).Model(&domain.Bill{}).
+		Where("id = ? AND status = ?", bill.ID, domain.BillStatusAwaitingUpload).
+		Updates(map[string]interface{}{
+			"file_storage_path":     bill.FileStoragePath,
+			"original_storage_path": bill.OriginalStoragePath,
+			"file_type":             bill.FileType,
+			"content_hash":          bill.ContentHash,
+			"perceptual_hash":       bill.PerceptualHash,
+			"duplicate_of_bill_id":  bill.DuplicateOfBillID,
+			"duplicate_reason":      bill.DuplicateReason,
+			"status":                bill.Status,
+		})
+	if result.Error != nil {
+		s.deleteBillFiles(ctx, stored.path, stored.originalPath)
+		return nil, fmt.Errorf("error updating bill: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		s.deleteBillFiles(ctx, stored.path, stored.originalPath)
+		return nil, domain.ErrBillUploadCompleted
+	}
+	s.fileStore.DeleteFile(ctx, uploadPath)
+
+	// Queue the analysis
+	job, err := domain.NewAnalysisJob(bill.ID, analysisOptions(config))
+	if err == nil {
+		err = s.jobQueue.Enqueue(ctx, job)
+	}
+	if err != nil {
+		// The file is already stored, so the bill is kept and can be analyzed again
+		s.recordAnalysisFailure(bill.ID, domain.BillStatusFailed, err)
+		return nil, fmt.Errorf("error queueing bill analysis: %w", err)
+	}
+	s.publishBillEvent(&bill)
+
+	fileURL, err := s.fileStore.GetFileURL(ctx, bill.FileStoragePath)
+	if err != nil {
+		// Log the error but continue as this is not critical
+		fmt.Printf("Warning: Failed to generate pre-signed URL for bill %s: %v\n", bill.ID, err)
+		fileURL = ""
+	}
+
+	return &domain.BillWithURL{Bill: &bill, FileURL: fileURL}, nil
+}
+
+// checkDeclaredBillFile checks the type and size a client declares for a file
+// it uploads without going through the API. The file itself is checked once uploaded.
+func (s *BillService) checkDeclaredBillFile(contentType string, size, maxFileBytes int64) error {
+	if maxFileBytes > 0 && size > maxFileBytes {
+		return domain.ErrFileTooLarge
+	}
+	if !analyzableFileTypes[contentType] && !(convertibleFileTypes[contentType] && s.imageProcessor != nil) {
+		return fmt.Errorf("%w: got %s", domain.ErrUnsupportedFileType, contentType)
+	}
+	return nil
+}
+
+// readUploadedBillFile reads a file uploaded directly to the store, failing with
+// domain.ErrFileTooLarge past maxFileBytes when it is positive
+func (s *BillService) readUploadedBillFile(ctx context.Context, uploadPath string, maxFileBytes int64) ([]byte, error) {
+	file, err := s.fileStore.OpenFile(ctx, uploadPath)
+	if err != nil {
+		if errors.Is(err, domain.ErrStoredFileNotFound) {
+			return nil, domain.ErrMissingFile
+		}
+		return nil, fmt.Errorf("error opening uploaded bill file: %w", err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if maxFileBytes > 0 {
+		// Read one byte more than allowed to tell a file of exactly the limit from a larger one
+		reader = io.LimitReader(file, maxFileBytes+1)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading uploaded bill file: %w", err)
+	}
+	if maxFileBytes > 0 && int64(len(content)) > maxFileBytes {
+		return nil, domain.ErrFileTooLarge
+	}
+	return content, nil
+}
+
+// ExpireBillUploads deletes the bills still awaiting their file longer than the
+// upload TTL after they were created, with whatever file was uploaded for them,
+// and returns how many were deleted. Uploaded files are only encrypted once their
+// upload is completed, so abandoned ones must not be left in the store.
+func (s *BillService) ExpireBillUploads(ctx context.Context) (int, error) {
+	var bills []domain.Bill
+	err := s.db.WithContext(ctx).
+		Where("status = ? AND uploaded_at < ?", domain.BillStatusAwaitingUpload, time.Now().Add(-s.uploadTTL).UTC()).
+		Find(&bills).Error
+	if err != nil {
+		return 0, fmt.Errorf("error loading expired bill uploads: %w", err)
+	}
+
+	expired := 0
+	for _, bill := range bills {
+		if err := s.fileStore.DeleteFile(ctx, bill.FileStoragePath); err != nil && !errors.Is(err, domain.ErrStoredFileNotFound) {
+			fmt.Printf("Warning: Failed to delete uploaded file of expired bill %s: %v\n", bill.ID, err)
+			continue
+		}
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			result := tx.Delete(&domain.Bill{}, "id = ? AND status = ?", bill.ID, domain.BillStatusAwaitingUpload)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			// A resumable upload can't complete once its bill is gone
+			return tx.Where("bill_id = ?", bill.ID).Delete(&domain.ResumableUpload{}).Error
+		})
+		if err != nil {
+			fmt.Printf("Warning: Failed to delete expired bill upload %s: %v\n", bill.ID, err)
+			continue
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// rejectBillUpload deletes a bill whose directly uploaded file failed validation,
+// with the file, ignoring errors as the caller reports the validation error
+func (s *BillService) rejectBillUpload(ctx context.Context, billID uuid.UUID, uploadPath string) {
+	s.fileStore.DeleteFile(ctx, uploadPath)
+	s.db.WithContext(ctx).Delete(&domain.Bill{}, "id = ? AND status = ?", billID, domain.BillStatusAwaitingUpload)
+}
@@ -0,0 +1,112 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// duplicateScope limits a duplicate search to the bills of the uploader and,
+// for bills attached to a group, the bills of that group
+func duplicateScope(bill *domain.Bill) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if bill.GroupID != nil {
+			return db.Where("(user_id = ? OR group_id = ?) AND id <> ?", bill.UserID, *bill.GroupID, bill.ID)
+		}
+		return db.Where("user_id = ? AND id <> ?", bill.UserID, bill.ID)
+	}
+}
+
+// checkUploadDuplicate looks for an earlier upload of the same file, or another
+// photo of the same receipt, before a new bill is saved. Depending on the policy
+// the bill is marked as a duplicate or rejected with a *domain.DuplicateBillError.
+func (s *BillService) checkUploadDuplicate(ctx context.Context, bill *domain.Bill) error {
+	if s.duplicates == "" || s.duplicates == domain.DuplicatePolicyOff {
+		return nil
+	}
+
+	billID, reason, err := s.findUploadDuplicate(ctx, bill)
+	if err != nil {
+		// Log the error but continue, duplicates are only a convenience
+		fmt.Printf("Warning: Failed to look for duplicates of %s: %v\n", bill.Filename, err)
+		return nil
+	}
+	if billID == uuid.Nil {
+		return nil
+	}
+
+	if s.duplicates == domain.DuplicatePolicyBlock {
+		return &domain.DuplicateBillError{BillID: billID, Reason: reason}
+	}
+	bill.MarkDuplicateOf(billID, reason)
+	return nil
+}
+
+func (s *BillService) findUploadDuplicate(ctx context.Context, bill *domain.Bill) (uuid.UUID, domain.DuplicateReason, error) {
+	if bill.ContentHash != nil {
+		var match domain.Bill
+		result := s.db.WithContext(ctx).Scopes(duplicateScope(bill)).
+			Select("id").Where("content_hash = ?", *bill.ContentHash).
+			Order("uploaded_at ASC").Limit(1).Find(&match)
+		if result.Error != nil {
+			return uuid.Nil, "", fmt.Errorf("error looking for identical files: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			return match.ID, domain.DuplicateSameFile, nil
+		}
+	}
+
+	if bill.PerceptualHash != nil {
+		var candidates []domain.Bill
+		err := s.db.WithContext(ctx).Scopes(duplicateScope(bill)).
+			Select("id", "perceptual_hash").Where("perceptual_hash IS NOT NULL").
+			Order("uploaded_at ASC").Find(&candidates).Error
+		if err != nil {
+			return uuid.Nil, "", fmt.Errorf("error looking for similar images: %w", err)
+		}
+		for _, candidate := range candidates {
+			if domain.PerceptualHashDistance(*candidate.PerceptualHash, *bill.PerceptualHash) <= domain.MaxPerceptualHashDistance {
+				return candidate.ID, domain.DuplicateSameImage, nil
+			}
+		}
+	}
+
+	return uuid.Nil, "", nil
+}
+
+// flagReceiptDuplicate marks an analyzed bill as a duplicate when another bill
+// in its scope has the same vendor, date and total. The bill is already stored
+// by then, so it is only marked, whatever the policy.
+func (s *BillService) flagReceiptDuplicate(ctx context.Context, bill *domain.Bill) {
+	if s.duplicates == "" || s.duplicates == domain.DuplicatePolicyOff || bill.DuplicateOfBillID != nil {
+		return
+	}
+	fingerprint := bill.ReceiptFingerprint()
+	if fingerprint == nil {
+		return
+	}
+
+	var match domain.Bill
+	result := s.db.WithContext(ctx).Scopes(duplicateScope(bill)).
+		Select("id").Where("fingerprint = ?", *fingerprint).
+		Order("uploaded_at ASC").Limit(1).Find(&match)
+	if result.Error != nil {
+		fmt.Printf("Warning: Failed to look for duplicates of bill %s: %v\n", bill.ID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	bill.MarkDuplicateOf(match.ID, domain.DuplicateSameReceipt)
+	err := s.db.WithContext(ctx).Model(&domain.Bill{}).Where("id = ?", bill.ID).Updates(map[string]interface{}{
+		"duplicate_of_bill_id": bill.DuplicateOfBillID,
+		"duplicate_reason":     bill.DuplicateReason,
+	}).Error
+	if err != nil {
+		fmt.Printf("Warning: Failed to mark bill %s as a duplicate: %v\n", bill.ID, err)
+	}
+}
@@ -0,0 +1,103 @@
+package application
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/jpeg" // registers the JPEG decoder
+	_ "image/png"  // registers the PNG decoder
+
+	"github.com/gabriel-vasile/mimetype"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+)
+
+// analyzableFileTypes are the file types Textract can analyze
+var analyzableFileTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"application/pdf": true,
+	"image/tiff":      true,
+}
+
+// convertibleFileTypes are the phone photo formats the image processor turns into JPEGs
+var convertibleFileTypes = map[string]bool{
+	"image/heic": true,
+	"image/heif": true,
+	"image/webp": true,
+}
+
+// billFileExtensions are the extensions bill files are stored with, by file type
+var billFileExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
+	"image/tiff":      ".tif",
+	"image/heic":      ".heic",
+	"image/heif":      ".heif",
+	"image/webp":      ".webp",
+}
+
+// detectBillFileType returns the real type of an uploaded file from its bytes,
+// whatever the client declared, and rejects files that can't be analyzed or are
+// damaged. HEIC and WebP photos are only accepted when they will be converted.
+func (s *BillService) detectBillFileType(content []byte) (string, error) {
+	if len(content) == 0 {
+		return "", domain.ErrEmptyFile
+	}
+
+	contentType := mimetype.Detect(content).String()
+	switch {
+	case analyzableFileTypes[contentType]:
+	case convertibleFileTypes[contentType] && s.imageProcessor != nil:
+	default:
+		return "", fmt.Errorf("%w: got %s", domain.ErrUnsupportedFileType, contentType)
+	}
+
+	// Damaged photos are reported by the image processor when it decodes them
+	if s.imageProcessor != nil && contentType != "application/pdf" && contentType != "image/tiff" {
+		return contentType, nil
+	}
+
+	if err := checkFileIntegrity(content, contentType, s.maxImagePixels); err != nil {
+		return "", err
+	}
+	return contentType, nil
+}
+
+// checkFileIntegrity catches truncated and damaged uploads, which Textract
+// would only reject after the bill is stored and queued. Images with more than
+// maxPixels pixels are rejected from their header, before they are decoded.
+func checkFileIntegrity(content []byte, contentType string, maxPixels int) error {
+	switch contentType {
+	case "image/jpeg", "image/png":
+		config, _, err := image.DecodeConfig(bytes.NewReader(content))
+		if err != nil {
+			return fmt.Errorf("%w: %v", domain.ErrCorruptFile, err)
+		}
+		if maxPixels > 0 && int64(config.Width)*int64(config.Height) > int64(maxPixels) {
+			return fmt.Errorf("%w: image is %dx%d pixels, more than %d", domain.ErrFileTooLarge, config.Width, config.Height, maxPixels)
+		}
+
+		// Decoding the whole image catches truncated files, not only bad headers
+		if _, _, err := image.Decode(bytes.NewReader(content)); err != nil {
+			return fmt.Errorf("%w: %v", domain.ErrCorruptFile, err)
+		}
+	case "application/pdf":
+		// A complete PDF ends with an end-of-file marker, possibly followed by whitespace
+		tail := content[max(0, len(content)-1024):]
+		if !bytes.Contains(tail, []byte("%%EOF")) {
+			return fmt.Errorf("%w: PDF has no end-of-file marker", domain.ErrCorruptFile)
+		}
+	case "image/tiff":
+		var order binary.ByteOrder = binary.LittleEndian
+		if content[0] == 'M' {
+			order = binary.BigEndian
+		}
+		if len(content) < 8 || int64(order.Uint32(content[4:8])) >= int64(len(content)) {
+			return fmt.Errorf("%w: TIFF image directory is missing", domain.ErrCorruptFile)
+		}
+	}
+	return nil
+}
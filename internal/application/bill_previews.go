@@ -0,0 +1,201 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+)
+
+// Renditions of a bill file
+const (
+	thumbnailRendition = "thumbnail"
+	previewRendition   = "preview"
+)
+
+// Background rendering of bills without renditions
+const (
+	previewBackfillBatchSize = 20        // bills rendered per run
+	previewRetryDelay        = time.Hour // wait before a bill whose render failed is tried again
+	maxPreviewAttempts       = 5         // renders tried before a bill is left without renditions
+)
+
+// billPreviewStorageKey is the key a rendition of a bill file is stored under,
+// next to the bill's files. It is named after the bill, so it stays unique for
+// bills whose files are still under their uploaded filename.
+func billPreviewStorageKey(userID, billID uuid.UUID, rendition string) string {
+	return fmt.Sprintf("bills/%s/previews/%s-%s.jpg", userID, billID, rendition)
+}
+
+// BillPreviewURLs returns the URLs of the thumbnail and preview of each bill by
+// bill ID. They are empty for bills uploaded before renditions existed until
+// RenderMissingPreviews renders them.
+func (s *BillService) BillPreviewURLs(ctx context.Context, bills []domain.Bill) map[uuid.UUID]domain.BillPreviewURLs {
+	urls := make(map[uuid.UUID]domain.BillPreviewURLs, len(bills))
+	for i := range bills {
+		urls[bills[i].ID] = storedPreviewURLs(ctx, s.fileStore, &bills[i])
+	}
+	return urls
+}
+
+// RenderMissingPreviews renders the renditions of bills uploaded before they
+// existed, most recent first, and returns how many bills were rendered. Bills
+// still waiting for their analysis are left to the analysis workers. Bills whose
+// render failed are tried again after previewRetryDelay, behind the others, up
+// to maxPreviewAttempts times. Runs on several API instances may render the same
+// bill, which stores the same files.
+func (s *BillService) RenderMissingPreviews(ctx context.Context) (int, error) {
+	if s.previewRenderer == nil {
+		return 0, nil
+	}
+
+	var bills []domain.Bill
+	err := s.db.WithContext(ctx).
+		Where("previews_rendered_at IS NULL AND status NOT IN ?", []domain.BillStatus{
+			domain.BillStatusAwaitingUpload, domain.BillStatusPending, domain.BillStatusProcessing,
+		}).
+		Where("preview_attempts < ? AND (previews_attempted_at IS NULL OR previews_attempted_at < ?)",
+			maxPreviewAttempts, time.Now().Add(-previewRetryDelay).UTC()).
+		Order("preview_attempts ASC, uploaded_at DESC").
+		Limit(previewBackfillBatchSize).
+		Find(&bills).Error
+	if err != nil {
+		return 0, fmt.Errorf("error loading bills without previews: %w", err)
+	}
+
+	rendered := 0
+	for i := range bills {
+		// The attempt is recorded first, so a bill that keeps failing moves back
+		err := s.db.WithContext(ctx).Model(&domain.Bill{}).Where("id = ?", bills[i].ID).UpdateColumns(map[string]interface{}{
+			"preview_attempts":      gorm.Expr("preview_attempts + 1"),
+			"previews_attempted_at": time.Now().UTC(),
+		}).Error
+		if err != nil {
+			return rendered, fmt.Errorf("error recording preview attempt: %w", err)
+		}
+
+		if err := s.renderBillPreviews(ctx, &bills[i]); err != nil {
+			if ctx.Err() != nil {
+				return rendered, ctx.Err()
+			}
+			fmt.Printf("Warning: Failed to render previews for bill %s: %v\n", bills[i].ID, err)
+			continue
+		}
+		rendered++
+	}
+	return rendered, nil
+}
+
+// renderPendingPreviews renders the renditions of a bill file if it has none yet
+func (s *BillService) renderPendingPreviews(ctx context.Context, billID uuid.UUID) {
+	var bill domain.Bill
+	if err := s.db.WithContext(ctx).First(&bill, "id = ?", billID).Error; err != nil {
+		return
+	}
+	if bill.PreviewsRenderedAt != nil {
+		return
+	}
+	if err := s.renderBillPreviews(ctx, &bill); err != nil {
+		fmt.Printf("Warning: Failed to render previews for bill %s: %v\n", bill.ID, err)
+	}
+}
+
+// renderBillPreviews renders the thumbnail and preview of a bill file and stores
+// them next to it. Files that can't be rendered are recorded as rendered without
+// renditions, so they are not tried again, while storage errors leave the bill
+// to be rendered next time.
+func (s *BillService) renderBillPreviews(ctx context.Context, bill *domain.Bill) error {
+	if s.previewRenderer == nil || bill.Status == domain.BillStatusAwaitingUpload {
+		return nil
+	}
+
+	file, err := s.fileStore.OpenFile(ctx, bill.FileStoragePath)
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("error reading bill file: %w", err)
+	}
+
+	renderedAt := time.Now().UTC()
+	updates := map[string]interface{}{"previews_rendered_at": renderedAt}
+	previews, err := s.previewRenderer.RenderPreviews(ctx, content, bill.FileType)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		fmt.Printf("Warning: Bill %s can't be rendered, it is shown without previews: %v\n", bill.ID, err)
+		previews = nil
+	}
+
+	var thumbnailPath, previewPath *string
+	if previews != nil {
+		stored, err := s.storePreview(ctx, bill, thumbnailRendition, previews.Thumbnail)
+		if err != nil {
+			return err
+		}
+		thumbnailPath = &stored
+		if stored, err = s.storePreview(ctx, bill, previewRendition, previews.Preview); err != nil {
+			s.fileStore.DeleteFile(ctx, *thumbnailPath)
+			return err
+		}
+		previewPath = &stored
+		updates["thumbnail_storage_path"] = *thumbnailPath
+		updates["preview_storage_path"] = *previewPath
+	}
+
+	// Rendering is not an edit of the bill, so its update time is kept
+	if err := s.db.WithContext(ctx).Model(&domain.Bill{}).Where("id = ?", bill.ID).UpdateColumns(updates).Error; err != nil {
+		return fmt.Errorf("error recording bill previews: %w", err)
+	}
+	bill.ThumbnailStoragePath = thumbnailPath
+	bill.PreviewStoragePath = previewPath
+	bill.PreviewsRenderedAt = &renderedAt
+	return nil
+}
+
+// storePreview uploads a rendition of a bill file, named after the bill's file for downloads
+func (s *BillService) storePreview(ctx context.Context, bill *domain.Bill, rendition string, content []byte) (string, error) {
+	filename := filepath.Base(bill.Filename)
+	filename = strings.TrimSuffix(filename, filepath.Ext(filename)) + "-" + rendition + ".jpg"
+
+	storagePath, err := s.fileStore.UploadFile(ctx, bytes.NewReader(content), billPreviewStorageKey(bill.UserID, bill.ID, rendition),
+		ports.FileMetadata{ContentType: "image/jpeg", Filename: filename})
+	if err != nil {
+		return "", fmt.Errorf("error storing bill %s: %w", rendition, err)
+	}
+	return storagePath, nil
+}
+
+// storedPreviewURLs returns the URLs of the renditions a bill file already has
+func storedPreviewURLs(ctx context.Context, fileStore ports.FileStore, bill *domain.Bill) domain.BillPreviewURLs {
+	var urls domain.BillPreviewURLs
+	if bill.ThumbnailStoragePath != nil {
+		urls.ThumbnailURL = previewURL(ctx, fileStore, bill.ID, *bill.ThumbnailStoragePath)
+	}
+	if bill.PreviewStoragePath != nil {
+		urls.PreviewURL = previewURL(ctx, fileStore, bill.ID, *bill.PreviewStoragePath)
+	}
+	return urls
+}
+
+func previewURL(ctx context.Context, fileStore ports.FileStore, billID uuid.UUID, storagePath string) string {
+	url, err := fileStore.GetFileURL(ctx, storagePath)
+	if err != nil {
+		// Log the error but continue as this is not critical
+		fmt.Printf("Warning: Failed to generate pre-signed URL for preview of bill %s: %v\n", billID, err)
+		return ""
+	}
+	return url
+}
@@ -0,0 +1,152 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/texttrack"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+)
+
+// AnalysisReparseReport counts what parsing the stored analyses again did
+type AnalysisReparseReport struct {
+	Reparsed int // bills updated from their stored analysis
+	Skipped  int // bills being analyzed or edited at the time
+	Failed   int // bills left as they were
+}
+
+// ReparseBill parses the stored response of the last analysis of a bill again
+// with a different configuration, without calling Textract. Extracted values
+// and line items are replaced like ReanalyzeBill does.
+func (s *BillService) ReparseBill(ctx context.Context, billID, userID uuid.UUID, config texttrack.TextDetectionConfig, overwriteCorrections bool) (*domain.BillWithURL, error) {
+	if billID == uuid.Nil {
+		return nil, domain.ErrInvalidInput
+	}
+
+	var bill domain.Bill
+	err := s.db.WithContext(ctx).Preload("LineItems").First(&bill, "id = ? AND user_id = ?", billID, userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrBillNotFound
+		}
+		return nil, fmt.Errorf("error retrieving bill: %w", err)
+	}
+
+	run, err := s.latestAnalysisRun(ctx, bill.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reparseBill(ctx, &bill, run, config, overwriteCorrections); err != nil {
+		return nil, err
+	}
+	return s.GetBill(ctx, bill.ID, userID)
+}
+
+// ReparseAnalyses parses the last stored analysis of every bill parsed by an older
+// version of the parser again, with the options it was parsed with. Corrections
+// made by users are kept. With dryRun nothing is changed.
+func (s *BillService) ReparseAnalyses(ctx context.Context, dryRun bool) (*AnalysisReparseReport, error) {
+	var runs []domain.AnalysisRun
+	err := s.db.WithContext(ctx).
+		Select("id", "bill_id", "options", "parser_version").
+		Where("parser_version < ?", texttrack.ParserVersion).
+		Where("created_at = (SELECT MAX(latest.created_at) FROM analysis_runs latest WHERE latest.bill_id = analysis_runs.bill_id)").
+		Order("created_at").
+		Find(&runs).Error
+	if err != nil {
+		return nil, fmt.Errorf("error loading analysis runs: %w", err)
+	}
+
+	report := &AnalysisReparseReport{}
+	for _, run := range runs {
+		if dryRun {
+			report.Reparsed++
+			continue
+		}
+
+		var bill domain.Bill
+		if err := s.db.WithContext(ctx).Preload("LineItems").First(&bill, "id = ?", run.BillID).Error; err != nil {
+			report.Failed++
+			log.Printf("Failed to load bill %s to parse its analysis again: %v", run.BillID, err)
+			continue
+		}
+		// Runs are listed without their responses, which are loaded one bill at a time
+		fullRun, err := s.latestAnalysisRun(ctx, bill.ID)
+		if err != nil {
+			report.Failed++
+			log.Printf("Failed to load the analysis of bill %s: %v", bill.ID, err)
+			continue
+		}
+
+		err = s.reparseBill(ctx, &bill, fullRun, textDetectionConfig(run.Options), false)
+		switch {
+		case errors.Is(err, domain.ErrBillNotEditable), errors.Is(err, domain.ErrBillVersionConflict):
+			report.Skipped++
+			log.Printf("Skipped bill %s, it is being analyzed or edited", bill.ID)
+		case err != nil:
+			report.Failed++
+			log.Printf("Failed to parse the analysis of bill %s again: %v", bill.ID, err)
+		default:
+			report.Reparsed++
+		}
+	}
+	return report, nil
+}
+
+// reparseBill parses a stored analysis of a bill again and saves the result,
+// recording on the run which options and parser version it was parsed with
+func (s *BillService) reparseBill(ctx context.Context, bill *domain.Bill, run *domain.AnalysisRun, config texttrack.TextDetectionConfig, overwriteCorrections bool) error {
+	if !bill.IsEditable() {
+		return domain.ErrBillNotEditable
+	}
+	rawResponse, err := run.RawResponse()
+	if err != nil {
+		return err
+	}
+	parsed, err := texttrack.ParseRawResponse(rawResponse, config)
+	if err != nil {
+		return err
+	}
+
+	restoreStatus, err := s.markBillReanalyzing(ctx, bill)
+	if err != nil {
+		return err
+	}
+	err = s.saveReanalysis(ctx, bill, parsed, overwriteCorrections, func(tx *gorm.DB) error {
+		err := tx.Model(&domain.AnalysisRun{}).Where("id = ?", run.ID).Updates(map[string]interface{}{
+			"options":        analysisOptions(config),
+			"parser_version": texttrack.ParserVersion,
+			"reparsed_at":    time.Now().UTC(),
+		}).Error
+		if err != nil {
+			return fmt.Errorf("error updating analysis run: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		restoreStatus()
+		return err
+	}
+	s.flagReceiptDuplicate(ctx, bill)
+	s.publishBillStatus(bill.ID)
+	return nil
+}
+
+// latestAnalysisRun loads the last stored analysis of a bill
+func (s *BillService) latestAnalysisRun(ctx context.Context, billID uuid.UUID) (*domain.AnalysisRun, error) {
+	var run domain.AnalysisRun
+	err := s.db.WithContext(ctx).Where("bill_id = ?", billID).Order("created_at DESC").First(&run).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrAnalysisRunNotFound
+		}
+		return nil, fmt.Errorf("error retrieving analysis run: %w", err)
+	}
+	return &run, nil
+}
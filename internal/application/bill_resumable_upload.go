@@ -0,0 +1,334 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/texttrack"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+)
+
+// errResumableUploadsUnavailable is returned when no upload stager is configured
+var errResumableUploadsUnavailable = errors.New("resumable uploads are not configured")
+
+// resumableUploadLease is how long a request holds an upload while it writes a
+// chunk before renewing its lease
+const resumableUploadLease = time.Minute
+
+// CreateResumableUpload starts an upload of a bill file the client sends in
+// chunks and can resume after losing its connection. The declared type and size
+// are checked here, the file itself once all of it is received. The bill is
+// analyzed with config when the upload completes.
+func (s *BillService) CreateResumableUpload(ctx context.Context, req domain.ResumableUploadRequest, maxFileBytes int64, config texttrack.TextDetectionConfig) (*domain.ResumableUpload, error) {
+	if req.UserID == uuid.Nil {
+		return nil, domain.ErrUserIDEmpty
+	}
+	if req.Filename == "" {
+		return nil, domain.ErrInvalidInput
+	}
+	if req.Size <= 0 {
+		return nil, domain.ErrEmptyFile
+	}
+	if err := s.checkDeclaredBillFile(req.ContentType, req.Size, maxFileBytes); err != nil {
+		return nil, err
+	}
+	if s.uploadStager == nil {
+		return nil, errResumableUploadsUnavailable
+	}
+
+	key := uploadBillStorageKey(req.UserID, uuid.New(), billFileExtensions[req.ContentType])
+	handle, err := s.uploadStager.StartUpload(ctx, key, ports.FileMetadata{ContentType: req.ContentType, Filename: filepath.Base(req.Filename)})
+	if err != nil {
+		return nil, fmt.Errorf("error starting upload: %w", err)
+	}
+
+	upload := &domain.ResumableUpload{
+		UserID:        req.UserID,
+		Filename:      req.Filename,
+		ContentType:   req.ContentType,
+		Size:          req.Size,
+		Metadata:      req.Metadata,
+		Options:       analysisOptions(config),
+		StorageKey:    key,
+		StagingHandle: handle,
+		ExpiresAt:     time.Now().Add(s.uploadTTL).UTC(),
+	}
+	if err := s.db.WithContext(ctx).Create(upload).Error; err != nil {
+		s.uploadStager.AbortUpload(ctx, key, handle)
+		return nil, fmt.Errorf("error saving upload to database: %w", err)
+	}
+	return upload, nil
+}
+
+// GetResumableUpload returns an upload of the user, failing with
+// domain.ErrResumableUploadExpired once an incomplete upload has expired
+func (s *BillService) GetResumableUpload(ctx context.Context, uploadID, userID uuid.UUID) (*domain.ResumableUpload, error) {
+	var upload domain.ResumableUpload
+	err := s.db.WithContext(ctx).First(&upload, "id = ? AND user_id = ?", uploadID, userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrResumableUploadNotFound
+		}
+		return nil, fmt.Errorf("error retrieving upload: %w", err)
+	}
+	if !upload.IsComplete() && time.Now().After(upload.ExpiresAt) {
+		return nil, domain.ErrResumableUploadExpired
+	}
+	return &upload, nil
+}
+
+// WriteResumableUpload appends a chunk starting at offset, which must be the
+// number of bytes received so far. Bytes received before the chunk failed are
+// kept, so the client can resume from the offset of the returned upload. Once the
+// whole file is received it becomes a bill, checked like any upload and queued
+// for analysis, which is returned too. A complete upload whose bill could not be
+// created can be retried with an empty chunk.
+func (s *BillService) WriteResumableUpload(ctx context.Context, uploadID, userID uuid.UUID, offset int64, chunk io.Reader, maxFileBytes int64) (*domain.ResumableUpload, *domain.BillWithURL, error) {
+	if s.uploadStager == nil {
+		return nil, nil, errResumableUploadsUnavailable
+	}
+	upload, release, err := s.claimResumableUpload(ctx, uploadID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	if upload.UserID != userID {
+		return nil, nil, domain.ErrResumableUploadNotFound
+	}
+	if !upload.IsComplete() && time.Now().After(upload.ExpiresAt) {
+		return nil, nil, domain.ErrResumableUploadExpired
+	}
+	if offset != upload.UploadedBytes {
+		return upload, nil, domain.ErrUploadOffsetMismatch
+	}
+
+	if !upload.IsComplete() {
+		// The chunk is received outside any transaction, the lease keeps other requests out
+		written, writeErr := s.uploadStager.WriteChunk(ctx, upload.StorageKey, upload.StagingHandle, offset,
+			io.LimitReader(chunk, upload.Size-offset))
+		if written > 0 {
+			upload.UploadedBytes += written
+			upload.ExpiresAt = time.Now().Add(s.uploadTTL).UTC()
+			result := s.db.WithContext(ctx).Model(&domain.ResumableUpload{}).
+				Where("id = ? AND lock_token = ? AND uploaded_bytes = ?", upload.ID, *upload.LockToken, offset).
+				Updates(map[string]interface{}{
+					"uploaded_bytes": upload.UploadedBytes,
+					"expires_at":     upload.ExpiresAt,
+				})
+			if result.Error != nil {
+				return nil, nil, fmt.Errorf("error updating upload: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return nil, nil, domain.ErrResumableUploadLocked
+			}
+		}
+		if writeErr != nil {
+			return upload, nil, writeErr
+		}
+		if !upload.IsComplete() {
+			return upload, nil, nil
+		}
+	}
+
+	if upload.BillID == nil {
+		if err := s.createResumableUploadBill(ctx, upload); err != nil {
+			return upload, nil, err
+		}
+	}
+
+	billWithURL, err := s.completeResumableUpload(ctx, upload, maxFileBytes)
+	if err != nil {
+		return upload, nil, err
+	}
+	return upload, billWithURL, nil
+}
+
+// createResumableUploadBill stores a complete upload as a bill awaiting its file
+func (s *BillService) createResumableUploadBill(ctx context.Context, upload *domain.ResumableUpload) error {
+	storagePath, err := s.uploadStager.FinishUpload(ctx, upload.StorageKey, upload.StagingHandle,
+		ports.FileMetadata{ContentType: upload.ContentType, Filename: filepath.Base(upload.Filename)})
+	if err != nil {
+		return fmt.Errorf("error storing uploaded file: %w", err)
+	}
+
+	bill, err := domain.NewBill(upload.UserID, upload.Filename, storagePath, upload.ContentType)
+	if err != nil {
+		s.fileStore.DeleteFile(ctx, storagePath)
+		return fmt.Errorf("error creating bill record: %w", err)
+	}
+	bill.Status = domain.BillStatusAwaitingUpload
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(bill).Error; err != nil {
+			return err
+		}
+		return tx.Model(upload).Update("bill_id", bill.ID).Error
+	})
+	if err != nil {
+		s.fileStore.DeleteFile(ctx, storagePath)
+		return fmt.Errorf("error saving bill to database: %w", err)
+	}
+	upload.BillID = &bill.ID
+	return nil
+}
+
+// completeResumableUpload completes the bill of a complete upload like a direct
+// upload. Uploads whose file fails validation are deleted with their bill.
+func (s *BillService) completeResumableUpload(ctx context.Context, upload *domain.ResumableUpload, maxFileBytes int64) (*domain.BillWithURL, error) {
+	billWithURL, err := s.CompleteBillUpload(ctx, *upload.BillID, upload.UserID, maxFileBytes, textDetectionConfig(upload.Options))
+	switch {
+	case errors.Is(err, domain.ErrBillUploadCompleted):
+		return s.GetBill(ctx, *upload.BillID, upload.UserID)
+	case domain.UploadErrorCode(err) != "":
+		s.db.WithContext(ctx).Delete(upload)
+	}
+	return billWithURL, err
+}
+
+// DeleteResumableUpload discards an upload and the chunks received so far. The
+// bill of a complete upload is kept.
+func (s *BillService) DeleteResumableUpload(ctx context.Context, uploadID, userID uuid.UUID) error {
+	// Expired uploads can be discarded too, so they are not checked like in GetResumableUpload
+	upload, release, err := s.claimResumableUpload(ctx, uploadID)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if upload.UserID != userID {
+		return domain.ErrResumableUploadNotFound
+	}
+	return s.discardResumableUpload(ctx, upload)
+}
+
+// ExpireResumableUploads discards the uploads that received no chunk for longer
+// than the upload TTL and returns how many were discarded. Uploads receiving a
+// chunk are left for the next run.
+func (s *BillService) ExpireResumableUploads(ctx context.Context) (int, error) {
+	if s.uploadStager == nil {
+		return 0, nil
+	}
+	var uploads []domain.ResumableUpload
+	if err := s.db.WithContext(ctx).Where("expires_at < ?", time.Now().UTC()).Find(&uploads).Error; err != nil {
+		return 0, fmt.Errorf("error loading expired uploads: %w", err)
+	}
+
+	expired := 0
+	for i := range uploads {
+		upload, release, err := s.claimResumableUpload(ctx, uploads[i].ID)
+		if err != nil {
+			if !errors.Is(err, domain.ErrResumableUploadLocked) && !errors.Is(err, domain.ErrResumableUploadNotFound) {
+				log.Printf("Failed to discard expired upload %s: %v", uploads[i].ID, err)
+			}
+			continue
+		}
+
+		// A chunk may have been received since the uploads were loaded
+		if time.Now().After(upload.ExpiresAt) {
+			if err := s.discardResumableUpload(ctx, upload); err != nil {
+				log.Printf("Failed to discard expired upload %s: %v", upload.ID, err)
+			} else {
+				expired++
+			}
+		}
+		release()
+	}
+	return expired, nil
+}
+
+// discardResumableUpload deletes an upload, first discarding its chunks when it
+// didn't become a bill
+func (s *BillService) discardResumableUpload(ctx context.Context, upload *domain.ResumableUpload) error {
+	if upload.BillID == nil {
+		if s.uploadStager == nil {
+			return errResumableUploadsUnavailable
+		}
+		if err := s.uploadStager.AbortUpload(ctx, upload.StorageKey, upload.StagingHandle); err != nil {
+			return fmt.Errorf("error discarding uploaded chunks: %w", err)
+		}
+	}
+	if err := s.db.WithContext(ctx).Delete(upload).Error; err != nil {
+		return fmt.Errorf("error deleting upload: %w", err)
+	}
+	return nil
+}
+
+// claimResumableUpload takes the lease of an upload for one request, so its
+// chunks are written one at a time across API instances, and returns the upload
+// with a function releasing it. The lease is renewed until it is released, and
+// only outlives a request whose API instance stopped. It fails with
+// domain.ErrResumableUploadLocked while another request holds the lease.
+func (s *BillService) claimResumableUpload(ctx context.Context, uploadID uuid.UUID) (*domain.ResumableUpload, func(), error) {
+	token := uuid.New()
+	now := time.Now().UTC()
+	result := s.db.WithContext(ctx).Model(&domain.ResumableUpload{}).
+		Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", uploadID, now).
+		UpdateColumns(map[string]interface{}{
+			"lock_token":   token,
+			"locked_until": now.Add(resumableUploadLease),
+		})
+	if result.Error != nil {
+		return nil, nil, fmt.Errorf("error locking upload: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := s.db.WithContext(ctx).Model(&domain.ResumableUpload{}).Where("id = ?", uploadID).Count(&count).Error; err != nil {
+			return nil, nil, fmt.Errorf("error retrieving upload: %w", err)
+		}
+		if count > 0 {
+			return nil, nil, domain.ErrResumableUploadLocked
+		}
+		return nil, nil, domain.ErrResumableUploadNotFound
+	}
+
+	stop, renewed := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(renewed)
+		ticker := time.NewTicker(resumableUploadLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := s.db.Model(&domain.ResumableUpload{}).Where("id = ? AND lock_token = ?", uploadID, token).
+					UpdateColumn("locked_until", time.Now().Add(resumableUploadLease).UTC()).Error
+				if err != nil {
+					log.Printf("Failed to renew the lease of upload %s: %v", uploadID, err)
+				}
+			}
+		}
+	}()
+	release := func() {
+		close(stop)
+		<-renewed
+
+		// The request context may already be cancelled
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := s.db.WithContext(ctx).Model(&domain.ResumableUpload{}).Where("id = ? AND lock_token = ?", uploadID, token).
+			UpdateColumns(map[string]interface{}{"lock_token": nil, "locked_until": nil}).Error
+		if err != nil {
+			log.Printf("Failed to release the lease of upload %s: %v", uploadID, err)
+		}
+	}
+
+	var upload domain.ResumableUpload
+	if err := s.db.WithContext(ctx).First(&upload, "id = ? AND lock_token = ?", uploadID, token).Error; err != nil {
+		release()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, domain.ErrResumableUploadLocked
+		}
+		return nil, nil, fmt.Errorf("error retrieving upload: %w", err)
+	}
+	return &upload, release, nil
+}
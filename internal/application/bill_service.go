@@ -1,10 +1,16 @@
 package application
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/dgsaltarin/SharedBitesBackend/internal/adapters/driven/texttrack"
 	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
@@ -15,106 +21,514 @@ import (
 )
 
 type BillService struct {
-	textractClient *aws.TextractClient
-	fileStore      ports.FileStore
-	textProcessor  ports.TextProcessor
-	db             *gorm.DB
+	textractClient  *aws.TextractClient
+	fileStore       ports.FileStore
+	textProcessor   ports.TextProcessor
+	imageProcessor  ports.ImageProcessor
+	previewRenderer ports.PreviewRenderer
+	jobQueue        ports.JobQueue
+	events          ports.BillEventBus
+	groupEvents     *GroupEventService
+	retryPolicy     domain.RetryPolicy
+	duplicates      domain.DuplicatePolicy
+	uploadStager    ports.UploadStager
+	uploadTTL       time.Duration // how long resumable uploads last without receiving a chunk
+	maxImagePixels  int           // largest width times height of images decoded to validate them, 0 for no limit
+	db              *gorm.DB
 }
 
 func NewBillService(
 	textractClient *aws.TextractClient,
 	fileStore ports.FileStore,
 	textProcessor ports.TextProcessor,
+	imageProcessor ports.ImageProcessor,
+	previewRenderer ports.PreviewRenderer,
+	jobQueue ports.JobQueue,
+	events ports.BillEventBus,
+	groupEvents *GroupEventService,
+	retryPolicy domain.RetryPolicy,
+	duplicates domain.DuplicatePolicy,
+	uploadStager ports.UploadStager,
+	uploadTTL time.Duration,
+	maxImagePixels int,
 	db *gorm.DB,
 ) *BillService {
 	return &BillService{
-		textractClient: textractClient,
-		fileStore:      fileStore,
-		textProcessor:  textProcessor,
-		db:             db,
+		textractClient:  textractClient,
+		fileStore:       fileStore,
+		textProcessor:   textProcessor,
+		imageProcessor:  imageProcessor,
+		previewRenderer: previewRenderer,
+		jobQueue:        jobQueue,
+		events:          events,
+		groupEvents:     groupEvents,
+		retryPolicy:     retryPolicy,
+		duplicates:      duplicates,
+		uploadStager:    uploadStager,
+		uploadTTL:       uploadTTL,
+		maxImagePixels:  maxImagePixels,
+		db:              db,
 	}
 }
 
-// UploadAndAnalyzeBillWithConfig handles uploading a bill file and immediately analyzing it with custom configuration
+// UploadBill handles uploading a bill file and queueing it for analysis with the default configuration
+func (s *BillService) UploadBill(ctx context.Context, req domain.UploadBillRequest) (*domain.Bill, error) {
+	billWithURL, err := s.UploadBillWithConfig(ctx, req, texttrack.DefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	return billWithURL.Bill, nil
+}
+
+// UploadBillWithConfig handles uploading a bill file and queueing it for analysis with custom configuration.
+// The bill is returned right away in pending status; a worker runs the analysis in the background.
 // This allows for language-specific optimization and custom confidence thresholds
-func (s *BillService) UploadAndAnalyzeBillWithConfig(ctx context.Context, req domain.UploadBillRequest, config texttrack.TextDetectionConfig) (*domain.BillWithURL, error) {
+func (s *BillService) UploadBillWithConfig(ctx context.Context, req domain.UploadBillRequest, config texttrack.TextDetectionConfig) (*domain.BillWithURL, error) {
+	bill, err := s.storeBill(ctx, req, config, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate a pre-signed URL for the bill file
+	fileURL, err := s.fileStore.GetFileURL(ctx, bill.FileStoragePath)
+	if err != nil {
+		// Log the error but continue as this is not critical
+		fmt.Printf("Warning: Failed to generate pre-signed URL for bill %s: %v\n", bill.ID, err)
+		fileURL = "" // Empty URL if we couldn't generate one
+	}
+
+	return &domain.BillWithURL{
+		Bill:    bill,
+		FileURL: fileURL,
+	}, nil
+}
+
+// storeBill uploads the file, creates its bill in pending status and queues the analysis.
+// prepare, if set, can fill in the bill before it is saved.
+func (s *BillService) storeBill(ctx context.Context, req domain.UploadBillRequest, config texttrack.TextDetectionConfig, prepare func(bill *domain.Bill) error) (*domain.Bill, error) {
 	if req.UserID == uuid.Nil {
 		return nil, domain.ErrUserIDEmpty
 	}
-	if req.File == nil || req.Filename == "" || req.ContentType == "" {
+	if req.File == nil || req.Filename == "" {
 		return nil, domain.ErrInvalidInput
 	}
 
-	// Create a storage path for the file
-	storagePath := fmt.Sprintf("bills/%s/%s", req.UserID, filepath.Base(req.Filename))
-
-	// Upload the file to storage
-	storedPath, err := s.fileStore.UploadFile(ctx, req.File, storagePath, req.ContentType)
+	stored, err := s.uploadBillFile(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("error uploading bill file: %w", err)
+		return nil, err
 	}
 
 	// Create bill record in database
-	bill, err := domain.NewBill(req.UserID, req.Filename, storedPath, req.ContentType)
+	bill, err := domain.NewBill(req.UserID, req.Filename, stored.path, stored.contentType)
 	if err != nil {
 		// If there was an error creating the bill record, try to delete the uploaded file
-		s.fileStore.DeleteFile(ctx, storedPath)
+		s.deleteBillFiles(ctx, stored.path, stored.originalPath)
 		return nil, fmt.Errorf("error creating bill record: %w", err)
 	}
+	bill.OriginalStoragePath = stored.originalPath
+	bill.ContentHash = &stored.contentHash
+	bill.PerceptualHash = stored.perceptualHash
+	bill.Status = domain.BillStatusPending
+	if prepare != nil {
+		if err := prepare(bill); err != nil {
+			s.deleteBillFiles(ctx, stored.path, stored.originalPath)
+			return nil, err
+		}
+	}
+
+	// Look for the same upload among the user's bills and the group's
+	if err := s.checkUploadDuplicate(ctx, bill); err != nil {
+		s.deleteBillFiles(ctx, stored.path, stored.originalPath)
+		return nil, err
+	}
 
 	// Save bill to database
 	if err := s.db.Create(bill).Error; err != nil {
 		// If there was an error saving to the database, try to delete the uploaded file
-		s.fileStore.DeleteFile(ctx, storedPath)
+		s.deleteBillFiles(ctx, stored.path, stored.originalPath)
 		return nil, fmt.Errorf("error saving bill to database: %w", err)
 	}
 
-	// Update bill status to processing
-	if err := s.db.Model(bill).Update("status", domain.BillStatusProcessing).Error; err != nil {
+	// Queue the analysis
+	job, err := domain.NewAnalysisJob(bill.ID, analysisOptions(config))
+	if err != nil {
+		s.deleteBillFiles(ctx, stored.path, stored.originalPath)
+		s.db.Delete(bill)
+		return nil, fmt.Errorf("error creating analysis job: %w", err)
+	}
+	if err := s.jobQueue.Enqueue(ctx, job); err != nil {
 		// Clean up on error
-		s.fileStore.DeleteFile(ctx, storedPath)
+		s.deleteBillFiles(ctx, stored.path, stored.originalPath)
 		s.db.Delete(bill)
-		return nil, fmt.Errorf("error updating bill status to processing: %w", err)
+		return nil, fmt.Errorf("error queueing bill analysis: %w", err)
 	}
+	s.publishBillEvent(bill)
 
-	// Cast textProcessor to the specific adapter type to access enhanced method
-	textAdapter, ok := s.textProcessor.(*texttrack.AWSTextractAdapter)
-	if !ok {
-		// Fallback to standard analysis if the adapter type is not available
-		return nil, fmt.Errorf("enhanced text processing not available")
+	return bill, nil
+}
+
+// storedBillFile is where an uploaded bill file was stored
+type storedBillFile struct {
+	path           string
+	originalPath   *string // the file as uploaded, when path holds a normalized copy
+	contentType    string
+	contentHash    string // SHA-256 of the file as uploaded
+	perceptualHash *int64
+}
+
+// preparedBillFile is an uploaded bill file that passed validation, normalized
+// for storage when an image processor is set
+type preparedBillFile struct {
+	contentType string
+	contentHash string // SHA-256 of the file as uploaded
+	normalized  *ports.NormalizedImage
+}
+
+// uploadBillFile stores an uploaded bill file under a new unique key, keeping its
+// name as metadata. Images are normalized first when an image processor is set,
+// and the original is kept next to the normalized copy.
+func (s *BillService) uploadBillFile(ctx context.Context, req domain.UploadBillRequest) (*storedBillFile, error) {
+	content, err := io.ReadAll(req.File)
+	if err != nil {
+		return nil, fmt.Errorf("error reading bill file: %w", err)
 	}
 
-	// Analyze the document with enhanced configuration
-	result, err := textAdapter.AnalyzeDocumentWithConfig(ctx, bill.FileStoragePath, config)
+	prepared, err := s.prepareBillFile(ctx, content, req.Filename)
 	if err != nil {
-		// Update bill status to failed
-		s.db.Model(bill).Updates(map[string]interface{}{
-			"status": domain.BillStatusFailed,
+		return nil, err
+	}
+
+	return s.storeBillFile(ctx, req.UserID, req.Filename, prepared,
+		func(key string, metadata ports.FileMetadata) (string, error) {
+			return s.fileStore.UploadFile(ctx, bytes.NewReader(content), key, metadata)
 		})
-		return nil, fmt.Errorf("error analyzing bill with enhanced Textract: %w", err)
+}
+
+// prepareBillFile checks the type and integrity of an uploaded file and
+// normalizes it when an image processor is set
+func (s *BillService) prepareBillFile(ctx context.Context, content []byte, filename string) (*preparedBillFile, error) {
+	sum := sha256.Sum256(content)
+	contentHash := hex.EncodeToString(sum[:])
+
+	// The declared content type comes from the client and is not trusted
+	contentType, err := s.detectBillFileType(content)
+	if err != nil {
+		return nil, err
 	}
 
-	// Start a transaction to update the bill and create line items
-	tx := s.db.Begin()
-	if tx.Error != nil {
-		return nil, fmt.Errorf("error starting transaction: %w", tx.Error)
+	normalized := &ports.NormalizedImage{Content: content, ContentType: contentType}
+	if s.imageProcessor != nil {
+		normalized, err = s.imageProcessor.Normalize(ctx, content, filename, contentType)
+		if errors.Is(err, domain.ErrCorruptFile) {
+			return nil, err
+		}
+		if err != nil {
+			if convertibleFileTypes[contentType] {
+				return nil, fmt.Errorf("error converting bill image: %w", err)
+			}
+			// Log the error but continue, Textract can still read the file as uploaded
+			fmt.Printf("Warning: Failed to normalize bill image %s: %v\n", filename, err)
+			normalized = &ports.NormalizedImage{Content: content, ContentType: contentType}
+		}
 	}
 
-	// Update bill with extracted information
-	billUpdates := map[string]interface{}{
-		"vendor_name":       result.VendorName,
-		"transaction_date":  result.TransactionDate,
-		"total_amount":      result.TotalAmount,
-		"text_track_output": result.RawTextOutput,
-		"status":            domain.BillStatusAnalyzed,
+	return &preparedBillFile{contentType: contentType, contentHash: contentHash, normalized: normalized}, nil
+}
+
+// storeBillFile stores a prepared bill file under new unique keys. storeOriginal
+// stores the file as uploaded under the given key and returns its storage path.
+func (s *BillService) storeBillFile(ctx context.Context, userID uuid.UUID, filename string, prepared *preparedBillFile,
+	storeOriginal func(key string, metadata ports.FileMetadata) (string, error)) (*storedBillFile, error) {
+	// Files are stored under a random key, so uploads with the same name don't overwrite each other
+	fileID := uuid.New()
+	filename = filepath.Base(filename)
+	normalized := prepared.normalized
+
+	if !normalized.Changed {
+		storedPath, err := storeOriginal(billStorageKey(userID, fileID, billFileExtensions[prepared.contentType]),
+			ports.FileMetadata{ContentType: prepared.contentType, Filename: filename})
+		if err != nil {
+			return nil, fmt.Errorf("error uploading bill file: %w", err)
+		}
+		return &storedBillFile{
+			path:           storedPath,
+			contentType:    prepared.contentType,
+			contentHash:    prepared.contentHash,
+			perceptualHash: normalized.PerceptualHash,
+		}, nil
 	}
 
-	if err := tx.Model(bill).Updates(billUpdates).Error; err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("error updating bill with extracted data: %w", err)
+	originalPath, err := storeOriginal(originalBillStorageKey(userID, fileID, billFileExtensions[prepared.contentType]),
+		ports.FileMetadata{ContentType: prepared.contentType, Filename: filename})
+	if err != nil {
+		return nil, fmt.Errorf("error uploading original bill file: %w", err)
+	}
+
+	normalizedFilename := strings.TrimSuffix(filename, filepath.Ext(filename)) + normalized.Extension
+	storedPath, err := s.fileStore.UploadFile(ctx, bytes.NewReader(normalized.Content),
+		billStorageKey(userID, fileID, normalized.Extension),
+		ports.FileMetadata{ContentType: normalized.ContentType, Filename: normalizedFilename})
+	if err != nil {
+		s.fileStore.DeleteFile(ctx, originalPath)
+		return nil, fmt.Errorf("error uploading bill file: %w", err)
+	}
+
+	return &storedBillFile{
+		path:           storedPath,
+		originalPath:   &originalPath,
+		contentType:    normalized.ContentType,
+		contentHash:    prepared.contentHash,
+		perceptualHash: normalized.PerceptualHash,
+	}, nil
+}
+
+// deleteBillFiles removes the stored files of a bill, ignoring errors as the
+// callers are already cleaning up after one
+func (s *BillService) deleteBillFiles(ctx context.Context, storagePath string, originalPath *string) {
+	s.fileStore.DeleteFile(ctx, storagePath)
+	if originalPath != nil {
+		s.fileStore.DeleteFile(ctx, *originalPath)
+	}
+}
+
+// AnalyzeBill analyzes a stored bill with the default configuration
+func (s *BillService) AnalyzeBill(ctx context.Context, billID uuid.UUID) error {
+	err := s.analyzeBill(ctx, billID, texttrack.DefaultConfig(), nil)
+	if errors.Is(err, domain.ErrAnalysisSuperseded) {
+		return nil
+	}
+	if err != nil {
+		s.recordAnalysisFailure(billID, domain.BillStatusFailed, err)
+		return err
+	}
+	return nil
+}
+
+// ProcessAnalysisJob runs a queued analysis job. It is called by the analysis workers.
+// Temporary failures are returned as a domain.AnalysisRetryError while the job has attempts left;
+// the bill goes back to pending meanwhile, and is marked failed once the job is dead-lettered.
+// domain.ErrAnalysisSuperseded is returned while another analysis owns the bill, leaving the job to it.
+func (s *BillService) ProcessAnalysisJob(ctx context.Context, job *domain.AnalysisJob) error {
+	// Render the previews first, so they are shown while the bill is analyzed
+	s.renderPendingPreviews(ctx, job.BillID)
+
+	// Multi-page documents resume the Textract job of an earlier attempt, which
+	// may have run out of time while Textract was still working
+	async := texttrack.AsyncAnalysis{
+		Started: func(textractJobID string) { s.recordTextractJob(job, textractJobID) },
+	}
+	if job.TextractJobID != nil {
+		async.JobID = *job.TextractJobID
+	}
+	ctx = texttrack.WithAsyncAnalysis(ctx, async)
+	err := s.analyzeBill(ctx, job.BillID, textDetectionConfig(job.Options), job.LockedAt)
+	if errors.Is(err, domain.ErrAnalysisSuperseded) {
+		// A bill analyzed meanwhile, by this job's previous worker or by hand,
+		// completes the job. Otherwise another analysis owns the bill now.
+		if s.billAnalyzed(job.BillID) {
+			return nil
+		}
+		return err
+	}
+	if err == nil || errors.Is(err, domain.ErrBillNotFound) {
+		return err
+	}
+
+	if s.retryPolicy.CanRetry(job, err) {
+		s.recordAnalysisFailure(job.BillID, domain.BillStatusPending, err)
+		return &domain.AnalysisRetryError{Err: err, Delay: s.retryPolicy.Backoff(job.Attempts)}
+	}
+
+	s.recordAnalysisFailure(job.BillID, domain.BillStatusFailed, err)
+	return err
+}
+
+// recordTextractJob stores the asynchronous Textract job started for an analysis job
+func (s *BillService) recordTextractJob(job *domain.AnalysisJob, textractJobID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.jobQueue.SetTextractJob(ctx, job, textractJobID); err != nil {
+		fmt.Printf("Warning: Failed to record Textract job %s of analysis job %s: %v\n", textractJobID, job.ID, err)
+		return
+	}
+	job.TextractJobID = &textractJobID
+}
+
+// ListDeadLetteredAnalyses returns the analysis jobs that failed for good
+func (s *BillService) ListDeadLetteredAnalyses(ctx context.Context) ([]*domain.AnalysisJob, error) {
+	return s.jobQueue.ListFailed(ctx)
+}
+
+// RetryAnalysis queues a dead-lettered analysis job again and puts its bill back to pending
+func (s *BillService) RetryAnalysis(ctx context.Context, jobID uuid.UUID) (*domain.AnalysisJob, error) {
+	if jobID == uuid.Nil {
+		return nil, domain.ErrInvalidInput
+	}
+
+	job, err := s.jobQueue.Requeue(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Model(&domain.Bill{}).
+		Where("id = ? AND status = ?", job.BillID, domain.BillStatusFailed).
+		Update("status", domain.BillStatusPending).Error
+	if err != nil {
+		return nil, fmt.Errorf("error updating bill status to pending: %w", err)
+	}
+	s.publishBillStatus(job.BillID)
+
+	return job, nil
+}
+
+// claimBillAnalysis atomically marks a bill as processing for one analysis and
+// counts the attempt. It returns the token the analysis saves its results with,
+// and false when the bill is analyzed or another analysis is processing it. A
+// processing bill is taken over by a job handed out again after its lease
+// expired, as its earlier claim predates leasedAt; without leasedAt it isn't.
+func (s *BillService) claimBillAnalysis(ctx context.Context, billID uuid.UUID, leasedAt *time.Time) (uuid.UUID, bool, error) {
+	token := uuid.New()
+	query := s.db.WithContext(ctx).Model(&domain.Bill{}).Where("id = ? AND status <> ?", billID, domain.BillStatusAnalyzed)
+	if leasedAt != nil {
+		query = query.Where("(status <> ? OR analysis_claimed_at IS NULL OR analysis_claimed_at < ?)", domain.BillStatusProcessing, *leasedAt)
+	} else {
+		query = query.Where("status <> ?", domain.BillStatusProcessing)
+	}
+
+	result := query.Updates(map[string]interface{}{
+		"status":              domain.BillStatusProcessing,
+		"analysis_attempts":   gorm.Expr("analysis_attempts + 1"),
+		"analysis_token":      token,
+		"analysis_claimed_at": time.Now().UTC(),
+	})
+	if result.Error != nil {
+		return uuid.Nil, false, fmt.Errorf("error updating bill status to processing: %w", result.Error)
+	}
+	return token, result.RowsAffected > 0, nil
+}
+
+// billAnalyzed reports whether a bill holds the results of a finished analysis
+func (s *BillService) billAnalyzed(billID uuid.UUID) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var count int64
+	err := s.db.WithContext(ctx).Model(&domain.Bill{}).Where("id = ? AND status = ?", billID, domain.BillStatusAnalyzed).Count(&count).Error
+	return err == nil && count > 0
+}
+
+// holdsBillAnalysis reports whether the analysis with token still owns the bill,
+// so that its failures are only recorded while no other analysis took it over
+func (s *BillService) holdsBillAnalysis(billID, token uuid.UUID) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var count int64
+	err := s.db.WithContext(ctx).Model(&domain.Bill{}).Where("id = ? AND analysis_token = ?", billID, token).Count(&count).Error
+	return err != nil || count > 0
+}
+
+// recordAnalysisFailure stores the error of the last analysis attempt on the bill
+func (s *BillService) recordAnalysisFailure(billID uuid.UUID, status domain.BillStatus, analysisErr error) {
+	// The analysis context may already be cancelled or expired
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := s.db.WithContext(ctx).Model(&domain.Bill{}).Where("id = ?", billID).Updates(map[string]interface{}{
+		"status":              status,
+		"last_analysis_error": analysisErr.Error(),
+	}).Error
+	if err != nil {
+		fmt.Printf("Warning: Failed to record analysis failure for bill %s: %v\n", billID, err)
+		return
+	}
+	s.publishBillStatus(billID)
+}
+
+// publishBillStatus notifies the owner of a bill of its current status
+func (s *BillService) publishBillStatus(billID uuid.UUID) {
+	if s.events == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var bill domain.Bill
+	if err := s.db.WithContext(ctx).First(&bill, "id = ?", billID).Error; err != nil {
+		fmt.Printf("Warning: Failed to load bill %s to publish its status: %v\n", billID, err)
+		return
+	}
+	s.publishBillEvent(&bill)
+}
+
+// publishBillEvent notifies the owner of a bill of its status. Events are best effort:
+// clients that miss one still find the status with GET /bills/:bill_id/status.
+func (s *BillService) publishBillEvent(bill *domain.Bill) {
+	if s.events == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.events.Publish(ctx, domain.NewBillStatusEvent(bill)); err != nil {
+		fmt.Printf("Warning: Failed to publish status of bill %s: %v\n", bill.ID, err)
+	}
+}
+
+// analyzeBill extracts the data of a stored bill with Textract and saves it with its line items.
+// Bills that were already analyzed are left untouched, and only the analysis that last claimed
+// the bill saves its results, so a job handed out twice is harmless. leasedAt is when the job
+// running the analysis was handed out, nil outside of jobs.
+func (s *BillService) analyzeBill(ctx context.Context, billID uuid.UUID, config texttrack.TextDetectionConfig, leasedAt *time.Time) error {
+	if billID == uuid.Nil {
+		return domain.ErrInvalidInput
+	}
+
+	var bill domain.Bill
+	if err := s.db.WithContext(ctx).First(&bill, "id = ?", billID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.ErrBillNotFound
+		}
+		return fmt.Errorf("error retrieving bill: %w", err)
+	}
+	if bill.Status == domain.BillStatusAnalyzed {
+		return nil
+	}
+
+	token, claimed, err := s.claimBillAnalysis(ctx, bill.ID, leasedAt)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return domain.ErrAnalysisSuperseded
+	}
+	bill.Status = domain.BillStatusProcessing
+	s.publishBillEvent(&bill)
+
+	// Cast textProcessor to a document analyzer to access the enhanced method
+	textAdapter, ok := s.textProcessor.(texttrack.DocumentAnalyzer)
+	if !ok {
+		return fmt.Errorf("enhanced text processing not available")
+	}
+
+	// Analyze the document with enhanced configuration
+	result, err := textAdapter.AnalyzeDocumentWithConfig(ctx, bill.FileStoragePath, config)
+	if err != nil {
+		if !s.holdsBillAnalysis(bill.ID, token) {
+			return domain.ErrAnalysisSuperseded
+		}
+		return fmt.Errorf("error analyzing bill with enhanced Textract: %w", err)
+	}
+	run, err := domain.NewAnalysisRun(bill.ID, analysisOptions(config), texttrack.ParserVersion, result.RawResponse)
+	if err != nil {
+		return err
 	}
 
 	// Create line items from extracted data
+	lineItems := make([]*domain.LineItem, 0, len(result.LineItems))
 	for _, item := range result.LineItems {
 		lineItem, err := domain.NewLineItem(
 			bill.ID,
@@ -124,41 +538,283 @@ func (s *BillService) UploadAndAnalyzeBillWithConfig(ctx context.Context, req do
 			item.TotalPrice,
 		)
 		if err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("error creating line item: %w", err)
+			return fmt.Errorf("error creating line item: %w", err)
 		}
+		lineItem.ID = uuid.New()
+		lineItem.PageNumber = item.PageNumber
+		lineItem.ExtractionKey = item.Key
+		lineItem.FieldDetections = item.Detections
+		lineItems = append(lineItems, lineItem)
+		bill.LineItems = append(bill.LineItems, *lineItem)
+	}
 
+	// Check that the extracted amounts add up before saving them
+	bill.VendorName = result.VendorName
+	bill.TransactionDate = result.TransactionDate
+	bill.TotalAmount = result.TotalAmount
+	bill.SubtotalAmount = result.SubtotalAmount
+	bill.TaxAmount = result.TaxAmount
+	bill.TipAmount = result.TipAmount
+	bill.DiscountAmount = result.DiscountAmount
+	bill.Reconcile()
+
+	// Start a transaction to update the bill and create line items
+	tx := s.db.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return fmt.Errorf("error starting transaction: %w", tx.Error)
+	}
+
+	// Update bill with extracted information
+	billUpdates := map[string]interface{}{
+		"vendor_name":           result.VendorName,
+		"transaction_date":      result.TransactionDate,
+		"total_amount":          result.TotalAmount,
+		"subtotal_amount":       result.SubtotalAmount,
+		"tax_amount":            result.TaxAmount,
+		"tip_amount":            result.TipAmount,
+		"discount_amount":       result.DiscountAmount,
+		"text_track_output":     result.RawTextOutput,
+		"field_detections":      result.Detections,
+		"reconciliation_status": bill.ReconciliationStatus,
+		"discrepancies":         bill.Discrepancies,
+		"fingerprint":           bill.ReceiptFingerprint(),
+		"last_analysis_error":   nil,
+		"status":                domain.BillStatusAnalyzed,
+		"processed_at":          time.Now().UTC(),
+	}
+
+	// Only the analysis holding the claim saves, another one took the bill over otherwise
+	updated := tx.Model(&bill).Omit("LineItems").Where("analysis_token = ?", token).Updates(billUpdates)
+	if updated.Error != nil {
+		tx.Rollback()
+		return fmt.Errorf("error updating bill with extracted data: %w", updated.Error)
+	}
+	if updated.RowsAffected == 0 {
+		tx.Rollback()
+		return domain.ErrAnalysisSuperseded
+	}
+
+	for _, lineItem := range lineItems {
 		if err := tx.Create(lineItem).Error; err != nil {
 			tx.Rollback()
-			return nil, fmt.Errorf("error saving line item: %w", err)
+			return fmt.Errorf("error saving line item: %w", err)
 		}
 	}
 
+	// Keep the full response, to parse it again without another analysis
+	if err := tx.Create(run).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error saving analysis response: %w", err)
+	}
+
 	// Commit the transaction
 	if err := tx.Commit().Error; err != nil {
-		return nil, fmt.Errorf("error committing transaction: %w", err)
+		return fmt.Errorf("error committing transaction: %w", err)
 	}
+	s.flagReceiptDuplicate(ctx, &bill)
+	s.publishBillStatus(bill.ID)
 
-	// Reload the bill with line items
-	var updatedBill domain.Bill
-	if err := s.db.Preload("LineItems").First(&updatedBill, "id = ?", bill.ID).Error; err != nil {
-		return nil, fmt.Errorf("error reloading bill with line items: %w", err)
+	return nil
+}
+
+// ReanalyzeBill runs the text processor again on the stored file of a bill with a different configuration.
+// Extracted values and line items are replaced in a transaction, while manual corrections are kept unless
+// overwriteCorrections is set.
+func (s *BillService) ReanalyzeBill(ctx context.Context, billID, userID uuid.UUID, config texttrack.TextDetectionConfig, overwriteCorrections bool) (*domain.BillWithURL, error) {
+	if billID == uuid.Nil {
+		return nil, domain.ErrInvalidInput
 	}
 
-	// Generate a pre-signed URL for the bill file
-	fileURL, err := s.fileStore.GetFileURL(ctx, updatedBill.FileStoragePath)
+	var bill domain.Bill
+	err := s.db.WithContext(ctx).Preload("LineItems").First(&bill, "id = ? AND user_id = ?", billID, userID).Error
 	if err != nil {
-		// Log the error but continue as this is not critical
-		fmt.Printf("Warning: Failed to generate pre-signed URL for bill %s: %v\n", updatedBill.ID, err)
-		fileURL = "" // Empty URL if we couldn't generate one
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrBillNotFound
+		}
+		return nil, fmt.Errorf("error retrieving bill: %w", err)
+	}
+	if !bill.IsEditable() {
+		return nil, domain.ErrBillNotEditable
 	}
 
-	return &domain.BillWithURL{
-		Bill:    &updatedBill,
-		FileURL: fileURL,
+	textAdapter, ok := s.textProcessor.(texttrack.DocumentAnalyzer)
+	if !ok {
+		return nil, fmt.Errorf("enhanced text processing not available")
+	}
+
+	restoreStatus, err := s.markBillReanalyzing(ctx, &bill)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := textAdapter.AnalyzeDocumentWithConfig(ctx, bill.FileStoragePath, config)
+	if err != nil {
+		// The previous analysis is still valid
+		restoreStatus()
+		return nil, fmt.Errorf("error analyzing bill with enhanced Textract: %w", err)
+	}
+
+	run, err := domain.NewAnalysisRun(bill.ID, analysisOptions(config), texttrack.ParserVersion, parsed.RawResponse)
+	if err != nil {
+		restoreStatus()
+		return nil, err
+	}
+	err = s.saveReanalysis(ctx, &bill, parsed, overwriteCorrections, func(tx *gorm.DB) error {
+		if err := tx.Create(run).Error; err != nil {
+			return fmt.Errorf("error saving analysis response: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		restoreStatus()
+		return nil, err
+	}
+	s.flagReceiptDuplicate(ctx, &bill)
+	s.publishBillStatus(bill.ID)
+
+	return s.GetBill(ctx, bill.ID, userID)
+}
+
+// markBillReanalyzing marks an analyzed bill as processing, so corrections can't
+// race with a new analysis, and returns a function restoring its previous status
+func (s *BillService) markBillReanalyzing(ctx context.Context, bill *domain.Bill) (func(), error) {
+	previousStatus := bill.Status
+	result := s.db.WithContext(ctx).Model(&domain.Bill{}).
+		Where("id = ? AND version = ? AND status = ?", bill.ID, bill.Version, previousStatus).
+		Update("status", domain.BillStatusProcessing)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error updating bill status to processing: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, domain.ErrBillVersionConflict
+	}
+	s.publishBillStatus(bill.ID)
+
+	return func() {
+		s.db.Model(&domain.Bill{}).Where("id = ?", bill.ID).Update("status", previousStatus)
+		s.publishBillStatus(bill.ID)
 	}, nil
 }
 
+// saveReanalysis replaces the extracted values and line items of a bill marked
+// as processing with freshly parsed data, keeping manual corrections unless
+// overwriteCorrections is set. saveRun stores the analysis the data was parsed
+// from in the same transaction.
+func (s *BillService) saveReanalysis(ctx context.Context, bill *domain.Bill, parsed *ports.ParsedTextractData, overwriteCorrections bool, saveRun func(tx *gorm.DB) error) error {
+	data, err := extractedBillData(bill.ID, parsed)
+	if err != nil {
+		return err
+	}
+
+	var deletedLineItems []domain.LineItem
+	if err := s.db.WithContext(ctx).Unscoped().Where("bill_id = ? AND deleted_by_user", bill.ID).Find(&deletedLineItems).Error; err != nil {
+		return fmt.Errorf("error retrieving deleted line items: %w", err)
+	}
+
+	reanalysis := bill.Reanalyze(data, deletedLineItems, overwriteCorrections)
+	processedAt := time.Now().UTC()
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		billUpdates := map[string]interface{}{
+			"vendor_name":           bill.VendorName,
+			"transaction_date":      bill.TransactionDate,
+			"total_amount":          bill.TotalAmount,
+			"subtotal_amount":       bill.SubtotalAmount,
+			"tax_amount":            bill.TaxAmount,
+			"tip_amount":            bill.TipAmount,
+			"discount_amount":       bill.DiscountAmount,
+			"text_track_output":     data.RawText,
+			"field_detections":      bill.FieldDetections,
+			"reconciliation_status": bill.ReconciliationStatus,
+			"discrepancies":         bill.Discrepancies,
+			"edited_fields":         bill.EditedFields,
+			"fingerprint":           bill.ReceiptFingerprint(),
+			"version":               bill.Version,
+			"status":                domain.BillStatusAnalyzed,
+			"processed_at":          processedAt,
+		}
+		result := tx.Model(&domain.Bill{}).Where("id = ? AND version = ?", bill.ID, reanalysis.BaseVersion).Updates(billUpdates)
+		if result.Error != nil {
+			return fmt.Errorf("error updating bill with extracted data: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return domain.ErrBillVersionConflict
+		}
+
+		// Replaced items are gone for good, only items users deleted are remembered
+		if len(reanalysis.RemovedLineItemIDs) > 0 {
+			if err := tx.Unscoped().Where("bill_id = ? AND id IN ?", bill.ID, reanalysis.RemovedLineItemIDs).Delete(&domain.LineItem{}).Error; err != nil {
+				return fmt.Errorf("error removing previous line items: %w", err)
+			}
+		}
+
+		for _, lineItem := range reanalysis.CreatedLineItems {
+			if err := tx.Create(lineItem).Error; err != nil {
+				return fmt.Errorf("error saving line item: %w", err)
+			}
+		}
+
+		if len(reanalysis.Revisions) > 0 {
+			if err := tx.Create(reanalysis.Revisions).Error; err != nil {
+				return fmt.Errorf("error recording bill revisions: %w", err)
+			}
+		}
+		return saveRun(tx)
+	})
+}
+
+// analysisOptions converts a text detection configuration into the options stored on an analysis job
+func analysisOptions(config texttrack.TextDetectionConfig) domain.AnalysisOptions {
+	return domain.AnalysisOptions{
+		Languages:     config.Languages,
+		MinConfidence: config.MinConfidence,
+		CurrencyCodes: config.CurrencyCodes,
+	}
+}
+
+// textDetectionConfig converts the options of an analysis job back into a text detection configuration
+func textDetectionConfig(options domain.AnalysisOptions) texttrack.TextDetectionConfig {
+	config := texttrack.DefaultConfig()
+	if len(options.Languages) > 0 {
+		config.Languages = options.Languages
+	}
+	if options.MinConfidence > 0 {
+		config.MinConfidence = options.MinConfidence
+	}
+	if len(options.CurrencyCodes) > 0 {
+		config.CurrencyCodes = options.CurrencyCodes
+	}
+	return config
+}
+
+// extractedBillData converts the text processor output into domain values for a bill
+func extractedBillData(billID uuid.UUID, result *ports.ParsedTextractData) (domain.ExtractedBillData, error) {
+	data := domain.ExtractedBillData{
+		VendorName:      result.VendorName,
+		TransactionDate: result.TransactionDate,
+		TotalAmount:     result.TotalAmount,
+		SubtotalAmount:  result.SubtotalAmount,
+		TaxAmount:       result.TaxAmount,
+		TipAmount:       result.TipAmount,
+		DiscountAmount:  result.DiscountAmount,
+		RawText:         result.RawTextOutput,
+		FieldDetections: result.Detections,
+	}
+
+	for _, item := range result.LineItems {
+		lineItem, err := domain.NewLineItem(billID, item.Description, item.Quantity, item.UnitPrice, item.TotalPrice)
+		if err != nil {
+			return domain.ExtractedBillData{}, fmt.Errorf("error creating line item: %w", err)
+		}
+		lineItem.PageNumber = item.PageNumber
+		lineItem.ExtractionKey = item.Key
+		lineItem.FieldDetections = item.Detections
+		data.LineItems = append(data.LineItems, lineItem)
+	}
+
+	return data, nil
+}
+
 // GetBill retrieves a bill and its line items by ID
 func (s *BillService) GetBill(ctx context.Context, billID, userID uuid.UUID) (*domain.BillWithURL, error) {
 	if billID == uuid.Nil {
@@ -166,7 +822,7 @@ func (s *BillService) GetBill(ctx context.Context, billID, userID uuid.UUID) (*d
 	}
 
 	var bill domain.Bill
-	err := s.db.Preload("LineItems").First(&bill, "id = ? AND user_id = ?", billID, userID).Error
+	err := s.db.Preload("LineItems.Assignments").First(&bill, "id = ? AND user_id = ?", billID, userID).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, domain.ErrBillNotFound
@@ -183,8 +839,9 @@ func (s *BillService) GetBill(ctx context.Context, billID, userID uuid.UUID) (*d
 	}
 
 	return &domain.BillWithURL{
-		Bill:    &bill,
-		FileURL: fileURL,
+		Bill:            &bill,
+		FileURL:         fileURL,
+		BillPreviewURLs: storedPreviewURLs(ctx, s.fileStore, &bill),
 	}, nil
 }
 
@@ -230,6 +887,44 @@ func (s *BillService) ListBills(ctx context.Context, userID uuid.UUID, options d
 	return bills, total, nil
 }
 
+// SubscribeBillEvents returns the current status of a bill owned by the user and a
+// subscription to the events of the user's bills, from which the caller picks the bill's.
+// The subscription is taken before reading the status so no transition is missed.
+func (s *BillService) SubscribeBillEvents(ctx context.Context, billID, userID uuid.UUID) (*domain.BillEvent, <-chan *domain.BillEvent, func(), error) {
+	if billID == uuid.Nil {
+		return nil, nil, nil, domain.ErrInvalidInput
+	}
+
+	events, cancel, err := s.SubscribeUserEvents(userID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var bill domain.Bill
+	if err := s.db.WithContext(ctx).First(&bill, "id = ? AND user_id = ?", billID, userID).Error; err != nil {
+		cancel()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil, domain.ErrBillNotFound
+		}
+		return nil, nil, nil, fmt.Errorf("error retrieving bill: %w", err)
+	}
+
+	return domain.NewBillStatusEvent(&bill), events, cancel, nil
+}
+
+// SubscribeUserEvents subscribes to the events of every bill of the user
+func (s *BillService) SubscribeUserEvents(userID uuid.UUID) (<-chan *domain.BillEvent, func(), error) {
+	if userID == uuid.Nil {
+		return nil, nil, domain.ErrUserIDEmpty
+	}
+	if s.events == nil {
+		return nil, nil, fmt.Errorf("bill events are not available")
+	}
+
+	events, cancel := s.events.Subscribe(userID)
+	return events, cancel, nil
+}
+
 // GetBillStatus retrieves just the status of a bill
 func (s *BillService) GetBillStatus(ctx context.Context, billID, userID uuid.UUID) (domain.BillStatus, error) {
 	if billID == uuid.Nil {
@@ -273,6 +968,30 @@ func (s *BillService) DeleteBill(ctx context.Context, billID, userID uuid.UUID)
 		return fmt.Errorf("error starting transaction: %w", tx.Error)
 	}
 
+	// Delete the claim session, if any; its claims cascade
+	if err := tx.Where("bill_id = ?", billID).Delete(&domain.ClaimSession{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting claim session: %w", err)
+	}
+
+	// Delete queued analysis jobs
+	if err := tx.Where("bill_id = ?", billID).Delete(&domain.AnalysisJob{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting analysis jobs: %w", err)
+	}
+
+	// Delete the edit history
+	if err := tx.Where("bill_id = ?", billID).Delete(&domain.BillRevision{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting bill revisions: %w", err)
+	}
+
+	// Delete the stored analysis responses
+	if err := tx.Where("bill_id = ?", billID).Delete(&domain.AnalysisRun{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting analysis runs: %w", err)
+	}
+
 	// Delete line items first (this should use cascading delete if set up in the database)
 	if err := tx.Where("bill_id = ?", billID).Delete(&domain.LineItem{}).Error; err != nil {
 		tx.Rollback()
@@ -296,6 +1015,19 @@ func (s *BillService) DeleteBill(ctx context.Context, billID, userID uuid.UUID)
 		// Log but don't fail the operation if file deletion fails
 		fmt.Printf("Warning: Failed to delete file for bill %s: %v\n", billID, err)
 	}
+	if bill.OriginalStoragePath != nil {
+		if err := s.fileStore.DeleteFile(ctx, *bill.OriginalStoragePath); err != nil {
+			fmt.Printf("Warning: Failed to delete original file for bill %s: %v\n", billID, err)
+		}
+	}
+	for _, previewPath := range []*string{bill.ThumbnailStoragePath, bill.PreviewStoragePath} {
+		if previewPath == nil {
+			continue
+		}
+		if err := s.fileStore.DeleteFile(ctx, *previewPath); err != nil {
+			fmt.Printf("Warning: Failed to delete preview for bill %s: %v\n", billID, err)
+		}
+	}
 
 	return nil
 }
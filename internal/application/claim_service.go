@@ -0,0 +1,180 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+	"github.com/google/uuid"
+)
+
+// ClaimService runs collaborative claiming sessions where group members pick
+// the line items they had on a bill.
+type ClaimService struct {
+	billRepo    ports.BillRepository
+	groupRepo   ports.GroupRepository
+	claimRepo   ports.ClaimSessionRepository
+	groupEvents *GroupEventService // optional, notifies members viewing the group
+}
+
+func NewClaimService(billRepo ports.BillRepository, groupRepo ports.GroupRepository, claimRepo ports.ClaimSessionRepository, groupEvents *GroupEventService) *ClaimService {
+	return &ClaimService{
+		billRepo:    billRepo,
+		groupRepo:   groupRepo,
+		claimRepo:   claimRepo,
+		groupEvents: groupEvents,
+	}
+}
+
+// ClaimSessionView bundles a session with the bill and group needed to present it.
+type ClaimSessionView struct {
+	Session *domain.ClaimSession
+	Bill    *domain.Bill
+	Group   *domain.Group
+}
+
+// OpenSession opens a group bill for claiming. Only the bill owner can open it.
+func (s *ClaimService) OpenSession(ctx context.Context, billID, userID uuid.UUID, req domain.OpenClaimSessionRequest) (*ClaimSessionView, error) {
+	bill, err := s.billRepo.GetBillByID(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+	if bill.UserID != userID {
+		return nil, domain.ErrBillNotFound
+	}
+
+	session, err := domain.NewClaimSession(bill, userID, domain.OverClaimPolicy(req.OverClaimPolicy))
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := s.groupRepo.GetByID(ctx, session.GroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.claimRepo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	s.groupEvents.Publish(group.ID, domain.GroupEventClaimsOpened, userID, domain.ClaimsPayload{BillID: bill.ID.String()})
+
+	return &ClaimSessionView{Session: session, Bill: bill, Group: group}, nil
+}
+
+// GetSessionForUser returns the session of a bill to its owner or to a group member linked to the user
+func (s *ClaimService) GetSessionForUser(ctx context.Context, billID, userID uuid.UUID) (*ClaimSessionView, error) {
+	view, err := s.loadSession(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+	if view.Bill.UserID != userID {
+		if _, ok := view.Group.MemberForUser(userID); !ok {
+			return nil, domain.ErrBillNotFound
+		}
+	}
+	return view, nil
+}
+
+// GetSessionForMember returns the session of a bill to a member of its group, e.g. through a guest link
+func (s *ClaimService) GetSessionForMember(ctx context.Context, billID, groupID, memberID uuid.UUID) (*ClaimSessionView, error) {
+	view, err := s.loadSession(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+	if view.Group.ID != groupID {
+		return nil, domain.ErrBillNotFound
+	}
+	if _, ok := view.Group.FindMember(memberID); !ok {
+		return nil, domain.ErrGroupMemberNotFound
+	}
+	return view, nil
+}
+
+// SubmitUserClaims replaces the claims of the group member linked to the user
+func (s *ClaimService) SubmitUserClaims(ctx context.Context, billID, userID uuid.UUID, req domain.SubmitClaimsRequest) (*ClaimSessionView, error) {
+	view, err := s.loadSession(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+	member, ok := view.Group.MemberForUser(userID)
+	if !ok {
+		return nil, domain.ErrNotGroupMember
+	}
+	return s.submitClaims(ctx, view, member.ID, userID, req)
+}
+
+// SubmitMemberClaims replaces the claims of a group member, e.g. through a guest link
+func (s *ClaimService) SubmitMemberClaims(ctx context.Context, billID, groupID, memberID uuid.UUID, req domain.SubmitClaimsRequest) (*ClaimSessionView, error) {
+	view, err := s.GetSessionForMember(ctx, billID, groupID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	return s.submitClaims(ctx, view, memberID, uuid.Nil, req)
+}
+
+// FinalizeSession locks the session and turns the claims into line item assignments. Only the bill owner can finalize.
+func (s *ClaimService) FinalizeSession(ctx context.Context, billID, userID uuid.UUID) (*ClaimSessionView, error) {
+	view, err := s.loadSession(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+	if view.Bill.UserID != userID {
+		return nil, domain.ErrBillNotFound
+	}
+
+	session, err := s.claimRepo.Finalize(ctx, view.Session.ID, func(session *domain.ClaimSession) (map[uuid.UUID][]*domain.LineItemAssignment, error) {
+		return session.BuildAssignments(view.Bill, view.Group)
+	})
+	if err != nil {
+		return nil, err
+	}
+	view.Session = session
+	s.groupEvents.Publish(view.Group.ID, domain.GroupEventClaimsFinalized, userID, domain.ClaimsPayload{BillID: view.Bill.ID.String()})
+
+	return view, nil
+}
+
+// submitClaims replaces the claims of a member. actorUserID is uuid.Nil for guests.
+func (s *ClaimService) submitClaims(ctx context.Context, view *ClaimSessionView, memberID, actorUserID uuid.UUID, req domain.SubmitClaimsRequest) (*ClaimSessionView, error) {
+	if view.Session.IsLocked() {
+		return nil, domain.ErrClaimSessionLocked
+	}
+
+	claims, err := domain.NewMemberClaims(view.Session, view.Bill, memberID, req.Claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.claimRepo.ReplaceMemberClaims(ctx, view.Session.ID, memberID, claims); err != nil {
+		return nil, err
+	}
+	member := memberID.String()
+	s.groupEvents.Publish(view.Group.ID, domain.GroupEventClaimsUpdated, actorUserID, domain.ClaimsPayload{BillID: view.Bill.ID.String(), MemberID: &member})
+
+	// Reload so the response reflects claims other members made concurrently
+	return s.loadSession(ctx, view.Bill.ID)
+}
+
+func (s *ClaimService) loadSession(ctx context.Context, billID uuid.UUID) (*ClaimSessionView, error) {
+	if billID == uuid.Nil {
+		return nil, domain.ErrInvalidInput
+	}
+
+	session, err := s.claimRepo.GetByBillID(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+
+	bill, err := s.billRepo.GetBillByID(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := s.groupRepo.GetByID(ctx, session.GroupID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving claim session group: %w", err)
+	}
+
+	return &ClaimSessionView{Session: session, Bill: bill, Group: group}, nil
+}
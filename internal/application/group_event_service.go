@@ -0,0 +1,102 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+	"github.com/google/uuid"
+)
+
+// maxReplayedGroupEvents bounds how many missed events are sent on reconnect;
+// clients further behind should reload the group instead
+const maxReplayedGroupEvents = 500
+
+// GroupEventService records group changes and streams them to the members
+// viewing the group.
+type GroupEventService struct {
+	groupRepo ports.GroupRepository
+	eventRepo ports.GroupEventRepository
+	bus       ports.GroupEventBus
+}
+
+func NewGroupEventService(groupRepo ports.GroupRepository, eventRepo ports.GroupEventRepository, bus ports.GroupEventBus) *GroupEventService {
+	return &GroupEventService{
+		groupRepo: groupRepo,
+		eventRepo: eventRepo,
+		bus:       bus,
+	}
+}
+
+// GroupEventSubscription holds the events a client missed and the live ones
+type GroupEventSubscription struct {
+	Missed []*domain.GroupEvent
+	Events <-chan *domain.GroupEvent
+	Cancel func()
+}
+
+// Subscribe subscribes a user who can view the group to its events. When
+// lastEventID is set, the events after it are returned as missed so the client
+// resumes where it left off. The subscription is taken before reading them so
+// no event is lost in between; live events may repeat missed ones.
+func (s *GroupEventService) Subscribe(ctx context.Context, groupID, userID uuid.UUID, lastEventID int64) (*GroupEventSubscription, error) {
+	if groupID == uuid.Nil {
+		return nil, domain.ErrInvalidInput
+	}
+	if userID == uuid.Nil {
+		return nil, domain.ErrUserIDEmpty
+	}
+
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if !group.CanView(userID) {
+		return nil, domain.ErrGroupNotFound
+	}
+
+	events, cancel := s.bus.Subscribe(groupID)
+	subscription := &GroupEventSubscription{Events: events, Cancel: cancel}
+
+	if lastEventID > 0 {
+		subscription.Missed, err = s.eventRepo.ListSince(ctx, groupID, lastEventID, maxReplayedGroupEvents)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	return subscription, nil
+}
+
+// Publish records a group event and sends it to the members viewing the group.
+// Events are best effort: the change they describe is already saved, so
+// failures are logged instead of returned. Safe to call on a nil service.
+func (s *GroupEventService) Publish(groupID uuid.UUID, eventType domain.GroupEventType, actorUserID uuid.UUID, payload interface{}) {
+	if s == nil {
+		return
+	}
+
+	event, err := domain.NewGroupEvent(groupID, eventType, actorUserID, payload)
+	if err != nil {
+		fmt.Printf("Warning: Failed to create %s event for group %s: %v\n", eventType, groupID, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Deleted groups have no log left to resume from
+	if eventType != domain.GroupEventGroupDeleted {
+		if err := s.eventRepo.Append(ctx, event); err != nil {
+			fmt.Printf("Warning: Failed to record %s event for group %s: %v\n", eventType, groupID, err)
+			return
+		}
+	}
+
+	if err := s.bus.Publish(ctx, event); err != nil {
+		fmt.Printf("Warning: Failed to publish %s event for group %s: %v\n", eventType, groupID, err)
+	}
+}
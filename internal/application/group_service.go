@@ -10,12 +10,16 @@ import (
 )
 
 type GroupService struct {
-	groupRepo ports.GroupRepository
+	groupRepo   ports.GroupRepository
+	userRepo    ports.UserRepository
+	groupEvents *GroupEventService // optional, notifies members viewing the group
 }
 
-func NewGroupService(groupRepo ports.GroupRepository) *GroupService {
+func NewGroupService(groupRepo ports.GroupRepository, userRepo ports.UserRepository, groupEvents *GroupEventService) *GroupService {
 	return &GroupService{
-		groupRepo: groupRepo,
+		groupRepo:   groupRepo,
+		userRepo:    userRepo,
+		groupEvents: groupEvents,
 	}
 }
 
@@ -94,6 +98,11 @@ func (s *GroupService) UpdateGroup(ctx context.Context, groupID, userID uuid.UUI
 	if err := s.groupRepo.Update(ctx, group); err != nil {
 		return nil, fmt.Errorf("error updating group: %w", err)
 	}
+	s.groupEvents.Publish(group.ID, domain.GroupEventGroupUpdated, userID, domain.GroupUpdatedPayload{
+		Name:        group.Name,
+		Description: group.Description,
+		MemberNames: group.GetMemberNames(),
+	})
 
 	return group, nil
 }
@@ -117,6 +126,42 @@ func (s *GroupService) DeleteGroup(ctx context.Context, groupID, userID uuid.UUI
 	if err := s.groupRepo.Delete(ctx, groupID); err != nil {
 		return fmt.Errorf("error deleting group: %w", err)
 	}
+	s.groupEvents.Publish(groupID, domain.GroupEventGroupDeleted, userID, struct{}{})
 
 	return nil
 }
+
+// LinkMember links a group member to the registered user with the given email, ensuring the user is the owner
+func (s *GroupService) LinkMember(ctx context.Context, groupID, memberID, userID uuid.UUID, req domain.LinkMemberRequest) (*domain.Group, error) {
+	if groupID == uuid.Nil || memberID == uuid.Nil {
+		return nil, domain.ErrInvalidInput
+	}
+	if userID == uuid.Nil {
+		return nil, domain.ErrUserIDEmpty
+	}
+
+	group, err := s.groupRepo.GetByIDAndOwner(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	linkedUser, err := s.userRepo.FindByEmail(ctx, req.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := group.LinkMember(memberID, linkedUser.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.groupRepo.UpdateMember(ctx, member); err != nil {
+		return nil, fmt.Errorf("error linking group member: %w", err)
+	}
+	s.groupEvents.Publish(group.ID, domain.GroupEventMemberLinked, userID, domain.MemberLinkedPayload{
+		MemberID: member.ID.String(),
+		UserID:   linkedUser.ID.String(),
+	})
+
+	return group, nil
+}
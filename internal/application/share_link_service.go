@@ -0,0 +1,154 @@
+package application
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+	"github.com/google/uuid"
+)
+
+// shareLinkPayloadSize is the size of a decoded token payload: group ID, member ID and expiry.
+const shareLinkPayloadSize = 16 + 16 + 8
+
+// ShareLinkService issues and resolves signed, expiring, read-only links
+// that let unregistered group members see their shares.
+type ShareLinkService struct {
+	splitService  *SplitService
+	groupRepo     ports.GroupRepository
+	secret        []byte
+	ttl           time.Duration
+	publicBaseURL string
+}
+
+func NewShareLinkService(splitService *SplitService, groupRepo ports.GroupRepository, secret string, ttl time.Duration, publicBaseURL string) *ShareLinkService {
+	return &ShareLinkService{
+		splitService:  splitService,
+		groupRepo:     groupRepo,
+		secret:        []byte(secret),
+		ttl:           ttl,
+		publicBaseURL: strings.TrimRight(publicBaseURL, "/"),
+	}
+}
+
+// CreateLink issues a share link for a member of a group owned by the user
+func (s *ShareLinkService) CreateLink(ctx context.Context, groupID, memberID, ownerID uuid.UUID) (*domain.ShareLinkDTO, error) {
+	if len(s.secret) == 0 {
+		return nil, domain.ErrShareLinkDisabled
+	}
+	if groupID == uuid.Nil || memberID == uuid.Nil {
+		return nil, domain.ErrInvalidInput
+	}
+	if ownerID == uuid.Nil {
+		return nil, domain.ErrUserIDEmpty
+	}
+
+	group, err := s.groupRepo.GetByIDAndOwner(ctx, groupID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := group.FindMember(memberID); !ok {
+		return nil, domain.ErrGroupMemberNotFound
+	}
+
+	claims := domain.ShareLinkClaims{
+		GroupID:   groupID,
+		MemberID:  memberID,
+		ExpiresAt: time.Now().UTC().Add(s.ttl).Truncate(time.Second),
+	}
+	token := s.sign(claims)
+	url := s.publicBaseURL + "/api/v1/share/" + token
+
+	return &domain.ShareLinkDTO{
+		Token:     token,
+		URL:       url,
+		ViewURL:   url + "/view",
+		ExpiresAt: claims.ExpiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// ResolveLink verifies a share link and returns the group ledger it gives access to
+func (s *ShareLinkService) ResolveLink(ctx context.Context, token string) (*domain.GroupLedger, *domain.ShareLinkClaims, error) {
+	claims, err := s.VerifyLink(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ledger, err := s.splitService.GetGroupLedger(ctx, claims.GroupID)
+	if err != nil {
+		if domain.IsErrNotFound(err) {
+			return nil, nil, domain.ErrShareLinkInvalid
+		}
+		return nil, nil, err
+	}
+	if _, ok := ledger.Group.FindMember(claims.MemberID); !ok {
+		// The member was removed from the group after the link was issued
+		return nil, nil, domain.ErrShareLinkInvalid
+	}
+
+	return ledger, claims, nil
+}
+
+// VerifyLink checks the signature and expiry of a share link and returns its claims
+func (s *ShareLinkService) VerifyLink(token string) (*domain.ShareLinkClaims, error) {
+	if len(s.secret) == 0 {
+		return nil, domain.ErrShareLinkDisabled
+	}
+
+	claims, err := s.verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.IsExpired(time.Now().UTC()) {
+		return nil, domain.ErrShareLinkExpired
+	}
+
+	return claims, nil
+}
+
+func (s *ShareLinkService) sign(claims domain.ShareLinkClaims) string {
+	payload := make([]byte, 0, shareLinkPayloadSize)
+	payload = append(payload, claims.GroupID[:]...)
+	payload = append(payload, claims.MemberID[:]...)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(claims.ExpiresAt.Unix()))
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload))
+}
+
+func (s *ShareLinkService) verify(token string) (*domain.ShareLinkClaims, error) {
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return nil, domain.ErrShareLinkInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil || len(payload) != shareLinkPayloadSize {
+		return nil, domain.ErrShareLinkInvalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, s.mac(payload)) {
+		return nil, domain.ErrShareLinkInvalid
+	}
+
+	groupID, _ := uuid.FromBytes(payload[0:16])
+	memberID, _ := uuid.FromBytes(payload[16:32])
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload[32:])), 0).UTC()
+
+	return &domain.ShareLinkClaims{
+		GroupID:   groupID,
+		MemberID:  memberID,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (s *ShareLinkService) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write(payload)
+	return h.Sum(nil)
+}
@@ -0,0 +1,215 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+	"github.com/google/uuid"
+)
+
+// SplitService links bills to groups and assigns line items to group members.
+type SplitService struct {
+	billRepo    ports.BillRepository
+	groupRepo   ports.GroupRepository
+	groupEvents *GroupEventService // optional, notifies members viewing the group
+}
+
+func NewSplitService(billRepo ports.BillRepository, groupRepo ports.GroupRepository, groupEvents *GroupEventService) *SplitService {
+	return &SplitService{
+		billRepo:    billRepo,
+		groupRepo:   groupRepo,
+		groupEvents: groupEvents,
+	}
+}
+
+// AttachBillToGroup links a bill to one of the user's groups and records who paid it
+func (s *SplitService) AttachBillToGroup(ctx context.Context, billID, userID uuid.UUID, req domain.AttachBillToGroupRequest) (*domain.Bill, error) {
+	groupID, err := uuid.Parse(req.GroupID)
+	if err != nil {
+		return nil, domain.ErrInvalidInput
+	}
+	paidByMemberID, err := uuid.Parse(req.PaidByMemberID)
+	if err != nil {
+		return nil, domain.ErrInvalidInput
+	}
+
+	bill, err := s.getOwnedBill(ctx, billID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := s.groupRepo.GetByIDAndOwner(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bill.AttachToGroup(group, paidByMemberID); err != nil {
+		return nil, err
+	}
+
+	if err := s.billRepo.SetBillGroup(ctx, bill); err != nil {
+		return nil, fmt.Errorf("error attaching bill to group: %w", err)
+	}
+
+	payload := domain.ExpenseAddedPayload{
+		BillID:         bill.ID.String(),
+		TotalAmount:    bill.TotalAmount,
+		PaidByMemberID: paidByMemberID.String(),
+	}
+	if bill.VendorName != nil {
+		payload.VendorName = *bill.VendorName
+	}
+	s.groupEvents.Publish(group.ID, domain.GroupEventExpenseAdded, userID, payload)
+
+	return bill, nil
+}
+
+// AssignLineItem replaces the member assignments of a line item on a group bill
+func (s *SplitService) AssignLineItem(ctx context.Context, billID, lineItemID, userID uuid.UUID, req domain.AssignLineItemRequest) (*domain.LineItem, error) {
+	bill, err := s.getOwnedBill(ctx, billID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if bill.GroupID == nil {
+		return nil, domain.ErrBillNotInGroup
+	}
+
+	lineItem := bill.FindLineItem(lineItemID)
+	if lineItem == nil {
+		return nil, domain.ErrLineItemNotFound
+	}
+
+	group, err := s.groupRepo.GetByID(ctx, *bill.GroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := make([]*domain.LineItemAssignment, 0, len(req.Assignments))
+	for _, input := range req.Assignments {
+		memberID, err := uuid.Parse(input.MemberID)
+		if err != nil {
+			return nil, domain.ErrInvalidInput
+		}
+		if _, ok := group.FindMember(memberID); !ok {
+			return nil, domain.ErrGroupMemberNotFound
+		}
+
+		units := input.Units
+		if units == 0 {
+			units = 1
+		}
+
+		assignment, err := domain.NewLineItemAssignment(lineItem.ID, memberID, units)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, assignment)
+	}
+
+	if err := s.billRepo.ReplaceLineItemAssignments(ctx, lineItem.ID, assignments); err != nil {
+		return nil, fmt.Errorf("error assigning line item: %w", err)
+	}
+
+	lineItem.Assignments = make([]domain.LineItemAssignment, len(assignments))
+	payload := domain.AssignmentUpdatedPayload{
+		BillID:      bill.ID.String(),
+		LineItemID:  lineItem.ID.String(),
+		Assignments: make([]domain.LineItemAssignmentDTO, len(assignments)),
+	}
+	for i, assignment := range assignments {
+		lineItem.Assignments[i] = *assignment
+		payload.Assignments[i] = domain.LineItemAssignmentDTO{
+			MemberID: assignment.GroupMemberID.String(),
+			Units:    assignment.Units,
+		}
+	}
+	s.groupEvents.Publish(group.ID, domain.GroupEventAssignmentUpdated, userID, payload)
+
+	return lineItem, nil
+}
+
+// GetGroupLedger computes the shares, balances and settlement transfers of a group
+func (s *SplitService) GetGroupLedger(ctx context.Context, groupID uuid.UUID) (*domain.GroupLedger, error) {
+	if groupID == uuid.Nil {
+		return nil, domain.ErrInvalidInput
+	}
+
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	bills, err := s.billRepo.GetBillsByGroupID(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving group bills: %w", err)
+	}
+
+	settlements, err := s.groupRepo.ListSettlements(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	return domain.BuildGroupLedger(group, bills, settlements), nil
+}
+
+// RecordSettlement records a payment between two members of a group the user
+// belongs to, which reduces the transfers still needed to settle up
+func (s *SplitService) RecordSettlement(ctx context.Context, groupID, userID uuid.UUID, req domain.RecordSettlementRequest) (*domain.Settlement, error) {
+	if groupID == uuid.Nil {
+		return nil, domain.ErrInvalidInput
+	}
+	fromMemberID, err := uuid.Parse(req.FromMemberID)
+	if err != nil {
+		return nil, domain.ErrInvalidInput
+	}
+	toMemberID, err := uuid.Parse(req.ToMemberID)
+	if err != nil {
+		return nil, domain.ErrInvalidInput
+	}
+
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if !group.CanView(userID) {
+		return nil, domain.ErrGroupNotFound
+	}
+
+	settlement, err := domain.NewSettlement(group, fromMemberID, toMemberID, req.Amount, req.Note, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.groupRepo.CreateSettlement(ctx, settlement); err != nil {
+		return nil, err
+	}
+
+	s.groupEvents.Publish(group.ID, domain.GroupEventSettlementRecorded, userID, domain.SettlementRecordedPayload{
+		SettlementID: settlement.ID.String(),
+		FromMemberID: settlement.FromMemberID.String(),
+		ToMemberID:   settlement.ToMemberID.String(),
+		Amount:       settlement.Amount,
+	})
+
+	return settlement, nil
+}
+
+func (s *SplitService) getOwnedBill(ctx context.Context, billID, userID uuid.UUID) (*domain.Bill, error) {
+	if billID == uuid.Nil {
+		return nil, domain.ErrInvalidInput
+	}
+	if userID == uuid.Nil {
+		return nil, domain.ErrUserIDEmpty
+	}
+
+	bill, err := s.billRepo.GetBillByID(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+	if bill.UserID != userID {
+		return nil, domain.ErrBillNotFound
+	}
+
+	return bill, nil
+}
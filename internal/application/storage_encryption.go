@@ -0,0 +1,74 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+)
+
+// MasterKeyRotationReport counts what a rotation of the master key of stored files did
+type MasterKeyRotationReport struct {
+	Rotated   int // files whose data key was rewrapped with the current master key
+	Encrypted int // files stored before encryption was enabled, now encrypted
+	Current   int // files already using the current master key
+	Failed    int // files left as they were
+}
+
+// MasterKeyRotator moves the files of every bill to the current master key, so
+// retired master keys can be removed once it has run
+type MasterKeyRotator struct {
+	files ports.FileKeyRotator
+	db    *gorm.DB
+}
+
+func NewMasterKeyRotator(files ports.FileKeyRotator, db *gorm.DB) *MasterKeyRotator {
+	return &MasterKeyRotator{files: files, db: db}
+}
+
+// Rotate rewraps the data keys of the files, originals and previews of every
+// bill with the current master key, encrypting the files stored in plain. Files
+// shared by several bills are rotated once. With dryRun nothing is changed.
+func (r *MasterKeyRotator) Rotate(ctx context.Context, dryRun bool) (*MasterKeyRotationReport, error) {
+	var bills []domain.Bill
+	err := r.db.WithContext(ctx).
+		Select("id", "file_storage_path", "original_storage_path", "thumbnail_storage_path", "preview_storage_path").
+		// Files uploaded directly are encrypted when the upload is completed
+		Where("status <> ?", domain.BillStatusAwaitingUpload).
+		Order("uploaded_at").
+		Find(&bills).Error
+	if err != nil {
+		return nil, fmt.Errorf("error loading bills: %w", err)
+	}
+
+	report := &MasterKeyRotationReport{}
+	rotated := make(map[string]bool)
+	for _, bill := range bills {
+		for _, storagePath := range []*string{&bill.FileStoragePath, bill.OriginalStoragePath, bill.ThumbnailStoragePath, bill.PreviewStoragePath} {
+			if storagePath == nil || *storagePath == "" || rotated[*storagePath] {
+				continue
+			}
+			rotated[*storagePath] = true
+
+			rotation, err := r.files.RotateFileKey(ctx, *storagePath, dryRun)
+			if err != nil {
+				report.Failed++
+				log.Printf("Failed to rotate the key of file %s of bill %s: %v", *storagePath, bill.ID, err)
+				continue
+			}
+			switch rotation {
+			case ports.FileKeyRotated:
+				report.Rotated++
+			case ports.FileKeyEncrypted:
+				report.Encrypted++
+			case ports.FileKeyCurrent:
+				report.Current++
+			}
+		}
+	}
+	return report, nil
+}
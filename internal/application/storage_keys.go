@@ -0,0 +1,173 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/dgsaltarin/SharedBitesBackend/internal/ports"
+)
+
+// billStorageKey is the unique key a bill file is stored under. The normalized
+// copy and the original of an upload share the same file ID.
+func billStorageKey(userID, fileID uuid.UUID, extension string) string {
+	return fmt.Sprintf("bills/%s/%s%s", userID, fileID, extension)
+}
+
+// originalBillStorageKey is the key the file as uploaded is stored under, when
+// the bill file is a normalized copy
+func originalBillStorageKey(userID, fileID uuid.UUID, extension string) string {
+	return fmt.Sprintf("bills/%s/originals/%s%s", userID, fileID, extension)
+}
+
+// uniqueStorageKey matches the keys made by billStorageKey and originalBillStorageKey,
+// at the end of a storage path that may start with the store's own prefix
+var uniqueStorageKey = regexp.MustCompile(`(^|/)bills/[0-9a-f-]{36}/(originals/)?[0-9a-f-]{36}(\.[A-Za-z0-9]+)?$`)
+
+// StorageKeyMigrationReport counts what a storage key migration did
+type StorageKeyMigrationReport struct {
+	Migrated int // bills moved to unique keys
+	Shared   int // bills that shared their file with another bill
+	Failed   int // bills left at their old keys
+}
+
+// StorageKeyMigrator moves bill files stored under their uploaded filename,
+// where uploads with the same name overwrote each other, to unique keys
+type StorageKeyMigrator struct {
+	fileStore ports.FileStore
+	db        *gorm.DB
+}
+
+func NewStorageKeyMigrator(fileStore ports.FileStore, db *gorm.DB) *StorageKeyMigrator {
+	return &StorageKeyMigrator{fileStore: fileStore, db: db}
+}
+
+// Migrate copies the files of every bill stored under an old key to a new unique
+// key, points the bill at the copies and then deletes the old files. An old file
+// is only deleted once no other bill refers to it. Bills that shared a file were
+// overwritten by the last upload with the same name and are reported, as their
+// own file can't be recovered. With dryRun nothing is changed.
+func (m *StorageKeyMigrator) Migrate(ctx context.Context, dryRun bool) (*StorageKeyMigrationReport, error) {
+	var bills []domain.Bill
+	err := m.db.WithContext(ctx).
+		Select("id", "user_id", "filename", "file_storage_path", "file_type", "original_storage_path").
+		// Files uploaded directly are moved to unique keys when the upload is completed
+		Where("status <> ?", domain.BillStatusAwaitingUpload).
+		Order("uploaded_at").
+		Find(&bills).Error
+	if err != nil {
+		return nil, fmt.Errorf("error loading bills: %w", err)
+	}
+
+	// Count the bills referring to each old file, so shared files are only deleted once all are moved
+	references := make(map[string][]uuid.UUID)
+	var pending []domain.Bill
+	for _, bill := range bills {
+		if uniqueStorageKey.MatchString(bill.FileStoragePath) {
+			continue
+		}
+		pending = append(pending, bill)
+		references[bill.FileStoragePath] = append(references[bill.FileStoragePath], bill.ID)
+		if bill.OriginalStoragePath != nil {
+			references[*bill.OriginalStoragePath] = append(references[*bill.OriginalStoragePath], bill.ID)
+		}
+	}
+
+	report := &StorageKeyMigrationReport{}
+	for _, bill := range pending {
+		if shared := references[bill.FileStoragePath]; len(shared) > 1 {
+			report.Shared++
+			log.Printf("Bill %s shares %s with bills %v, it holds the file of the last upload", bill.ID, bill.FileStoragePath, shared)
+		}
+	}
+
+	for _, bill := range pending {
+		if dryRun {
+			report.Migrated++
+			continue
+		}
+
+		if err := m.migrateBill(ctx, bill, references); err != nil {
+			report.Failed++
+			log.Printf("Failed to migrate bill %s: %v", bill.ID, err)
+			continue
+		}
+		report.Migrated++
+	}
+	return report, nil
+}
+
+// migrateBill copies the files of a bill to unique keys and updates the bill.
+// The old files are deleted once the last bill referring to them is moved.
+func (m *StorageKeyMigrator) migrateBill(ctx context.Context, bill domain.Bill, references map[string][]uuid.UUID) error {
+	fileID := uuid.New()
+	filename := path.Base(bill.Filename)
+
+	storagePath, err := m.fileStore.CopyFile(ctx, bill.FileStoragePath,
+		billStorageKey(bill.UserID, fileID, storageExtension(bill.FileStoragePath)),
+		ports.FileMetadata{ContentType: bill.FileType, Filename: filename})
+	if err != nil {
+		return err
+	}
+	updates := map[string]interface{}{"file_storage_path": storagePath}
+
+	var originalPath *string
+	if bill.OriginalStoragePath != nil {
+		extension := storageExtension(*bill.OriginalStoragePath)
+		copied, err := m.fileStore.CopyFile(ctx, *bill.OriginalStoragePath,
+			originalBillStorageKey(bill.UserID, fileID, extension),
+			ports.FileMetadata{ContentType: mime.TypeByExtension(extension), Filename: filename})
+		if err != nil {
+			m.fileStore.DeleteFile(ctx, storagePath)
+			return err
+		}
+		originalPath = &copied
+		updates["original_storage_path"] = copied
+	}
+
+	if err := m.db.WithContext(ctx).Model(&domain.Bill{}).Where("id = ?", bill.ID).Updates(updates).Error; err != nil {
+		m.fileStore.DeleteFile(ctx, storagePath)
+		if originalPath != nil {
+			m.fileStore.DeleteFile(ctx, *originalPath)
+		}
+		return fmt.Errorf("error updating bill: %w", err)
+	}
+
+	m.release(ctx, bill.FileStoragePath, bill.ID, references)
+	if bill.OriginalStoragePath != nil {
+		m.release(ctx, *bill.OriginalStoragePath, bill.ID, references)
+	}
+	return nil
+}
+
+// release drops the reference of a moved bill to an old file, deleting the file
+// once no bill refers to it anymore
+func (m *StorageKeyMigrator) release(ctx context.Context, storagePath string, billID uuid.UUID, references map[string][]uuid.UUID) {
+	remaining := references[storagePath][:0]
+	for _, id := range references[storagePath] {
+		if id != billID {
+			remaining = append(remaining, id)
+		}
+	}
+	references[storagePath] = remaining
+	if len(remaining) > 0 {
+		return
+	}
+
+	if err := m.fileStore.DeleteFile(ctx, storagePath); err != nil {
+		log.Printf("Failed to delete old file %s: %v", storagePath, err)
+	}
+}
+
+// storageExtension returns the lowercase extension of a stored file
+func storageExtension(storagePath string) string {
+	return strings.ToLower(path.Ext(storagePath))
+}
@@ -0,0 +1,38 @@
+package domain
+
+import "time"
+
+// AnalysisCacheEntry is a Textract response cached by the SHA-256 hash of the
+// analyzed document, stored gzip-compressed
+type AnalysisCacheEntry struct {
+	ContentHash  string    `gorm:"size:64;primary_key"`
+	Response     []byte    `gorm:"type:bytea"`
+	ResponseSize int       `gorm:"not null"`
+	CreatedAt    time.Time `gorm:"index"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+}
+
+func (e *AnalysisCacheEntry) TableName() string {
+	return "analysis_cache"
+}
+
+// NewAnalysisCacheEntry compresses the Textract response of a document, cached until ttl passes
+func NewAnalysisCacheEntry(contentHash string, response []byte, ttl time.Duration) (*AnalysisCacheEntry, error) {
+	compressed, err := compressResponse(response)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	return &AnalysisCacheEntry{
+		ContentHash:  contentHash,
+		Response:     compressed,
+		ResponseSize: len(response),
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(ttl),
+	}, nil
+}
+
+// RawResponse returns the uncompressed JSON of the Textract response
+func (e *AnalysisCacheEntry) RawResponse() ([]byte, error) {
+	return decompressResponse(e.Response)
+}
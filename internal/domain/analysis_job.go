@@ -0,0 +1,160 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AnalysisJobStatus string
+
+const (
+	AnalysisJobQueued    AnalysisJobStatus = "queued"
+	AnalysisJobRunning   AnalysisJobStatus = "running"
+	AnalysisJobSucceeded AnalysisJobStatus = "succeeded"
+	// AnalysisJobFailed jobs are dead-lettered: they are not run again until an admin retries them
+	AnalysisJobFailed AnalysisJobStatus = "failed"
+)
+
+// AnalysisOptions holds the text detection settings a bill is analyzed with.
+type AnalysisOptions struct {
+	Languages     []string `json:"languages"`
+	MinConfidence float64  `json:"min_confidence"`
+	CurrencyCodes []string `json:"currency_codes"`
+}
+
+func (o AnalysisOptions) Value() (driver.Value, error) {
+	return json.Marshal(o)
+}
+
+func (o *AnalysisOptions) Scan(value interface{}) error {
+	if value == nil {
+		*o = AnalysisOptions{}
+		return nil
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported type for analysis options")
+	}
+	return json.Unmarshal(data, o)
+}
+
+// AnalysisJob is a queued request to analyze a bill in the background.
+type AnalysisJob struct {
+	ID         uuid.UUID         `gorm:"type:uuid;primary_key;"`
+	BillID     uuid.UUID         `gorm:"type:uuid;not null;index"`
+	Status     AnalysisJobStatus `gorm:"size:25;not null;index:idx_analysis_jobs_status_run_at,priority:1"`
+	Options    AnalysisOptions   `gorm:"type:jsonb"`
+	Attempts   int               `gorm:"not null;default:0"`
+	RunAt      time.Time         `gorm:"not null;index:idx_analysis_jobs_status_run_at,priority:2"`
+	LockedAt   *time.Time
+	LastError  *string `gorm:"type:text"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	FinishedAt *time.Time
+
+	// TextractJobID is the asynchronous Textract job started for the bill, resumed
+	// by later attempts instead of starting another
+	TextractJobID *string `gorm:"size:64"`
+}
+
+func (j *AnalysisJob) TableName() string {
+	return "analysis_jobs"
+}
+
+func (j *AnalysisJob) BeforeCreate(tx *gorm.DB) (err error) {
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	now := time.Now().UTC()
+	j.CreatedAt = now
+	j.UpdatedAt = now
+	if j.RunAt.IsZero() {
+		j.RunAt = now
+	}
+	return
+}
+
+func (j *AnalysisJob) BeforeUpdate(tx *gorm.DB) (err error) {
+	j.UpdatedAt = time.Now().UTC()
+	return
+}
+
+// NewAnalysisJob creates a job that analyzes the bill as soon as a worker is free.
+func NewAnalysisJob(billID uuid.UUID, options AnalysisOptions) (*AnalysisJob, error) {
+	if billID == uuid.Nil {
+		return nil, ErrBillIDEmpty
+	}
+
+	return &AnalysisJob{
+		BillID:  billID,
+		Status:  AnalysisJobQueued,
+		Options: options,
+	}, nil
+}
+
+// RetryPolicy controls how often and how late failed analyses are tried again
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Backoff returns the delay before the next attempt. It doubles with every
+// attempt up to MaxDelay, and a random half of it is jittered so that jobs
+// throttled together do not come back together.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// CanRetry reports whether a job that failed with err should run again
+func (p RetryPolicy) CanRetry(job *AnalysisJob, err error) bool {
+	return IsRetryableAnalysisError(err) && job.Attempts < p.MaxAttempts
+}
+
+// AnalysisRetryError asks the worker to run a failed job again after Delay
+type AnalysisRetryError struct {
+	Err   error
+	Delay time.Duration
+}
+
+func (e *AnalysisRetryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AnalysisRetryError) Unwrap() error {
+	return e.Err
+}
+
+// AnalysisJobDTO represents an analysis job in admin API responses
+type AnalysisJobDTO struct {
+	ID         string  `json:"id"`
+	BillID     string  `json:"bill_id"`
+	Status     string  `json:"status"`
+	Attempts   int     `json:"attempts"`
+	LastError  *string `json:"last_error,omitempty"`
+	RunAt      string  `json:"run_at"`
+	CreatedAt  string  `json:"created_at"`
+	FinishedAt *string `json:"finished_at,omitempty"`
+}
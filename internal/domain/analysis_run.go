@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AnalysisRun keeps the full response of one Textract analysis of a bill, so it
+// can be parsed again with another configuration or a newer parser without
+// paying for another analysis. The response is stored gzip-compressed.
+type AnalysisRun struct {
+	ID            uuid.UUID       `gorm:"type:uuid;primary_key;"`
+	BillID        uuid.UUID       `gorm:"type:uuid;not null;index"`
+	Options       AnalysisOptions `gorm:"type:jsonb"` // options the response was last parsed with
+	ParserVersion int             `gorm:"not null"`   // version of the parser the response was last parsed with
+	Response      []byte          `gorm:"type:bytea"` // compressed JSON of the Textract response
+	ResponseSize  int             `gorm:"not null"`   // size of the uncompressed response
+	CreatedAt     time.Time
+	ReparsedAt    *time.Time
+}
+
+func (r *AnalysisRun) TableName() string {
+	return "analysis_runs"
+}
+
+func (r *AnalysisRun) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now().UTC()
+	}
+	return
+}
+
+// NewAnalysisRun compresses the Textract response of an analysis of a bill,
+// parsed with options by the given parser version
+func NewAnalysisRun(billID uuid.UUID, options AnalysisOptions, parserVersion int, response []byte) (*AnalysisRun, error) {
+	if billID == uuid.Nil {
+		return nil, ErrBillIDEmpty
+	}
+
+	compressed, err := compressResponse(response)
+	if err != nil {
+		return nil, err
+	}
+	return &AnalysisRun{
+		BillID:        billID,
+		Options:       options,
+		ParserVersion: parserVersion,
+		Response:      compressed,
+		ResponseSize:  len(response),
+	}, nil
+}
+
+// RawResponse returns the uncompressed JSON of the Textract response
+func (r *AnalysisRun) RawResponse() ([]byte, error) {
+	return decompressResponse(r.Response)
+}
+
+func compressResponse(response []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(response); err != nil {
+		return nil, fmt.Errorf("error compressing analysis response: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error compressing analysis response: %w", err)
+	}
+	return compressed.Bytes(), nil
+}
+
+func decompressResponse(compressed []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing analysis response: %w", err)
+	}
+	defer reader.Close()
+
+	response, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing analysis response: %w", err)
+	}
+	return response, nil
+}
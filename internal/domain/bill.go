@@ -15,6 +15,9 @@ const (
 	BillStatusProcessing BillStatus = "processing"
 	BillStatusAnalyzed   BillStatus = "analyzed"
 	BillStatusFailed     BillStatus = "failed"
+
+	// BillStatusAwaitingUpload is a bill whose file the client uploads directly to storage
+	BillStatusAwaitingUpload BillStatus = "awaiting_upload"
 )
 
 type Bill struct {
@@ -29,12 +32,57 @@ type Bill struct {
 	UpdatedAt       time.Time
 	ProcessedAt     *time.Time
 
+	// file as uploaded, kept when FileStoragePath holds a normalized copy
+	OriginalStoragePath *string `gorm:"size:255"`
+
+	// renditions of the file shown in lists and previews
+	ThumbnailStoragePath *string    `gorm:"size:255"`
+	PreviewStoragePath   *string    `gorm:"size:255"`
+	PreviewsRenderedAt   *time.Time // nil until rendered; files that can't be rendered have no renditions
+	PreviewAttempts      int        `gorm:"not null;default:0"` // background renders tried while PreviewsRenderedAt is nil
+	PreviewsAttemptedAt  *time.Time
+
+	// duplicate detection
+	ContentHash       *string         `gorm:"size:64;index"` // SHA-256 of the uploaded file
+	PerceptualHash    *int64          // difference hash of the image, similar for photos of the same receipt
+	Fingerprint       *string         `gorm:"size:255;index"` // vendor, date and total once analyzed
+	DuplicateOfBillID *uuid.UUID      `gorm:"type:uuid"`
+	DuplicateReason   DuplicateReason `gorm:"size:20"`
+
+	// background analysis
+	AnalysisAttempts  int        `gorm:"not null;default:0"`
+	LastAnalysisError *string    `gorm:"type:text"`
+	AnalysisToken     *uuid.UUID `gorm:"type:uuid"` // identifies the analysis allowed to save its results
+	AnalysisClaimedAt *time.Time // when that analysis marked the bill as processing
+
 	// fields from textract
 	VendorName      *string
 	TransactionDate *time.Time
 	TotalAmount     *float64
+	SubtotalAmount  *float64
+	TaxAmount       *float64
+	TipAmount       *float64
+	DiscountAmount  *float64
 	LineItems       []LineItem `gorm:"foreignKey:BillID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	TextTrackOutput *string    `gorm:"type:text"`
+
+	// reconciliation of the extracted amounts
+	ReconciliationStatus ReconciliationStatus `gorm:"size:25"`
+	Discrepancies        Discrepancies        `gorm:"type:jsonb"`
+
+	// manual corrections
+	Version      int        `gorm:"not null;default:1"`
+	EditedFields FieldNames `gorm:"type:jsonb"` // fields whose current value was entered by a user
+
+	// confidence and location of the extracted vendor, date and total
+	FieldDetections FieldDetections `gorm:"type:jsonb"`
+
+	// batch upload the bill came from, if any
+	BatchID *uuid.UUID `gorm:"type:uuid;index"`
+
+	// group splitting
+	GroupID        *uuid.UUID `gorm:"type:uuid;index"`
+	PaidByMemberID *uuid.UUID `gorm:"type:uuid"`
 }
 
 func (b *Bill) TableName() string {
@@ -54,6 +102,10 @@ func (b *Bill) BeforeCreate(tx *gorm.DB) (err error) {
 		b.Status = BillStatusUploaded
 	}
 
+	if b.Version == 0 {
+		b.Version = 1
+	}
+
 	if b.UpdatedAt.IsZero() {
 		b.UpdatedAt = time.Now().UTC()
 	}
@@ -81,5 +133,43 @@ func NewBill(userID uuid.UUID, filename, fileStoragePath, fileType string) (*Bil
 		FileStoragePath: fileStoragePath,
 		FileType:        fileType,
 		Status:          BillStatusUploaded,
+		Version:         1,
 	}, nil
 }
+
+// AttachToGroup links the bill to a group and records which member paid it.
+func (b *Bill) AttachToGroup(group *Group, paidByMemberID uuid.UUID) error {
+	if group == nil {
+		return ErrGroupNotFound
+	}
+	if _, ok := group.FindMember(paidByMemberID); !ok {
+		return ErrGroupMemberNotFound
+	}
+
+	b.GroupID = &group.ID
+	b.PaidByMemberID = &paidByMemberID
+	return nil
+}
+
+// FindLineItem returns the line item with the given ID, or nil if the bill has none.
+func (b *Bill) FindLineItem(lineItemID uuid.UUID) *LineItem {
+	for i := range b.LineItems {
+		if b.LineItems[i].ID == lineItemID {
+			return &b.LineItems[i]
+		}
+	}
+	return nil
+}
+
+// Total returns the bill total, falling back to the sum of its line items
+// when Textract did not detect one.
+func (b *Bill) Total() float64 {
+	if b.TotalAmount != nil {
+		return *b.TotalAmount
+	}
+	var total float64
+	for _, item := range b.LineItems {
+		total += item.Amount()
+	}
+	return total
+}
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxBatchFiles is the largest number of receipts accepted in one batch upload
+const MaxBatchFiles = 50
+
+type BatchFileStatus string
+
+const (
+	BatchFileQueued   BatchFileStatus = "queued"   // bill created and queued for analysis
+	BatchFileRejected BatchFileStatus = "rejected" // the file could not be stored
+)
+
+// BillBatch groups the bills created by one batch upload
+type BillBatch struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;index"`
+	GroupID        *uuid.UUID `gorm:"type:uuid"`
+	PaidByMemberID *uuid.UUID `gorm:"type:uuid"`
+	CreatedAt      time.Time
+	Files          []BillBatchFile `gorm:"foreignKey:BatchID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (b *BillBatch) TableName() string {
+	return "bill_batches"
+}
+
+func (b *BillBatch) BeforeCreate(tx *gorm.DB) (err error) {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = time.Now().UTC()
+	}
+	return
+}
+
+// BillBatchFile is the outcome of one file of a batch upload
+type BillBatchFile struct {
+	ID        uuid.UUID       `gorm:"type:uuid;primary_key;"`
+	BatchID   uuid.UUID       `gorm:"type:uuid;not null;index"`
+	Position  int             `gorm:"not null"`
+	Filename  string          `gorm:"size:255;not null"`
+	Status    BatchFileStatus `gorm:"size:25;not null"`
+	BillID    *uuid.UUID      `gorm:"type:uuid"`
+	Error     *string         `gorm:"type:text"`
+	ErrorCode string          `gorm:"size:50"` // upload validation code of a rejected file
+
+	DuplicateOfBillID *uuid.UUID `gorm:"type:uuid"` // bill a rejected duplicate repeats
+
+	BillStatus BillStatus `gorm:"-"` // current status of the bill, filled when the batch is read
+}
+
+func (f *BillBatchFile) TableName() string {
+	return "bill_batch_files"
+}
+
+func (f *BillBatchFile) BeforeCreate(tx *gorm.DB) (err error) {
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+	}
+	return
+}
+
+// UploadBillBatchRequest represents the files of a batch upload. When GroupID is
+// set every bill is attached to the group as paid by PaidByMemberID.
+type UploadBillBatchRequest struct {
+	UserID         uuid.UUID
+	Files          []UploadBillRequest
+	GroupID        *uuid.UUID
+	PaidByMemberID *uuid.UUID
+}
+
+// BillBatchDTO represents a batch upload in API responses
+type BillBatchDTO struct {
+	ID             string             `json:"id"`
+	GroupID        *string            `json:"group_id,omitempty"`
+	PaidByMemberID *string            `json:"paid_by_member_id,omitempty"`
+	CreatedAt      string             `json:"created_at"`
+	Files          []BillBatchFileDTO `json:"files"`
+}
+
+// BillBatchFileDTO represents one file of a batch upload
+type BillBatchFileDTO struct {
+	Filename   string  `json:"filename"`
+	Status     string  `json:"status"`
+	BillID     *string `json:"bill_id,omitempty"`
+	BillStatus string  `json:"bill_status,omitempty"`
+	Error      *string `json:"error,omitempty"`
+	ErrorCode  string  `json:"error_code,omitempty"`
+
+	DuplicateOfBillID *string `json:"duplicate_of_bill_id,omitempty"`
+}
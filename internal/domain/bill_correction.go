@@ -0,0 +1,492 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FieldSource tells whether a value was extracted by OCR or entered by a user.
+type FieldSource string
+
+const (
+	FieldSourceOCR  FieldSource = "ocr"
+	FieldSourceUser FieldSource = "user"
+)
+
+// Bill fields that can be corrected.
+const (
+	BillFieldVendorName      = "vendor_name"
+	BillFieldTransactionDate = "transaction_date"
+	BillFieldTotalAmount     = "total_amount"
+	BillFieldSubtotalAmount  = "subtotal_amount"
+	BillFieldTaxAmount       = "tax_amount"
+	BillFieldTipAmount       = "tip_amount"
+	BillFieldDiscountAmount  = "discount_amount"
+)
+
+// Line item fields that can be corrected.
+const (
+	LineItemFieldDescription = "description"
+	LineItemFieldQuantity    = "quantity"
+	LineItemFieldUnitPrice   = "unit_price"
+	LineItemFieldTotalPrice  = "total_price"
+	// LineItemFieldDeleted is recorded when a user removes a line item.
+	LineItemFieldDeleted = "deleted"
+)
+
+// FieldNames is a set of field names stored as a JSON column.
+type FieldNames []string
+
+// Contains reports whether the set holds the field.
+func (f FieldNames) Contains(field string) bool {
+	for _, name := range f {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FieldNames) add(field string) {
+	if !f.Contains(field) {
+		*f = append(*f, field)
+	}
+}
+
+func (f FieldNames) Value() (driver.Value, error) {
+	if f == nil {
+		return nil, nil
+	}
+	return json.Marshal(f)
+}
+
+func (f *FieldNames) Scan(value interface{}) error {
+	if value == nil {
+		*f = nil
+		return nil
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported type for field names")
+	}
+	return json.Unmarshal(data, f)
+}
+
+// BillRevision records the value a field of a bill or line item had at a given
+// bill version, and whether it came from OCR or from a user.
+type BillRevision struct {
+	ID             uuid.UUID   `gorm:"type:uuid;primary_key;"`
+	BillID         uuid.UUID   `gorm:"type:uuid;not null;index"`
+	LineItemID     *uuid.UUID  `gorm:"type:uuid;index"`
+	Version        int         `gorm:"not null"`
+	Field          string      `gorm:"size:50;not null"`
+	Value          *string     `gorm:"type:text"`
+	Source         FieldSource `gorm:"size:10;not null"`
+	EditedByUserID *uuid.UUID  `gorm:"type:uuid"`
+	CreatedAt      time.Time
+}
+
+func (r *BillRevision) TableName() string {
+	return "bill_revisions"
+}
+
+func (r *BillRevision) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now().UTC()
+	}
+	return
+}
+
+// BillCorrection collects the changes of one edit so they can be saved atomically.
+// BaseVersion is the bill version the edit was made against.
+type BillCorrection struct {
+	Bill               *Bill
+	BaseVersion        int
+	CreatedLineItems   []*LineItem
+	UpdatedLineItems   []*LineItem
+	DeletedLineItemIDs []uuid.UUID
+	Revisions          []*BillRevision
+
+	userID uuid.UUID
+}
+
+// UpdateBillRequest represents a correction of the bill fields. Omitted fields are left unchanged.
+type UpdateBillRequest struct {
+	VendorName      *string  `json:"vendor_name"`
+	TransactionDate *string  `json:"transaction_date"`
+	TotalAmount     *float64 `json:"total_amount"`
+	SubtotalAmount  *float64 `json:"subtotal_amount"`
+	TaxAmount       *float64 `json:"tax_amount"`
+	TipAmount       *float64 `json:"tip_amount"`
+	DiscountAmount  *float64 `json:"discount_amount"`
+}
+
+// CreateLineItemRequest represents a line item added by hand.
+type CreateLineItemRequest struct {
+	Description string   `json:"description" binding:"required"`
+	Quantity    *float64 `json:"quantity"`
+	UnitPrice   *float64 `json:"unit_price"`
+	TotalPrice  *float64 `json:"total_price"`
+}
+
+// UpdateLineItemRequest represents a correction of a line item. Omitted fields are left unchanged.
+type UpdateLineItemRequest struct {
+	Description *string  `json:"description"`
+	Quantity    *float64 `json:"quantity"`
+	UnitPrice   *float64 `json:"unit_price"`
+	TotalPrice  *float64 `json:"total_price"`
+}
+
+// BillHistoryDTO represents the edit history of a bill.
+type BillHistoryDTO struct {
+	BillID    string            `json:"bill_id"`
+	Version   int               `json:"version"`
+	Revisions []BillRevisionDTO `json:"revisions"`
+}
+
+// BillRevisionDTO represents a single field revision.
+type BillRevisionDTO struct {
+	ID             string  `json:"id"`
+	LineItemID     *string `json:"line_item_id,omitempty"`
+	Version        int     `json:"version"`
+	Field          string  `json:"field"`
+	Value          *string `json:"value"`
+	Source         string  `json:"source"`
+	EditedByUserID *string `json:"edited_by_user_id,omitempty"`
+	CreatedAt      string  `json:"created_at"`
+}
+
+// IsEditable reports whether the bill can be corrected, which is not the case
+// while an analysis may still overwrite its fields.
+func (b *Bill) IsEditable() bool {
+	return b.Status != BillStatusPending && b.Status != BillStatusProcessing && b.Status != BillStatusAwaitingUpload
+}
+
+// FieldSources returns where the current value of each populated bill field came from.
+func (b *Bill) FieldSources() map[string]FieldSource {
+	values := map[string]bool{
+		BillFieldVendorName:      b.VendorName != nil,
+		BillFieldTransactionDate: b.TransactionDate != nil,
+		BillFieldTotalAmount:     b.TotalAmount != nil,
+		BillFieldSubtotalAmount:  b.SubtotalAmount != nil,
+		BillFieldTaxAmount:       b.TaxAmount != nil,
+		BillFieldTipAmount:       b.TipAmount != nil,
+		BillFieldDiscountAmount:  b.DiscountAmount != nil,
+	}
+	return fieldSources(values, b.EditedFields, FieldSourceOCR)
+}
+
+// FieldSources returns where the current value of each populated line item field came from.
+func (l *LineItem) FieldSources() map[string]FieldSource {
+	values := map[string]bool{
+		LineItemFieldDescription: l.Description != "",
+		LineItemFieldQuantity:    l.Quantity != nil,
+		LineItemFieldUnitPrice:   l.UnitPrice != nil,
+		LineItemFieldTotalPrice:  l.TotalPrice != nil,
+	}
+	return fieldSources(values, l.EditedFields, l.origin())
+}
+
+func fieldSources(values map[string]bool, edited FieldNames, origin FieldSource) map[string]FieldSource {
+	sources := make(map[string]FieldSource)
+	for field, populated := range values {
+		switch {
+		case edited.Contains(field):
+			sources[field] = FieldSourceUser
+		case populated:
+			sources[field] = origin
+		}
+	}
+	return sources
+}
+
+func (l *LineItem) origin() FieldSource {
+	if l.Source == "" {
+		return FieldSourceOCR
+	}
+	return l.Source
+}
+
+// NewCorrection starts an edit of the bill by a user and bumps its version.
+func (b *Bill) NewCorrection(userID uuid.UUID) (*BillCorrection, error) {
+	if userID == uuid.Nil {
+		return nil, ErrUserIDEmpty
+	}
+	if !b.IsEditable() {
+		return nil, ErrBillNotEditable
+	}
+
+	correction := &BillCorrection{Bill: b, BaseVersion: b.Version, userID: userID}
+	b.Version++
+	return correction, nil
+}
+
+// ApplyBillChanges applies a correction of the bill fields. It returns ErrEmptyCorrection
+// when no field actually changed.
+func (c *BillCorrection) ApplyBillChanges(req UpdateBillRequest) error {
+	b := c.Bill
+	changed := false
+
+	if req.VendorName != nil {
+		vendorName := strings.TrimSpace(*req.VendorName)
+		if c.recordBillField(BillFieldVendorName, b.VendorName, &vendorName) {
+			b.VendorName = &vendorName
+			changed = true
+		}
+	}
+
+	if req.TransactionDate != nil {
+		transactionDate, err := parseTransactionDate(*req.TransactionDate)
+		if err != nil {
+			return err
+		}
+		if c.recordBillField(BillFieldTransactionDate, formatRevisionTime(b.TransactionDate), formatRevisionTime(&transactionDate)) {
+			b.TransactionDate = &transactionDate
+			changed = true
+		}
+	}
+
+	amounts := []struct {
+		field string
+		value *float64
+		dest  **float64
+	}{
+		{BillFieldTotalAmount, req.TotalAmount, &b.TotalAmount},
+		{BillFieldSubtotalAmount, req.SubtotalAmount, &b.SubtotalAmount},
+		{BillFieldTaxAmount, req.TaxAmount, &b.TaxAmount},
+		{BillFieldTipAmount, req.TipAmount, &b.TipAmount},
+		{BillFieldDiscountAmount, req.DiscountAmount, &b.DiscountAmount},
+	}
+	for _, amount := range amounts {
+		if amount.value == nil {
+			continue
+		}
+		value := *amount.value
+		if c.recordBillField(amount.field, formatRevisionFloat(*amount.dest), formatRevisionFloat(&value)) {
+			*amount.dest = &value
+			changed = true
+		}
+	}
+
+	if !changed {
+		return ErrEmptyCorrection
+	}
+
+	b.Reconcile()
+	return nil
+}
+
+// AddLineItem adds a line item entered by the user.
+func (c *BillCorrection) AddLineItem(req CreateLineItemRequest) (*LineItem, error) {
+	if err := validateLineItemValues(req.Quantity, req.UnitPrice, req.TotalPrice); err != nil {
+		return nil, err
+	}
+
+	item, err := NewLineItem(c.Bill.ID, strings.TrimSpace(req.Description), req.Quantity, req.UnitPrice, req.TotalPrice)
+	if err != nil {
+		return nil, err
+	}
+	item.ID = uuid.New()
+	item.Source = FieldSourceUser
+
+	fields := map[string]*string{
+		LineItemFieldDescription: &item.Description,
+		LineItemFieldQuantity:    formatRevisionFloat(item.Quantity),
+		LineItemFieldUnitPrice:   formatRevisionFloat(item.UnitPrice),
+		LineItemFieldTotalPrice:  formatRevisionFloat(item.TotalPrice),
+	}
+	for _, field := range []string{LineItemFieldDescription, LineItemFieldQuantity, LineItemFieldUnitPrice, LineItemFieldTotalPrice} {
+		if fields[field] != nil {
+			c.addRevision(&item.ID, field, fields[field], FieldSourceUser)
+		}
+	}
+
+	c.Bill.LineItems = append(c.Bill.LineItems, *item)
+	c.CreatedLineItems = append(c.CreatedLineItems, item)
+	c.Bill.Reconcile()
+	return item, nil
+}
+
+// UpdateLineItem applies a correction of a line item. It returns ErrEmptyCorrection
+// when no field actually changed.
+func (c *BillCorrection) UpdateLineItem(lineItemID uuid.UUID, req UpdateLineItemRequest) (*LineItem, error) {
+	item := c.Bill.FindLineItem(lineItemID)
+	if item == nil {
+		return nil, ErrLineItemNotFound
+	}
+	if err := validateLineItemValues(req.Quantity, req.UnitPrice, req.TotalPrice); err != nil {
+		return nil, err
+	}
+
+	changed := false
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		if description == "" {
+			return nil, ErrLineItemDescriptionEmpty
+		}
+		if c.recordLineItemField(item, LineItemFieldDescription, &item.Description, &description) {
+			item.Description = description
+			changed = true
+		}
+	}
+
+	values := []struct {
+		field string
+		value *float64
+		dest  **float64
+	}{
+		{LineItemFieldQuantity, req.Quantity, &item.Quantity},
+		{LineItemFieldUnitPrice, req.UnitPrice, &item.UnitPrice},
+		{LineItemFieldTotalPrice, req.TotalPrice, &item.TotalPrice},
+	}
+	for _, v := range values {
+		if v.value == nil {
+			continue
+		}
+		value := *v.value
+		if c.recordLineItemField(item, v.field, formatRevisionFloat(*v.dest), formatRevisionFloat(&value)) {
+			*v.dest = &value
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil, ErrEmptyCorrection
+	}
+
+	c.UpdatedLineItems = append(c.UpdatedLineItems, item)
+	c.Bill.Reconcile()
+	return item, nil
+}
+
+// DeleteLineItem removes a line item from the bill.
+func (c *BillCorrection) DeleteLineItem(lineItemID uuid.UUID) error {
+	if c.Bill.FindLineItem(lineItemID) == nil {
+		return ErrLineItemNotFound
+	}
+
+	deleted := "true"
+	c.addRevision(&lineItemID, LineItemFieldDeleted, &deleted, FieldSourceUser)
+
+	remaining := c.Bill.LineItems[:0]
+	for _, item := range c.Bill.LineItems {
+		if item.ID != lineItemID {
+			remaining = append(remaining, item)
+		}
+	}
+	c.Bill.LineItems = remaining
+	c.DeletedLineItemIDs = append(c.DeletedLineItemIDs, lineItemID)
+	c.Bill.Reconcile()
+	return nil
+}
+
+// recordBillField records a change of a bill field and reports whether the value changed.
+func (c *BillCorrection) recordBillField(field string, oldValue, newValue *string) bool {
+	if equalRevisionValues(oldValue, newValue) {
+		return false
+	}
+	// Keep the OCR value the first time a user overrides it
+	if !c.Bill.EditedFields.Contains(field) {
+		c.addBaselineRevision(nil, field, oldValue)
+	}
+	c.addRevision(nil, field, newValue, FieldSourceUser)
+	c.Bill.EditedFields.add(field)
+	return true
+}
+
+// recordLineItemField records a change of a line item field and reports whether the value changed.
+func (c *BillCorrection) recordLineItemField(item *LineItem, field string, oldValue, newValue *string) bool {
+	if equalRevisionValues(oldValue, newValue) {
+		return false
+	}
+	if item.origin() == FieldSourceOCR && !item.EditedFields.Contains(field) {
+		c.addBaselineRevision(&item.ID, field, oldValue)
+	}
+	c.addRevision(&item.ID, field, newValue, FieldSourceUser)
+	item.EditedFields.add(field)
+	return true
+}
+
+// addBaselineRevision records the OCR value a field had before this edit.
+func (c *BillCorrection) addBaselineRevision(lineItemID *uuid.UUID, field string, value *string) {
+	c.Revisions = append(c.Revisions, &BillRevision{
+		BillID:     c.Bill.ID,
+		LineItemID: lineItemID,
+		Version:    c.BaseVersion,
+		Field:      field,
+		Value:      value,
+		Source:     FieldSourceOCR,
+	})
+}
+
+func (c *BillCorrection) addRevision(lineItemID *uuid.UUID, field string, value *string, source FieldSource) {
+	userID := c.userID
+	c.Revisions = append(c.Revisions, &BillRevision{
+		BillID:         c.Bill.ID,
+		LineItemID:     lineItemID,
+		Version:        c.Bill.Version,
+		Field:          field,
+		Value:          value,
+		Source:         source,
+		EditedByUserID: &userID,
+	})
+}
+
+func validateLineItemValues(quantity, unitPrice, totalPrice *float64) error {
+	if quantity != nil && *quantity <= 0 {
+		return ErrInvalidLineItemQuantity
+	}
+	if (unitPrice != nil && *unitPrice < 0) || (totalPrice != nil && *totalPrice < 0) {
+		return ErrInvalidLineItemPrice
+	}
+	return nil
+}
+
+func parseTransactionDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, ErrInvalidTransactionDate
+}
+
+func formatRevisionFloat(f *float64) *string {
+	if f == nil {
+		return nil
+	}
+	value := strconv.FormatFloat(*f, 'f', -1, 64)
+	return &value
+}
+
+func formatRevisionTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	value := t.Format(time.RFC3339)
+	return &value
+}
+
+func equalRevisionValues(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
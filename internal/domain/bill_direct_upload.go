@@ -0,0 +1,40 @@
+package domain
+
+import "time"
+
+// BillUploadURLRequest asks for a URL to upload a bill file directly to storage.
+// The upload must be exactly Size bytes and sent with ContentType.
+type BillUploadURLRequest struct {
+	Filename    string `json:"filename" binding:"required"`
+	ContentType string `json:"content_type" binding:"required"`
+	Size        int64  `json:"size" binding:"required,gt=0"`
+}
+
+// CompleteBillUploadRequest represents the analysis configuration of a bill
+// whose file was uploaded directly to storage. A named profile is used as the
+// base configuration and the other fields override it.
+type CompleteBillUploadRequest struct {
+	Profile       string   `json:"profile"`
+	Languages     []string `json:"languages"`
+	MinConfidence *float64 `json:"min_confidence"`
+	CurrencyCodes []string `json:"currency_codes"`
+}
+
+// BillUpload is a bill awaiting its file, with the URL the client uploads it to
+type BillUpload struct {
+	Bill      *Bill
+	URL       string
+	Method    string
+	Headers   map[string]string // headers the upload must be sent with
+	ExpiresAt time.Time
+}
+
+// BillUploadDTO represents a bill awaiting its file in API responses
+type BillUploadDTO struct {
+	BillID    string            `json:"bill_id"`
+	Status    string            `json:"status"`
+	UploadURL string            `json:"upload_url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt string            `json:"expires_at"`
+}
@@ -11,6 +11,13 @@ import (
 type BillWithURL struct {
 	Bill    *Bill
 	FileURL string
+	BillPreviewURLs
+}
+
+// BillPreviewURLs are the URLs of the renditions of a bill file, empty when it has none
+type BillPreviewURLs struct {
+	ThumbnailURL string
+	PreviewURL   string
 }
 
 // UploadBillRequest represents the data needed to upload a bill
@@ -36,11 +43,33 @@ type BillDTO struct {
 	UploadedAt      string        `json:"uploaded_at"`
 	ProcessedAt     *string       `json:"processed_at,omitempty"`
 	FileURL         string        `json:"file_url"`
+	ThumbnailURL    string        `json:"thumbnail_url,omitempty"`
+	PreviewURL      string        `json:"preview_url,omitempty"`
 	VendorName      string        `json:"vendor_name,omitempty"`
 	TransactionDate *string       `json:"transaction_date,omitempty"`
 	TotalAmount     *float64      `json:"total_amount,omitempty"`
+	SubtotalAmount  *float64      `json:"subtotal_amount,omitempty"`
+	TaxAmount       *float64      `json:"tax_amount,omitempty"`
+	TipAmount       *float64      `json:"tip_amount,omitempty"`
+	DiscountAmount  *float64      `json:"discount_amount,omitempty"`
 	LineItems       []LineItemDTO `json:"line_items,omitempty"`
 	TextTrackOutput string        `json:"text_track_output,omitempty"`
+	GroupID         *string       `json:"group_id,omitempty"`
+	PaidByMemberID  *string       `json:"paid_by_member_id,omitempty"`
+	BatchID         *string       `json:"batch_id,omitempty"`
+
+	DuplicateOfBillID *string `json:"duplicate_of_bill_id,omitempty"`
+	DuplicateReason   string  `json:"duplicate_reason,omitempty"`
+
+	ReconciliationStatus string           `json:"reconciliation_status,omitempty"`
+	Discrepancies        []DiscrepancyDTO `json:"discrepancies,omitempty"`
+
+	Version         int                          `json:"version"`
+	FieldSources    map[string]string            `json:"field_sources,omitempty"`
+	FieldDetections map[string]FieldDetectionDTO `json:"field_detections,omitempty"`
+
+	AnalysisAttempts  int     `json:"analysis_attempts"`
+	LastAnalysisError *string `json:"last_analysis_error,omitempty"`
 }
 
 // LineItemDTO represents a line item data transfer object
@@ -50,6 +79,12 @@ type LineItemDTO struct {
 	Quantity    *float64 `json:"quantity,omitempty"`
 	UnitPrice   *float64 `json:"unit_price,omitempty"`
 	TotalPrice  *float64 `json:"total_price,omitempty"`
+	PageNumber  *int     `json:"page_number,omitempty"`
+
+	Source          string                       `json:"source,omitempty"`
+	FieldSources    map[string]string            `json:"field_sources,omitempty"`
+	FieldDetections map[string]FieldDetectionDTO `json:"field_detections,omitempty"`
+	Assignments     []LineItemAssignmentDTO      `json:"assignments,omitempty"`
 }
 
 // BillSummaryDTO represents a summarized bill for listing
@@ -61,6 +96,8 @@ type BillSummaryDTO struct {
 	VendorName      string     `json:"vendor_name,omitempty"`
 	TotalAmount     *float64   `json:"total_amount,omitempty"`
 	TransactionDate *time.Time `json:"transaction_date,omitempty"`
+	ThumbnailURL    string     `json:"thumbnail_url,omitempty"`
+	PreviewURL      string     `json:"preview_url,omitempty"`
 }
 
 // ListBillsResponseDTO represents a response for listing bills
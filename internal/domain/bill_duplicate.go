@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// DuplicateReason says how a bill was recognized as a duplicate of another
+type DuplicateReason string
+
+const (
+	DuplicateSameFile    DuplicateReason = "same_file"    // byte-for-byte the same upload
+	DuplicateSameImage   DuplicateReason = "same_image"   // a new photo of the same receipt
+	DuplicateSameReceipt DuplicateReason = "same_receipt" // same vendor, date and total once analyzed
+)
+
+// DuplicatePolicy says what happens to an upload that duplicates a bill the
+// user already has, or one in the group it is uploaded to
+type DuplicatePolicy string
+
+const (
+	DuplicatePolicyOff   DuplicatePolicy = "off"   // duplicates aren't looked for
+	DuplicatePolicyFlag  DuplicatePolicy = "flag"  // duplicates are stored and marked with the bill they repeat
+	DuplicatePolicyBlock DuplicatePolicy = "block" // duplicate files are rejected; duplicates found after analysis are only marked
+)
+
+// MaxPerceptualHashDistance is how many bits the perceptual hashes of two
+// photos may differ by and still be taken for the same receipt
+const MaxPerceptualHashDistance = 6
+
+// DuplicateBillError is returned when an upload is rejected as a duplicate
+type DuplicateBillError struct {
+	BillID uuid.UUID // the bill the upload duplicates
+	Reason DuplicateReason
+}
+
+func (e *DuplicateBillError) Error() string {
+	return fmt.Sprintf("%s: %s of bill %s", ErrDuplicateBill, strings.ReplaceAll(string(e.Reason), "_", " "), e.BillID)
+}
+
+func (e *DuplicateBillError) Unwrap() error {
+	return ErrDuplicateBill
+}
+
+// PerceptualHashDistance returns how many bits two perceptual hashes differ by
+func PerceptualHashDistance(a, b int64) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+// MarkDuplicateOf records that the bill repeats another one
+func (b *Bill) MarkDuplicateOf(billID uuid.UUID, reason DuplicateReason) {
+	b.DuplicateOfBillID = &billID
+	b.DuplicateReason = reason
+}
+
+// ReceiptFingerprint identifies a receipt by its vendor, date and total, so the
+// same receipt is recognized however it was photographed. Bills missing any of
+// them have no fingerprint.
+func (b *Bill) ReceiptFingerprint() *string {
+	if b.VendorName == nil || b.TransactionDate == nil || b.TotalAmount == nil {
+		return nil
+	}
+
+	vendor := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, *b.VendorName)
+	if vendor == "" {
+		return nil
+	}
+
+	cents := int64(math.Round(*b.TotalAmount * 100))
+	fingerprint := fmt.Sprintf("%s|%s|%d", vendor, b.TransactionDate.Format("2006-01-02"), cents)
+	return &fingerprint
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BillEventType string
+
+const (
+	BillEventStatusChanged BillEventType = "bill.status"
+)
+
+// BillEvent is pushed to the owner of a bill whenever its status changes.
+// Summary holds the parsed result once the bill is analyzed.
+type BillEvent struct {
+	Type       BillEventType   `json:"type"`
+	BillID     uuid.UUID       `json:"bill_id"`
+	UserID     uuid.UUID       `json:"user_id"`
+	Status     BillStatus      `json:"status"`
+	Error      *string         `json:"error,omitempty"`
+	Summary    *BillSummaryDTO `json:"summary,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// NewBillStatusEvent creates an event with the current status of the bill
+func NewBillStatusEvent(bill *Bill) *BillEvent {
+	event := &BillEvent{
+		Type:       BillEventStatusChanged,
+		BillID:     bill.ID,
+		UserID:     bill.UserID,
+		Status:     bill.Status,
+		OccurredAt: time.Now().UTC(),
+	}
+
+	switch bill.Status {
+	case BillStatusAnalyzed:
+		summary := BillSummaryDTO{
+			ID:              bill.ID.String(),
+			Filename:        bill.Filename,
+			Status:          string(bill.Status),
+			UploadedAt:      bill.UploadedAt,
+			TotalAmount:     bill.TotalAmount,
+			TransactionDate: bill.TransactionDate,
+		}
+		if bill.VendorName != nil {
+			summary.VendorName = *bill.VendorName
+		}
+		event.Summary = &summary
+	case BillStatusFailed, BillStatusPending:
+		event.Error = bill.LastAnalysisError
+	}
+
+	return event
+}
+
+// IsFinal reports whether the bill reached a status it only leaves on user action
+func (e *BillEvent) IsFinal() bool {
+	return e.Status == BillStatusAnalyzed || e.Status == BillStatusFailed
+}
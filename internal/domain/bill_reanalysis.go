@@ -0,0 +1,146 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExtractedBillData holds the values read from a bill document.
+type ExtractedBillData struct {
+	VendorName      *string
+	TransactionDate *time.Time
+	TotalAmount     *float64
+	SubtotalAmount  *float64
+	TaxAmount       *float64
+	TipAmount       *float64
+	DiscountAmount  *float64
+	RawText         string
+	LineItems       []*LineItem
+	FieldDetections FieldDetections
+}
+
+// Reanalysis collects the changes of running OCR again on a bill so they can
+// be saved atomically. BaseVersion is the bill version it was computed against.
+type Reanalysis struct {
+	Bill               *Bill
+	BaseVersion        int
+	CreatedLineItems   []*LineItem
+	RemovedLineItemIDs []uuid.UUID
+	Revisions          []*BillRevision
+}
+
+// ReanalyzeBillRequest represents the options for analyzing a stored bill again.
+// A named profile is used as the base configuration and the other fields override it.
+type ReanalyzeBillRequest struct {
+	Profile              string   `json:"profile"`
+	Languages            []string `json:"languages"`
+	MinConfidence        *float64 `json:"min_confidence"`
+	CurrencyCodes        []string `json:"currency_codes"`
+	OverwriteCorrections bool     `json:"overwrite_corrections"`
+}
+
+// Reanalyze replaces the OCR values of the bill with freshly extracted data.
+// Fields and line items corrected by a user are kept, and items a user deleted
+// are not added back, unless overwrite is set. Extracted items are matched to
+// the ones corrected or deleted by their extraction key, so repeated lines are
+// told apart. deletedLineItems are the items users removed from the bill.
+func (b *Bill) Reanalyze(data ExtractedBillData, deletedLineItems []LineItem, overwrite bool) *Reanalysis {
+	reanalysis := &Reanalysis{Bill: b, BaseVersion: b.Version}
+	b.Version++
+
+	fields := []struct {
+		field string
+		value *string
+		apply func()
+	}{
+		{BillFieldVendorName, data.VendorName, func() { b.VendorName = data.VendorName }},
+		{BillFieldTransactionDate, formatRevisionTime(data.TransactionDate), func() { b.TransactionDate = data.TransactionDate }},
+		{BillFieldTotalAmount, formatRevisionFloat(data.TotalAmount), func() { b.TotalAmount = data.TotalAmount }},
+		{BillFieldSubtotalAmount, formatRevisionFloat(data.SubtotalAmount), func() { b.SubtotalAmount = data.SubtotalAmount }},
+		{BillFieldTaxAmount, formatRevisionFloat(data.TaxAmount), func() { b.TaxAmount = data.TaxAmount }},
+		{BillFieldTipAmount, formatRevisionFloat(data.TipAmount), func() { b.TipAmount = data.TipAmount }},
+		{BillFieldDiscountAmount, formatRevisionFloat(data.DiscountAmount), func() { b.DiscountAmount = data.DiscountAmount }},
+	}
+	currentValues := map[string]*string{
+		BillFieldVendorName:      b.VendorName,
+		BillFieldTransactionDate: formatRevisionTime(b.TransactionDate),
+		BillFieldTotalAmount:     formatRevisionFloat(b.TotalAmount),
+		BillFieldSubtotalAmount:  formatRevisionFloat(b.SubtotalAmount),
+		BillFieldTaxAmount:       formatRevisionFloat(b.TaxAmount),
+		BillFieldTipAmount:       formatRevisionFloat(b.TipAmount),
+		BillFieldDiscountAmount:  formatRevisionFloat(b.DiscountAmount),
+	}
+
+	var editedFields FieldNames
+	for _, f := range fields {
+		if b.EditedFields.Contains(f.field) && !overwrite {
+			editedFields.add(f.field)
+			continue
+		}
+		if !equalRevisionValues(currentValues[f.field], f.value) {
+			reanalysis.Revisions = append(reanalysis.Revisions, &BillRevision{
+				BillID:  b.ID,
+				Version: b.Version,
+				Field:   f.field,
+				Value:   f.value,
+				Source:  FieldSourceOCR,
+			})
+		}
+		f.apply()
+	}
+	b.EditedFields = editedFields
+	b.FieldDetections = data.FieldDetections
+
+	// Keep what users entered and skip extracted items they already fixed or
+	// removed. Items saved before extraction keys existed stand for one extracted
+	// item with the same description each.
+	skipped := make(map[string]bool)
+	legacy := make(map[string]int)
+	skip := func(item LineItem) {
+		if item.ExtractionKey != "" {
+			skipped[item.ExtractionKey] = true
+		} else if item.Source == FieldSourceOCR {
+			legacy[normalizeDescription(item.Description)]++
+		}
+	}
+	var lineItems []LineItem
+	for _, item := range b.LineItems {
+		if !overwrite && (item.Source == FieldSourceUser || len(item.EditedFields) > 0) {
+			lineItems = append(lineItems, item)
+			skip(item)
+			continue
+		}
+		reanalysis.RemovedLineItemIDs = append(reanalysis.RemovedLineItemIDs, item.ID)
+	}
+	if !overwrite {
+		for _, item := range deletedLineItems {
+			skip(item)
+		}
+	}
+
+	for _, item := range data.LineItems {
+		if item.ExtractionKey != "" && skipped[item.ExtractionKey] {
+			continue
+		}
+		if description := normalizeDescription(item.Description); legacy[description] > 0 {
+			legacy[description]--
+			continue
+		}
+		item.BillID = b.ID
+		if item.ID == uuid.Nil {
+			item.ID = uuid.New()
+		}
+		lineItems = append(lineItems, *item)
+		reanalysis.CreatedLineItems = append(reanalysis.CreatedLineItems, item)
+	}
+	b.LineItems = lineItems
+
+	b.Reconcile()
+	return reanalysis
+}
+
+func normalizeDescription(description string) string {
+	return strings.ToLower(strings.Join(strings.Fields(description), " "))
+}
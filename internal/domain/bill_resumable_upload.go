@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ResumableUpload is a bill file uploaded in chunks, which can be resumed after
+// the connection drops. It becomes a bill once all of it is uploaded.
+type ResumableUpload struct {
+	ID            uuid.UUID       `gorm:"type:uuid;primary_key;"`
+	UserID        uuid.UUID       `gorm:"type:uuid;not null;index"`
+	Filename      string          `gorm:"size:255;not null"`
+	ContentType   string          `gorm:"size:100;not null"`
+	Size          int64           `gorm:"not null"`
+	UploadedBytes int64           `gorm:"not null;default:0"`
+	Metadata      string          `gorm:"type:text"` // metadata as sent by the client, returned to it as is
+	Options       AnalysisOptions `gorm:"type:jsonb"`
+	StorageKey    string          `gorm:"type:text;not null"` // key the file is stored under once complete
+	StagingHandle string          `gorm:"type:text"`          // handle of the chunks kept by the upload stager
+	BillID        *uuid.UUID      `gorm:"type:uuid"`          // bill created from the complete upload
+	ExpiresAt     time.Time       `gorm:"not null;index"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+
+	// Lease of the request writing a chunk, so chunks are written one at a time
+	LockToken   *uuid.UUID `gorm:"type:uuid"`
+	LockedUntil *time.Time
+}
+
+func (u *ResumableUpload) TableName() string {
+	return "resumable_uploads"
+}
+
+func (u *ResumableUpload) BeforeCreate(tx *gorm.DB) (err error) {
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+	now := time.Now().UTC()
+	u.CreatedAt = now
+	u.UpdatedAt = now
+	return
+}
+
+// IsComplete reports whether every byte of the file was uploaded
+func (u *ResumableUpload) IsComplete() bool {
+	return u.UploadedBytes >= u.Size
+}
+
+// ResumableUploadRequest starts a resumable upload of a bill file of Size bytes
+type ResumableUploadRequest struct {
+	UserID      uuid.UUID
+	Filename    string
+	ContentType string
+	Size        int64
+	Metadata    string
+}
@@ -0,0 +1,287 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ClaimSessionStatus string
+
+const (
+	ClaimSessionOpen      ClaimSessionStatus = "open"
+	ClaimSessionFinalized ClaimSessionStatus = "finalized"
+)
+
+// OverClaimPolicy decides what happens when members claim more units of an
+// item than the receipt lists.
+type OverClaimPolicy string
+
+const (
+	// OverClaimSplit splits over-claimed items proportionally to the claimed units.
+	OverClaimSplit OverClaimPolicy = "split"
+	// OverClaimFlag flags over-claimed items and blocks finalizing until they are fixed.
+	OverClaimFlag OverClaimPolicy = "flag"
+)
+
+// ClaimSession lets the members of a group claim the line items they had on a bill.
+type ClaimSession struct {
+	ID              uuid.UUID          `gorm:"type:uuid;primary_key;"`
+	BillID          uuid.UUID          `gorm:"type:uuid;not null;uniqueIndex"`
+	GroupID         uuid.UUID          `gorm:"type:uuid;not null;index"`
+	OpenedByUserID  uuid.UUID          `gorm:"type:uuid;not null"`
+	Status          ClaimSessionStatus `gorm:"size:25;not null"`
+	OverClaimPolicy OverClaimPolicy    `gorm:"size:25;not null"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	FinalizedAt     *time.Time
+	Claims          []LineItemClaim `gorm:"foreignKey:SessionID;constraint:OnDelete:CASCADE;"`
+}
+
+func (s *ClaimSession) TableName() string {
+	return "bill_claim_sessions"
+}
+
+func (s *ClaimSession) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	now := time.Now().UTC()
+	s.CreatedAt = now
+	s.UpdatedAt = now
+	return
+}
+
+func (s *ClaimSession) BeforeUpdate(tx *gorm.DB) (err error) {
+	s.UpdatedAt = time.Now().UTC()
+	return
+}
+
+// LineItemClaim records how many units of a line item a member says they had.
+type LineItemClaim struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;"`
+	SessionID     uuid.UUID `gorm:"type:uuid;not null;index"`
+	LineItemID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	GroupMemberID uuid.UUID `gorm:"type:uuid;not null;index"`
+	Units         float64   `gorm:"type:decimal(10,3);not null"`
+	UpdatedAt     time.Time
+}
+
+func (c *LineItemClaim) TableName() string {
+	return "bill_line_item_claims"
+}
+
+func (c *LineItemClaim) BeforeCreate(tx *gorm.DB) (err error) {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	c.UpdatedAt = time.Now().UTC()
+	return
+}
+
+// NewClaimSession opens a claim session for a bill attached to a group.
+func NewClaimSession(bill *Bill, openedByUserID uuid.UUID, policy OverClaimPolicy) (*ClaimSession, error) {
+	if bill.GroupID == nil {
+		return nil, ErrBillNotInGroup
+	}
+	if openedByUserID == uuid.Nil {
+		return nil, ErrUserIDEmpty
+	}
+	if policy == "" {
+		policy = OverClaimSplit
+	}
+	if policy != OverClaimSplit && policy != OverClaimFlag {
+		return nil, ErrInvalidInput
+	}
+
+	return &ClaimSession{
+		BillID:          bill.ID,
+		GroupID:         *bill.GroupID,
+		OpenedByUserID:  openedByUserID,
+		Status:          ClaimSessionOpen,
+		OverClaimPolicy: policy,
+	}, nil
+}
+
+// IsLocked reports whether the session no longer accepts claims.
+func (s *ClaimSession) IsLocked() bool {
+	return s.Status != ClaimSessionOpen
+}
+
+// NewMemberClaims validates a member's claims against the bill's line items.
+// Each claim may not exceed the item quantity on its own; over-claiming across
+// members is allowed and resolved according to the session policy.
+func NewMemberClaims(session *ClaimSession, bill *Bill, memberID uuid.UUID, inputs []LineItemClaimInput) ([]*LineItemClaim, error) {
+	claims := make([]*LineItemClaim, 0, len(inputs))
+	seen := make(map[uuid.UUID]bool, len(inputs))
+	for _, input := range inputs {
+		lineItemID, err := uuid.Parse(input.LineItemID)
+		if err != nil {
+			return nil, ErrInvalidInput
+		}
+		if seen[lineItemID] {
+			return nil, ErrInvalidInput
+		}
+		seen[lineItemID] = true
+
+		item := bill.FindLineItem(lineItemID)
+		if item == nil {
+			return nil, ErrLineItemNotFound
+		}
+		if input.Units <= 0 {
+			return nil, ErrInvalidAssignmentUnits
+		}
+		if input.Units > quantityOf(*item) {
+			return nil, ErrClaimExceedsQuantity
+		}
+
+		claims = append(claims, &LineItemClaim{
+			SessionID:     session.ID,
+			LineItemID:    lineItemID,
+			GroupMemberID: memberID,
+			Units:         input.Units,
+		})
+	}
+	return claims, nil
+}
+
+// ItemClaimState summarizes the claims on a single line item.
+type ItemClaimState struct {
+	LineItem       LineItem
+	AvailableUnits float64
+	ClaimedUnits   float64
+	UnclaimedUnits float64
+	OverClaimed    bool
+	Claims         []LineItemClaim
+}
+
+// Summarize returns the claim state of every line item on the bill.
+func (s *ClaimSession) Summarize(bill *Bill) []ItemClaimState {
+	byItem := make(map[uuid.UUID][]LineItemClaim)
+	for _, claim := range s.Claims {
+		byItem[claim.LineItemID] = append(byItem[claim.LineItemID], claim)
+	}
+
+	states := make([]ItemClaimState, len(bill.LineItems))
+	for i, item := range bill.LineItems {
+		state := ItemClaimState{
+			LineItem:       item,
+			AvailableUnits: quantityOf(item),
+			Claims:         byItem[item.ID],
+		}
+		for _, claim := range state.Claims {
+			state.ClaimedUnits += claim.Units
+		}
+		if state.ClaimedUnits > state.AvailableUnits {
+			state.OverClaimed = true
+		} else {
+			state.UnclaimedUnits = state.AvailableUnits - state.ClaimedUnits
+		}
+		states[i] = state
+	}
+	return states
+}
+
+// BuildAssignments turns the session claims into line item assignments.
+// Over-claimed items are split by claimed units, or rejected with
+// ErrClaimsOverClaimed under OverClaimFlag. Unclaimed leftover units are
+// spread evenly across all group members; items nobody claimed get no
+// assignments and are split evenly by the ledger.
+func (s *ClaimSession) BuildAssignments(bill *Bill, group *Group) (map[uuid.UUID][]*LineItemAssignment, error) {
+	states := s.Summarize(bill)
+	for _, state := range states {
+		if state.OverClaimed && s.OverClaimPolicy == OverClaimFlag {
+			return nil, ErrClaimsOverClaimed
+		}
+	}
+
+	assignments := make(map[uuid.UUID][]*LineItemAssignment, len(states))
+	for _, state := range states {
+		itemAssignments := []*LineItemAssignment{}
+		if len(state.Claims) > 0 {
+			units := make(map[uuid.UUID]float64, len(group.Members))
+			for _, claim := range state.Claims {
+				if _, ok := group.FindMember(claim.GroupMemberID); ok {
+					units[claim.GroupMemberID] += claim.Units
+				}
+			}
+			if state.UnclaimedUnits > 0 && len(group.Members) > 0 {
+				leftover := state.UnclaimedUnits / float64(len(group.Members))
+				for _, member := range group.Members {
+					units[member.ID] += leftover
+				}
+			}
+			for _, member := range group.Members {
+				if units[member.ID] <= 0 {
+					continue
+				}
+				itemAssignments = append(itemAssignments, &LineItemAssignment{
+					LineItemID:    state.LineItem.ID,
+					GroupMemberID: member.ID,
+					Units:         units[member.ID],
+				})
+			}
+		}
+		assignments[state.LineItem.ID] = itemAssignments
+	}
+	return assignments, nil
+}
+
+// Finalize locks the session so no further claims are accepted.
+func (s *ClaimSession) Finalize() error {
+	if s.IsLocked() {
+		return ErrClaimSessionLocked
+	}
+	now := time.Now().UTC()
+	s.Status = ClaimSessionFinalized
+	s.FinalizedAt = &now
+	return nil
+}
+
+// OpenClaimSessionRequest represents the request to open a bill for claiming.
+type OpenClaimSessionRequest struct {
+	OverClaimPolicy string `json:"over_claim_policy"`
+}
+
+// SubmitClaimsRequest replaces all claims of the calling member.
+type SubmitClaimsRequest struct {
+	Claims []LineItemClaimInput `json:"claims"`
+}
+
+// LineItemClaimInput is a single claim within SubmitClaimsRequest.
+type LineItemClaimInput struct {
+	LineItemID string  `json:"line_item_id" binding:"required"`
+	Units      float64 `json:"units"`
+}
+
+// ClaimSessionDTO represents the state of a claim session.
+type ClaimSessionDTO struct {
+	ID              string              `json:"id"`
+	BillID          string              `json:"bill_id"`
+	GroupID         string              `json:"group_id"`
+	Status          string              `json:"status"`
+	OverClaimPolicy string              `json:"over_claim_policy"`
+	CreatedAt       string              `json:"created_at"`
+	FinalizedAt     *string             `json:"finalized_at,omitempty"`
+	Items           []ItemClaimStateDTO `json:"items"`
+}
+
+// ItemClaimStateDTO represents the claims on a single line item.
+type ItemClaimStateDTO struct {
+	LineItemID     string           `json:"line_item_id"`
+	Description    string           `json:"description"`
+	TotalPrice     float64          `json:"total_price"`
+	AvailableUnits float64          `json:"available_units"`
+	ClaimedUnits   float64          `json:"claimed_units"`
+	UnclaimedUnits float64          `json:"unclaimed_units"`
+	OverClaimed    bool             `json:"over_claimed"`
+	Claims         []MemberClaimDTO `json:"claims"`
+}
+
+// MemberClaimDTO represents a member's claim on a line item.
+type MemberClaimDTO struct {
+	MemberID   string  `json:"member_id"`
+	MemberName string  `json:"member_name"`
+	Units      float64 `json:"units"`
+}
@@ -1,6 +1,9 @@
 package domain
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
 
 // General Domain Errors (examples)
 var (
@@ -37,20 +40,124 @@ var (
 	ErrFileDownloadFailed         = errors.New("file download failed")
 	ErrTextractAnalysisFailed     = errors.New("Textract analysis failed")
 	ErrTextractDataExtraction     = errors.New("failed to extract data from Textract result")
+	ErrGroupMemberNotFound        = errors.New("group member not found")
+	ErrLineItemNotFound           = errors.New("line item not found")
+	ErrBillNotInGroup             = errors.New("bill is not attached to a group")
+	ErrInvalidAssignmentUnits     = errors.New("assignment units must be positive")
+	ErrInvalidSettlementAmount    = errors.New("settlement amount must be positive")
+	ErrSettlementSameMember       = errors.New("a member cannot settle with themselves")
+	ErrMemberAlreadyLinked        = errors.New("user is already linked to another member of the group")
+	ErrEmptyBatch                 = errors.New("batch upload has no files")
+	ErrBatchTooLarge              = errors.New("batch upload has too many files")
+	ErrBatchNotFound              = errors.New("batch not found")
+	ErrDuplicateBill              = errors.New("bill is a duplicate")
+)
+
+// Claim Session Errors
+var (
+	ErrClaimSessionNotFound = errors.New("claim session not found")
+	ErrClaimSessionExists   = errors.New("bill already has a claim session")
+	ErrClaimSessionLocked   = errors.New("claim session is finalized and no longer accepts claims")
+	ErrClaimsOverClaimed    = errors.New("some line items are claimed more times than their quantity")
+	ErrClaimExceedsQuantity = errors.New("claimed units exceed the line item quantity")
+	ErrNotGroupMember       = errors.New("user is not linked to a member of the bill's group")
+)
+
+// Bill Correction Errors
+var (
+	ErrBillNotEditable         = errors.New("bill cannot be edited while it is being analyzed")
+	ErrBillVersionConflict     = errors.New("bill was modified concurrently, reload it and try again")
+	ErrInvalidTransactionDate  = errors.New("transaction date must be formatted as YYYY-MM-DD or RFC 3339")
+	ErrInvalidLineItemQuantity = errors.New("line item quantity must be positive")
+	ErrInvalidLineItemPrice    = errors.New("line item prices cannot be negative")
+	ErrEmptyCorrection         = errors.New("no fields to update were provided")
+)
+
+// Share Link Errors
+var (
+	ErrShareLinkInvalid  = errors.New("share link is invalid")
+	ErrShareLinkExpired  = errors.New("share link has expired")
+	ErrShareLinkDisabled = errors.New("share links are not configured")
+)
+
+// File Storage Errors
+var (
+	ErrStoredFileNotFound = errors.New("stored file not found")
+	ErrFileLinkInvalid    = errors.New("file link is invalid")
+	ErrFileLinkExpired    = errors.New("file link has expired")
+	ErrMasterKeyNotFound  = errors.New("master key not found")
+	ErrFileDecryption     = errors.New("stored file can't be decrypted")
+)
+
+// Resumable Upload Errors
+var (
+	ErrResumableUploadNotFound = errors.New("upload not found")
+	ErrResumableUploadExpired  = errors.New("upload has expired")
+	ErrUploadOffsetMismatch    = errors.New("upload offset does not match the bytes already received")
+	ErrResumableUploadLocked   = errors.New("upload is receiving another chunk, try again")
 )
 
 // Text Analysis Errors (as previously defined)
 var (
-	ErrTextAnalysisFailed = errors.New("text analysis failed")
+	ErrTextAnalysisFailed  = errors.New("text analysis failed")
+	ErrAnalysisTemporary   = errors.New("text analysis is temporarily unavailable")
+	ErrUnsupportedDocument = errors.New("document is not supported for text analysis")
+	ErrAnalysisRunNotFound = errors.New("bill has no stored analysis to parse again")
+	ErrAnalysisSuperseded  = errors.New("bill was claimed by another analysis")
 	// ... other text analysis errors
 )
 
+// Upload Validation Errors
+var (
+	ErrMissingFile         = errors.New("no file was uploaded")
+	ErrEmptyFile           = errors.New("uploaded file is empty")
+	ErrFileTooLarge        = errors.New("uploaded file is too large")
+	ErrRequestTooLarge     = errors.New("upload request is too large")
+	ErrUnsupportedFileType = errors.New("file type is not supported, upload a JPEG, PNG, PDF or TIFF file")
+	ErrCorruptFile         = errors.New("uploaded file is damaged or incomplete")
+	ErrBillUploadCompleted = errors.New("the file of this bill was already uploaded")
+)
+
+// UploadErrorCode returns the code reported to clients for an upload validation
+// error, or an empty string for other errors
+func UploadErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrMissingFile):
+		return "missing_file"
+	case errors.Is(err, ErrEmptyFile):
+		return "empty_file"
+	case errors.Is(err, ErrFileTooLarge):
+		return "file_too_large"
+	case errors.Is(err, ErrRequestTooLarge):
+		return "request_too_large"
+	case errors.Is(err, ErrUnsupportedFileType):
+		return "unsupported_file_type"
+	case errors.Is(err, ErrCorruptFile):
+		return "corrupt_file"
+	case errors.Is(err, ErrDuplicateBill):
+		return "duplicate_bill"
+	}
+	return ""
+}
+
 // Queue/Filestore Errors (if added)
 var (
 	ErrQueueFailed   = errors.New("failed to interact with the queue")
 	ErrStorageFailed = errors.New("failed to interact with the file store")
+	ErrNoJobs        = errors.New("no jobs are ready to run")
+	ErrJobNotFound   = errors.New("job not found")
+	ErrJobNotFailed  = errors.New("only dead-lettered jobs can be retried")
+	ErrJobLeaseLost  = errors.New("job lease expired and the job was handed to another worker")
 )
 
+// IsRetryableAnalysisError reports whether an analysis failed for a temporary
+// reason, such as throttling, a server error or a timeout, and may succeed later.
+func IsRetryableAnalysisError(err error) bool {
+	return errors.Is(err, ErrAnalysisTemporary) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, context.Canceled)
+}
+
 // Helper function (optional) for checking specific error types if needed elsewhere
 func IsErrNotFound(err error) bool {
 	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrGroupNotFound) || errors.Is(err, ErrUserNotFound)
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// ReviewConfidence is the confidence below which an extracted value is marked
+// for the user to review
+const ReviewConfidence = 0.9
+
+// BoundingBox locates a value on its page, as ratios of the page width and height
+// measured from the top left corner
+type BoundingBox struct {
+	Left   float64 `json:"left"`
+	Top    float64 `json:"top"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// FieldDetection is how confident OCR was of the value of a field and where on
+// the document it was read
+type FieldDetection struct {
+	Confidence      *float64     `json:"confidence"` // 0 to 1, nil when OCR reported none
+	PageNumber      *int         `json:"page_number,omitempty"`
+	BoundingBox     *BoundingBox `json:"bounding_box,omitempty"`
+	ReviewThreshold *float64     `json:"review_threshold,omitempty"` // stricter than ReviewConfidence when the analysis asked for it
+}
+
+// NeedsReview reports whether the value was read with too little confidence to
+// be trusted without a look from the user. Values of unknown confidence are
+// always reviewed.
+func (d FieldDetection) NeedsReview() bool {
+	if d.Confidence == nil {
+		return true
+	}
+	threshold := ReviewConfidence
+	if d.ReviewThreshold != nil && *d.ReviewThreshold > threshold {
+		threshold = *d.ReviewThreshold
+	}
+	return *d.Confidence < threshold
+}
+
+// FieldDetections holds the detection of each extracted field by field name,
+// stored as a JSON column
+type FieldDetections map[string]FieldDetection
+
+func (d FieldDetections) Value() (driver.Value, error) {
+	if d == nil {
+		return nil, nil
+	}
+	return json.Marshal(d)
+}
+
+func (d *FieldDetections) Scan(value interface{}) error {
+	if value == nil {
+		*d = nil
+		return nil
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported type for field detections")
+	}
+	return json.Unmarshal(data, d)
+}
+
+// FieldDetectionDTO represents the detection of an extracted field
+type FieldDetectionDTO struct {
+	Confidence  *float64     `json:"confidence"`
+	PageNumber  *int         `json:"page_number,omitempty"`
+	BoundingBox *BoundingBox `json:"bounding_box,omitempty"`
+	NeedsReview bool         `json:"needs_review"`
+}
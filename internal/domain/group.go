@@ -19,9 +19,10 @@ type Group struct {
 
 // GroupMember represents a member of a group with their name.
 type GroupMember struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	GroupID   uuid.UUID `gorm:"type:uuid;not null;index"`
-	Name      string    `gorm:"size:255;not null"`
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	GroupID   uuid.UUID  `gorm:"type:uuid;not null;index"`
+	Name      string     `gorm:"size:255;not null"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index"` // Registered user linked to this member, if any
 	CreatedAt time.Time
 }
 
@@ -38,9 +39,15 @@ type GroupDTO struct {
 
 // GroupMemberDTO represents the data transfer object for group members.
 type GroupMemberDTO struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	CreatedAt string `json:"created_at"`
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	UserID    *string `json:"user_id,omitempty"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// LinkMemberRequest represents the request to link a group member to a registered user.
+type LinkMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
 }
 
 // CreateGroupRequest represents the request to create a new group.
@@ -135,6 +142,13 @@ func (g *Group) UpdateGroup(name string, description string, memberNames []strin
 	g.Description = description
 	g.UpdatedAt = time.Now().UTC()
 
+	// Keep the IDs of members that stay in the group, since bill assignments
+	// and share links reference them.
+	existing := make(map[string]GroupMember, len(g.Members))
+	for _, member := range g.Members {
+		existing[member.Name] = member
+	}
+
 	// Update members
 	g.Members = make([]GroupMember, len(memberNames))
 	now := time.Now().UTC()
@@ -142,6 +156,11 @@ func (g *Group) UpdateGroup(name string, description string, memberNames []strin
 		if memberName == "" {
 			return ErrGroupMemberNameEmpty
 		}
+		if member, ok := existing[memberName]; ok {
+			g.Members[i] = member
+			delete(existing, memberName)
+			continue
+		}
 		g.Members[i] = GroupMember{
 			ID:        uuid.New(),
 			GroupID:   g.ID,
@@ -167,6 +186,44 @@ func (g *Group) GetMemberNames() []string {
 	return names
 }
 
+// FindMember returns the member with the given ID, if present.
+func (g *Group) FindMember(memberID uuid.UUID) (*GroupMember, bool) {
+	for i := range g.Members {
+		if g.Members[i].ID == memberID {
+			return &g.Members[i], true
+		}
+	}
+	return nil, false
+}
+
+// MemberForUser returns the member linked to the given registered user, if any.
+func (g *Group) MemberForUser(userID uuid.UUID) (*GroupMember, bool) {
+	for i := range g.Members {
+		if g.Members[i].UserID != nil && *g.Members[i].UserID == userID {
+			return &g.Members[i], true
+		}
+	}
+	return nil, false
+}
+
+// LinkMember links a member to a registered user. A user can be linked to
+// at most one member of the group.
+func (g *Group) LinkMember(memberID, userID uuid.UUID) (*GroupMember, error) {
+	if userID == uuid.Nil {
+		return nil, ErrUserIDEmpty
+	}
+	member, ok := g.FindMember(memberID)
+	if !ok {
+		return nil, ErrGroupMemberNotFound
+	}
+	if linked, ok := g.MemberForUser(userID); ok && linked.ID != memberID {
+		return nil, ErrMemberAlreadyLinked
+	}
+
+	member.UserID = &userID
+	return member, nil
+}
+
 // HasMember checks if a given name is a member of the group.
 func (g *Group) HasMember(name string) bool {
 	for _, member := range g.Members {
@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type GroupEventType string
+
+const (
+	GroupEventGroupUpdated       GroupEventType = "group.updated"
+	GroupEventGroupDeleted       GroupEventType = "group.deleted"
+	GroupEventMemberLinked       GroupEventType = "member.linked"
+	GroupEventExpenseAdded       GroupEventType = "expense.added"
+	GroupEventAssignmentUpdated  GroupEventType = "assignment.updated"
+	GroupEventClaimsOpened       GroupEventType = "claims.opened"
+	GroupEventClaimsUpdated      GroupEventType = "claims.updated"
+	GroupEventClaimsFinalized    GroupEventType = "claims.finalized"
+	GroupEventSettlementRecorded GroupEventType = "settlement.recorded"
+)
+
+// GroupEvent records a change to a group so members viewing it can follow along.
+// IDs increase with every event, which lets clients resume after a reconnect.
+type GroupEvent struct {
+	ID          int64           `gorm:"primaryKey;autoIncrement"`
+	GroupID     uuid.UUID       `gorm:"type:uuid;not null;index"`
+	Type        GroupEventType  `gorm:"size:50;not null"`
+	ActorUserID *uuid.UUID      `gorm:"type:uuid"`
+	Payload     json.RawMessage `gorm:"type:jsonb;serializer:json"`
+	CreatedAt   time.Time
+}
+
+func (e *GroupEvent) TableName() string {
+	return "group_events"
+}
+
+// NewGroupEvent creates an event with the given payload encoded as JSON
+func NewGroupEvent(groupID uuid.UUID, eventType GroupEventType, actorUserID uuid.UUID, payload interface{}) (*GroupEvent, error) {
+	if groupID == uuid.Nil {
+		return nil, ErrInvalidInput
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding group event payload: %w", err)
+	}
+
+	event := &GroupEvent{
+		GroupID:   groupID,
+		Type:      eventType,
+		Payload:   data,
+		CreatedAt: time.Now().UTC(),
+	}
+	if actorUserID != uuid.Nil {
+		event.ActorUserID = &actorUserID
+	}
+	return event, nil
+}
+
+// CanView reports whether the user owns the group or is linked to one of its members
+func (g *Group) CanView(userID uuid.UUID) bool {
+	if g.IsOwner(userID) {
+		return true
+	}
+	_, ok := g.MemberForUser(userID)
+	return ok
+}
+
+// GroupEventDTO represents a group event sent to clients
+type GroupEventDTO struct {
+	ID          int64           `json:"id"`
+	GroupID     string          `json:"group_id"`
+	Type        string          `json:"type"`
+	ActorUserID *string         `json:"actor_user_id,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   string          `json:"created_at"`
+}
+
+// GroupUpdatedPayload is the payload of group.updated events
+type GroupUpdatedPayload struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	MemberNames []string `json:"member_names"`
+}
+
+// MemberLinkedPayload is the payload of member.linked events
+type MemberLinkedPayload struct {
+	MemberID string `json:"member_id"`
+	UserID   string `json:"user_id"`
+}
+
+// ExpenseAddedPayload is the payload of expense.added events
+type ExpenseAddedPayload struct {
+	BillID         string   `json:"bill_id"`
+	VendorName     string   `json:"vendor_name,omitempty"`
+	TotalAmount    *float64 `json:"total_amount,omitempty"`
+	PaidByMemberID string   `json:"paid_by_member_id"`
+}
+
+// AssignmentUpdatedPayload is the payload of assignment.updated events
+type AssignmentUpdatedPayload struct {
+	BillID      string                  `json:"bill_id"`
+	LineItemID  string                  `json:"line_item_id"`
+	Assignments []LineItemAssignmentDTO `json:"assignments"`
+}
+
+// SettlementRecordedPayload is the payload of settlement.recorded events
+type SettlementRecordedPayload struct {
+	SettlementID string  `json:"settlement_id"`
+	FromMemberID string  `json:"from_member_id"`
+	ToMemberID   string  `json:"to_member_id"`
+	Amount       float64 `json:"amount"`
+}
+
+// ClaimsPayload is the payload of claims.* events
+type ClaimsPayload struct {
+	BillID   string  `json:"bill_id"`
+	MemberID *string `json:"member_id,omitempty"` // member whose claims changed
+}
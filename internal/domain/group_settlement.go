@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Settlement records a payment from one group member to another to settle up.
+// It counts towards the balances of both members.
+type Settlement struct {
+	ID               uuid.UUID  `gorm:"type:uuid;primary_key;"`
+	GroupID          uuid.UUID  `gorm:"type:uuid;not null;index"`
+	FromMemberID     uuid.UUID  `gorm:"type:uuid;not null"`
+	ToMemberID       uuid.UUID  `gorm:"type:uuid;not null"`
+	Amount           float64    `gorm:"type:decimal(10,2);not null"`
+	Note             string     `gorm:"size:255"`
+	RecordedByUserID *uuid.UUID `gorm:"type:uuid"`
+	CreatedAt        time.Time
+}
+
+func (s *Settlement) TableName() string {
+	return "group_settlements"
+}
+
+func (s *Settlement) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = time.Now().UTC()
+	}
+	return
+}
+
+// NewSettlement records that a member of the group paid another one
+func NewSettlement(group *Group, fromMemberID, toMemberID uuid.UUID, amount float64, note string, recordedByUserID uuid.UUID) (*Settlement, error) {
+	if _, ok := group.FindMember(fromMemberID); !ok {
+		return nil, ErrGroupMemberNotFound
+	}
+	if _, ok := group.FindMember(toMemberID); !ok {
+		return nil, ErrGroupMemberNotFound
+	}
+	if fromMemberID == toMemberID {
+		return nil, ErrSettlementSameMember
+	}
+	if amount = roundCents(amount); amount <= 0 {
+		return nil, ErrInvalidSettlementAmount
+	}
+
+	settlement := &Settlement{
+		GroupID:      group.ID,
+		FromMemberID: fromMemberID,
+		ToMemberID:   toMemberID,
+		Amount:       amount,
+		Note:         note,
+	}
+	if recordedByUserID != uuid.Nil {
+		settlement.RecordedByUserID = &recordedByUserID
+	}
+	return settlement, nil
+}
+
+// RecordSettlementRequest represents the request to record a payment between group members.
+type RecordSettlementRequest struct {
+	FromMemberID string  `json:"from_member_id" binding:"required"`
+	ToMemberID   string  `json:"to_member_id" binding:"required"`
+	Amount       float64 `json:"amount" binding:"required"`
+	Note         string  `json:"note" binding:"max=255"`
+}
+
+// SettlementDTO represents a recorded settlement in API responses
+type SettlementDTO struct {
+	ID           string  `json:"id"`
+	GroupID      string  `json:"group_id"`
+	FromMemberID string  `json:"from_member_id"`
+	ToMemberID   string  `json:"to_member_id"`
+	Amount       float64 `json:"amount"`
+	Note         string  `json:"note,omitempty"`
+	CreatedAt    string  `json:"created_at"`
+}
@@ -15,10 +15,24 @@ type LineItem struct {
 	Quantity    *float64  `gorm:"type:decimal(10,3);default:1;"` // Optional, defaults to 1
 	UnitPrice   *float64  `gorm:"type:decimal(10,2);"`           // Price per unit
 	TotalPrice  *float64  `gorm:"type:decimal(10,2);"`           // Quantity * UnitPrice (or directly extracted)
+	PageNumber  *int      // Page of the document the item was extracted from, nil for items added by users
+
+	// ExtractionKey identifies where in the Textract response the item was read,
+	// so that analyzing the bill again recognizes it. Empty for items added by users.
+	ExtractionKey string `gorm:"size:32"`
 	// Consider adding: ProductCode, Category (user-defined or ML-suggested)
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	DeletedAt gorm.DeletedAt `gorm:"index"` // If line items can be soft-deleted individually
+
+	// DeletedByUser is set on items a user deleted, which analyzing the bill again doesn't add back
+	DeletedByUser bool `gorm:"not null;default:false"`
+
+	Source          FieldSource     `gorm:"size:10;not null;default:'ocr'"` // Whether the item was extracted or added by a user
+	EditedFields    FieldNames      `gorm:"type:jsonb"`                     // Fields whose current value was entered by a user
+	FieldDetections FieldDetections `gorm:"type:jsonb"`                     // Confidence and location of the extracted fields
+
+	Assignments []LineItemAssignment `gorm:"foreignKey:LineItemID;constraint:OnDelete:CASCADE;"`
 }
 
 func (l *LineItem) TableName() string {
@@ -41,6 +55,22 @@ func (l *LineItem) BeforeUpdate(tx *gorm.DB) (err error) {
 	return
 }
 
+// Amount returns the line total, computing it from quantity and unit price
+// when Textract did not detect it directly.
+func (l *LineItem) Amount() float64 {
+	if l.TotalPrice != nil {
+		return *l.TotalPrice
+	}
+	if l.UnitPrice != nil {
+		quantity := 1.0
+		if l.Quantity != nil {
+			quantity = *l.Quantity
+		}
+		return quantity * *l.UnitPrice
+	}
+	return 0
+}
+
 func NewLineItem(billID uuid.UUID, description string, quantity, unitPrice, totalPrice *float64) (*LineItem, error) {
 	if billID == uuid.Nil {
 		return nil, ErrBillIDEmpty
@@ -52,6 +82,7 @@ func NewLineItem(billID uuid.UUID, description string, quantity, unitPrice, tota
 	item := &LineItem{
 		BillID:      billID,
 		Description: description,
+		Source:      FieldSourceOCR,
 	}
 
 	if quantity != nil {
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LineItemAssignment records how many units of a line item a group member had.
+type LineItemAssignment struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;"`
+	LineItemID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	GroupMemberID uuid.UUID `gorm:"type:uuid;not null;index"`
+	Units         float64   `gorm:"type:decimal(10,3);not null;default:1"`
+	CreatedAt     time.Time
+}
+
+func (a *LineItemAssignment) TableName() string {
+	return "bill_line_item_assignments"
+}
+
+func (a *LineItemAssignment) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now().UTC()
+	}
+	return
+}
+
+func NewLineItemAssignment(lineItemID, groupMemberID uuid.UUID, units float64) (*LineItemAssignment, error) {
+	if lineItemID == uuid.Nil || groupMemberID == uuid.Nil {
+		return nil, ErrInvalidInput
+	}
+	if units <= 0 {
+		return nil, ErrInvalidAssignmentUnits
+	}
+
+	return &LineItemAssignment{
+		LineItemID:    lineItemID,
+		GroupMemberID: groupMemberID,
+		Units:         units,
+	}, nil
+}
+
+// AttachBillToGroupRequest represents the request to link a bill to a group.
+type AttachBillToGroupRequest struct {
+	GroupID        string `json:"group_id" binding:"required"`
+	PaidByMemberID string `json:"paid_by_member_id" binding:"required"`
+}
+
+// AssignLineItemRequest represents the request to assign a line item to group members.
+type AssignLineItemRequest struct {
+	Assignments []LineItemAssignmentInput `json:"assignments"`
+}
+
+// LineItemAssignmentInput is a single member assignment within AssignLineItemRequest.
+type LineItemAssignmentInput struct {
+	MemberID string  `json:"member_id" binding:"required"`
+	Units    float64 `json:"units"`
+}
+
+// LineItemAssignmentDTO represents a line item assignment data transfer object
+type LineItemAssignmentDTO struct {
+	MemberID string  `json:"member_id"`
+	Units    float64 `json:"units"`
+}
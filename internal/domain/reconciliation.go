@@ -0,0 +1,166 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+)
+
+type ReconciliationStatus string
+
+const (
+	// ReconciliationBalanced means the line items, adjustments and total add up.
+	ReconciliationBalanced ReconciliationStatus = "balanced"
+	// ReconciliationMismatch means at least one discrepancy was found.
+	ReconciliationMismatch ReconciliationStatus = "mismatch"
+	// ReconciliationIncomplete means there was not enough data to check the total.
+	ReconciliationIncomplete ReconciliationStatus = "incomplete"
+)
+
+// Fields a discrepancy can refer to.
+const (
+	DiscrepancyFieldTotalAmount    = "total_amount"
+	DiscrepancyFieldSubtotalAmount = "subtotal_amount"
+	DiscrepancyFieldLineTotalPrice = "line_item.total_price"
+)
+
+// reconciliationTolerance absorbs rounding of prices to cents.
+const reconciliationTolerance = 0.01
+
+// Discrepancy describes a field whose value doesn't match what the other fields imply.
+type Discrepancy struct {
+	Field      string     `json:"field"`
+	LineItemID *uuid.UUID `json:"line_item_id,omitempty"`
+	Expected   float64    `json:"expected"`
+	Actual     float64    `json:"actual"`
+	Message    string     `json:"message"`
+}
+
+// Difference returns how far the actual value is from the expected one.
+func (d Discrepancy) Difference() float64 {
+	return roundCents(d.Actual - d.Expected)
+}
+
+// Discrepancies is stored as a JSON column on the bill.
+type Discrepancies []Discrepancy
+
+func (d Discrepancies) Value() (driver.Value, error) {
+	if d == nil {
+		return nil, nil
+	}
+	return json.Marshal(d)
+}
+
+func (d *Discrepancies) Scan(value interface{}) error {
+	if value == nil {
+		*d = nil
+		return nil
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported type for discrepancies")
+	}
+	return json.Unmarshal(data, d)
+}
+
+// DiscrepancyDTO represents a reconciliation discrepancy.
+type DiscrepancyDTO struct {
+	Field      string  `json:"field"`
+	LineItemID *string `json:"line_item_id,omitempty"`
+	Expected   float64 `json:"expected"`
+	Actual     float64 `json:"actual"`
+	Difference float64 `json:"difference"`
+	Message    string  `json:"message"`
+}
+
+// Reconcile checks that each line's quantity times unit price equals its total
+// price, and that the line items plus tax and tip minus discounts add up to the
+// bill total. The result is stored on the bill.
+func (b *Bill) Reconcile() {
+	var discrepancies Discrepancies
+	var itemsTotal float64
+
+	for i := range b.LineItems {
+		item := &b.LineItems[i]
+		itemsTotal += item.Amount()
+
+		if item.Quantity == nil || item.UnitPrice == nil || item.TotalPrice == nil {
+			continue
+		}
+		expected := *item.Quantity * *item.UnitPrice
+		// Rounding of the unit price is multiplied by the quantity
+		tolerance := math.Max(reconciliationTolerance, math.Abs(*item.Quantity)*0.005)
+		if math.Abs(expected-*item.TotalPrice) > tolerance {
+			lineItemID := item.ID
+			discrepancies = append(discrepancies, Discrepancy{
+				Field:      DiscrepancyFieldLineTotalPrice,
+				LineItemID: &lineItemID,
+				Expected:   roundCents(expected),
+				Actual:     *item.TotalPrice,
+				Message:    fmt.Sprintf("%q: quantity times unit price is %.2f but total price is %.2f", item.Description, expected, *item.TotalPrice),
+			})
+		}
+	}
+	itemsTotal = roundCents(itemsTotal)
+
+	// Items can only be checked against the totals when every line was read
+	itemTolerance := reconciliationTolerance * math.Max(1, float64(len(b.LineItems)))
+
+	if b.SubtotalAmount != nil && len(b.LineItems) > 0 {
+		if math.Abs(itemsTotal-*b.SubtotalAmount) > itemTolerance {
+			discrepancies = append(discrepancies, Discrepancy{
+				Field:    DiscrepancyFieldSubtotalAmount,
+				Expected: itemsTotal,
+				Actual:   *b.SubtotalAmount,
+				Message:  fmt.Sprintf("line items add up to %.2f but the subtotal is %.2f", itemsTotal, *b.SubtotalAmount),
+			})
+		}
+	}
+
+	status := ReconciliationBalanced
+	if b.TotalAmount == nil || len(b.LineItems) == 0 {
+		status = ReconciliationIncomplete
+	} else {
+		base := itemsTotal
+		if b.SubtotalAmount != nil {
+			base = *b.SubtotalAmount
+		}
+		adjustments := valueOrZero(b.TipAmount) - math.Abs(valueOrZero(b.DiscountAmount))
+		expected := roundCents(base + valueOrZero(b.TaxAmount) + adjustments)
+		// Many receipts print prices with tax included, in which case the tax
+		// line is informative and must not be added again
+		taxIncluded := roundCents(base + adjustments)
+
+		if math.Abs(expected-*b.TotalAmount) > itemTolerance && math.Abs(taxIncluded-*b.TotalAmount) > itemTolerance {
+			discrepancies = append(discrepancies, Discrepancy{
+				Field:    DiscrepancyFieldTotalAmount,
+				Expected: expected,
+				Actual:   *b.TotalAmount,
+				Message:  fmt.Sprintf("line items plus tax, tip and discounts add up to %.2f but the total is %.2f", expected, *b.TotalAmount),
+			})
+		}
+	}
+
+	if len(discrepancies) > 0 {
+		status = ReconciliationMismatch
+	}
+
+	b.ReconciliationStatus = status
+	b.Discrepancies = discrepancies
+}
+
+func valueOrZero(f *float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return *f
+}
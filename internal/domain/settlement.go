@@ -0,0 +1,268 @@
+package domain
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// settlementEpsilon is the smallest balance (in currency units) considered non-zero.
+const settlementEpsilon = 0.005
+
+// ItemShare is a member's portion of a single line item.
+type ItemShare struct {
+	LineItemID  uuid.UUID
+	Description string
+	Units       float64
+	Amount      float64
+	Shared      bool // true when the item had no assignments and was split evenly
+}
+
+// BillShare is a member's portion of a single bill, including their part of
+// tax, tip and any other difference between the line items and the bill total.
+type BillShare struct {
+	BillID          uuid.UUID
+	VendorName      string
+	TransactionDate *time.Time
+	BillTotal       float64
+	PaidByMemberID  uuid.UUID
+	Items           []ItemShare
+	Amount          float64
+}
+
+// MemberBalance is how much a member paid and owes across all group bills, and
+// what they paid or received in recorded settlements.
+type MemberBalance struct {
+	MemberID uuid.UUID
+	Name     string
+	Paid     float64
+	Owed     float64
+	Sent     float64
+	Received float64
+}
+
+// Net returns the member's balance; positive means the group owes them money.
+func (b MemberBalance) Net() float64 {
+	return roundCents(b.Paid - b.Owed + b.Sent - b.Received)
+}
+
+// Transfer is a suggested payment from one member to another to settle up.
+type Transfer struct {
+	FromMemberID uuid.UUID
+	ToMemberID   uuid.UUID
+	Amount       float64
+}
+
+// GroupLedger holds every member's shares and the transfers needed to settle the group.
+type GroupLedger struct {
+	Group     *Group
+	Shares    map[uuid.UUID][]BillShare
+	Balances  map[uuid.UUID]*MemberBalance
+	Transfers []Transfer
+}
+
+// BuildGroupLedger splits each bill among the group members and computes the
+// resulting balances and the transfers still needed after the recorded settlements.
+func BuildGroupLedger(group *Group, bills []*Bill, settlements []*Settlement) *GroupLedger {
+	ledger := &GroupLedger{
+		Group:    group,
+		Shares:   make(map[uuid.UUID][]BillShare),
+		Balances: make(map[uuid.UUID]*MemberBalance),
+	}
+	for _, member := range group.Members {
+		ledger.Balances[member.ID] = &MemberBalance{MemberID: member.ID, Name: member.Name}
+	}
+
+	for _, bill := range bills {
+		for memberID, share := range splitBill(group, bill) {
+			ledger.Shares[memberID] = append(ledger.Shares[memberID], *share)
+			ledger.Balances[memberID].Owed += share.Amount
+		}
+		if bill.PaidByMemberID != nil {
+			if balance, ok := ledger.Balances[*bill.PaidByMemberID]; ok {
+				balance.Paid += bill.Total()
+			}
+		}
+	}
+
+	for _, settlement := range settlements {
+		if from, ok := ledger.Balances[settlement.FromMemberID]; ok {
+			from.Sent += settlement.Amount
+		}
+		if to, ok := ledger.Balances[settlement.ToMemberID]; ok {
+			to.Received += settlement.Amount
+		}
+	}
+
+	for _, balance := range ledger.Balances {
+		balance.Paid = roundCents(balance.Paid)
+		balance.Owed = roundCents(balance.Owed)
+		balance.Sent = roundCents(balance.Sent)
+		balance.Received = roundCents(balance.Received)
+	}
+	ledger.Transfers = settle(group, ledger.Balances)
+	return ledger
+}
+
+// TransfersFrom returns the transfers the given member has to make.
+func (l *GroupLedger) TransfersFrom(memberID uuid.UUID) []Transfer {
+	var transfers []Transfer
+	for _, t := range l.Transfers {
+		if t.FromMemberID == memberID {
+			transfers = append(transfers, t)
+		}
+	}
+	return transfers
+}
+
+// TransfersTo returns the transfers the given member should receive.
+func (l *GroupLedger) TransfersTo(memberID uuid.UUID) []Transfer {
+	var transfers []Transfer
+	for _, t := range l.Transfers {
+		if t.ToMemberID == memberID {
+			transfers = append(transfers, t)
+		}
+	}
+	return transfers
+}
+
+// splitBill computes each member's share of a bill. Assigned items are split
+// by units, unassigned items evenly, and the remainder (tax, tip, discounts)
+// proportionally to each member's item subtotal.
+func splitBill(group *Group, bill *Bill) map[uuid.UUID]*BillShare {
+	shares := make(map[uuid.UUID]*BillShare, len(group.Members))
+	if len(group.Members) == 0 {
+		return shares
+	}
+
+	for _, member := range group.Members {
+		share := &BillShare{
+			BillID:          bill.ID,
+			TransactionDate: bill.TransactionDate,
+			BillTotal:       roundCents(bill.Total()),
+		}
+		if bill.VendorName != nil {
+			share.VendorName = *bill.VendorName
+		}
+		if bill.PaidByMemberID != nil {
+			share.PaidByMemberID = *bill.PaidByMemberID
+		}
+		shares[member.ID] = share
+	}
+
+	memberCount := float64(len(group.Members))
+	var itemsSubtotal float64
+	for _, item := range bill.LineItems {
+		amount := item.Amount()
+		itemsSubtotal += amount
+
+		var totalUnits float64
+		for _, a := range item.Assignments {
+			if _, ok := shares[a.GroupMemberID]; ok {
+				totalUnits += a.Units
+			}
+		}
+
+		if totalUnits <= 0 {
+			for _, member := range group.Members {
+				share := shares[member.ID]
+				share.Items = append(share.Items, ItemShare{
+					LineItemID:  item.ID,
+					Description: item.Description,
+					Units:       quantityOf(item) / memberCount,
+					Amount:      amount / memberCount,
+					Shared:      true,
+				})
+				share.Amount += amount / memberCount
+			}
+			continue
+		}
+
+		for _, a := range item.Assignments {
+			share, ok := shares[a.GroupMemberID]
+			if !ok {
+				continue
+			}
+			portion := amount * a.Units / totalUnits
+			share.Items = append(share.Items, ItemShare{
+				LineItemID:  item.ID,
+				Description: item.Description,
+				Units:       a.Units,
+				Amount:      portion,
+			})
+			share.Amount += portion
+		}
+	}
+
+	remainder := bill.Total() - itemsSubtotal
+	for _, share := range shares {
+		if itemsSubtotal != 0 {
+			share.Amount += remainder * share.Amount / itemsSubtotal
+		} else {
+			share.Amount += remainder / memberCount
+		}
+	}
+
+	for _, share := range shares {
+		share.Amount = roundCents(share.Amount)
+		for i := range share.Items {
+			share.Items[i].Amount = roundCents(share.Items[i].Amount)
+		}
+	}
+	return shares
+}
+
+// settle greedily matches the largest debtor with the largest creditor until
+// every balance is within settlementEpsilon of zero.
+func settle(group *Group, balances map[uuid.UUID]*MemberBalance) []Transfer {
+	type position struct {
+		memberID uuid.UUID
+		amount   float64
+	}
+
+	var debtors, creditors []position
+	// Iterate members in group order so the result is deterministic.
+	for _, member := range group.Members {
+		net := balances[member.ID].Net()
+		switch {
+		case net < -settlementEpsilon:
+			debtors = append(debtors, position{member.ID, -net})
+		case net > settlementEpsilon:
+			creditors = append(creditors, position{member.ID, net})
+		}
+	}
+	sort.SliceStable(debtors, func(i, j int) bool { return debtors[i].amount > debtors[j].amount })
+	sort.SliceStable(creditors, func(i, j int) bool { return creditors[i].amount > creditors[j].amount })
+
+	var transfers []Transfer
+	for i, j := 0, 0; i < len(debtors) && j < len(creditors); {
+		amount := math.Min(debtors[i].amount, creditors[j].amount)
+		transfers = append(transfers, Transfer{
+			FromMemberID: debtors[i].memberID,
+			ToMemberID:   creditors[j].memberID,
+			Amount:       roundCents(amount),
+		})
+		debtors[i].amount -= amount
+		creditors[j].amount -= amount
+		if debtors[i].amount <= settlementEpsilon {
+			i++
+		}
+		if creditors[j].amount <= settlementEpsilon {
+			j++
+		}
+	}
+	return transfers
+}
+
+func quantityOf(item LineItem) float64 {
+	if item.Quantity != nil {
+		return *item.Quantity
+	}
+	return 1
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ShareLinkClaims identifies the group member a guest share link was issued for.
+type ShareLinkClaims struct {
+	GroupID   uuid.UUID
+	MemberID  uuid.UUID
+	ExpiresAt time.Time
+}
+
+// IsExpired reports whether the link is no longer valid at the given time.
+func (c ShareLinkClaims) IsExpired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
+
+// ShareLinkDTO represents a newly issued guest share link.
+type ShareLinkDTO struct {
+	Token     string `json:"token"`
+	URL       string `json:"url"`
+	ViewURL   string `json:"view_url"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// GuestShareDTO is the read-only view of a member's shares served to guest links.
+type GuestShareDTO struct {
+	GroupName    string              `json:"group_name"`
+	MemberID     string              `json:"member_id"`
+	MemberName   string              `json:"member_name"`
+	Bills        []GuestBillShareDTO `json:"bills"`
+	TotalPaid    float64             `json:"total_paid"`
+	TotalOwed    float64             `json:"total_owed"`
+	Balance      float64             `json:"balance"`
+	PaysTo       []GuestTransferDTO  `json:"pays_to"`
+	ReceivesFrom []GuestTransferDTO  `json:"receives_from"`
+	ExpiresAt    string              `json:"expires_at"`
+}
+
+// GuestBillShareDTO is a member's share of a single bill.
+type GuestBillShareDTO struct {
+	BillID          string              `json:"bill_id"`
+	VendorName      string              `json:"vendor_name,omitempty"`
+	TransactionDate *string             `json:"transaction_date,omitempty"`
+	BillTotal       float64             `json:"bill_total"`
+	PaidBy          string              `json:"paid_by"`
+	Items           []GuestItemShareDTO `json:"items"`
+	Amount          float64             `json:"amount"`
+}
+
+// GuestItemShareDTO is a member's share of a single line item.
+type GuestItemShareDTO struct {
+	Description string  `json:"description"`
+	Units       float64 `json:"units"`
+	Amount      float64 `json:"amount"`
+	Shared      bool    `json:"shared"`
+}
+
+// GuestTransferDTO is a suggested payment between two members.
+type GuestTransferDTO struct {
+	MemberName string  `json:"member_name"`
+	Amount     float64 `json:"amount"`
+}
@@ -0,0 +1,18 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// BillEventBus delivers bill events to the subscribers of the bill owner.
+// Subscribe returns the channel of events and a function that cancels the
+// subscription and closes the channel. Close ends every subscription, e.g. so
+// open event streams don't hold up a graceful shutdown.
+type BillEventBus interface {
+	Publish(ctx context.Context, event *domain.BillEvent) error
+	Subscribe(userID uuid.UUID) (<-chan *domain.BillEvent, func())
+	Close() error
+}
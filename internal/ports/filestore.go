@@ -3,10 +3,77 @@ package ports
 import (
 	"context"
 	"io"
+	"time"
 )
 
+// FileMetadata describes a stored file
+type FileMetadata struct {
+	ContentType string
+	Filename    string // name of the file as uploaded, used when it is downloaded
+}
+
 type FileStore interface {
-	UploadFile(ctx context.Context, file io.Reader, destinationPath, contentType string) (string, error)
+	UploadFile(ctx context.Context, file io.Reader, destinationPath string, metadata FileMetadata) (string, error)
 	GetFileURL(ctx context.Context, storagePath string) (string, error)
 	DeleteFile(ctx context.Context, storagePath string) error
+	// CopyFile copies a stored file to destinationPath, replacing its metadata,
+	// and returns the storage path of the copy
+	CopyFile(ctx context.Context, storagePath, destinationPath string, metadata FileMetadata) (string, error)
+	// OpenFile reads a stored file. The caller closes it.
+	OpenFile(ctx context.Context, storagePath string) (io.ReadCloser, error)
+	// CreateUploadURL returns a URL a client can upload a file of exactly size
+	// bytes to, stored at destinationPath, without going through the API
+	CreateUploadURL(ctx context.Context, destinationPath string, metadata FileMetadata, size int64) (*UploadURL, error)
+	// ObjectKey returns the key a stored file is kept under from its storage path
+	ObjectKey(storagePath string) string
+}
+
+// UploadURL is a presigned URL a client uploads a file to directly
+type UploadURL struct {
+	URL         string
+	Method      string
+	Headers     map[string]string // headers the client must send with the upload
+	StoragePath string            // where the uploaded file is stored
+	ExpiresAt   time.Time
+}
+
+// DownloadedFile is a stored file opened to be served to a client
+type DownloadedFile struct {
+	Content io.ReadSeekCloser
+	ModTime time.Time
+	FileMetadata
+}
+
+// FileSignature is the part of a signed file URL that authorizes it
+type FileSignature struct {
+	Key       string
+	Expires   string
+	Size      string // exact size of an upload, empty for downloads
+	Signature string
+}
+
+// SignedFileServer serves the files of a store whose download and upload URLs
+// point at the API itself rather than at a storage service
+type SignedFileServer interface {
+	// OpenSignedFile checks the signature and expiry of a download URL and opens
+	// the file it points to
+	OpenSignedFile(ctx context.Context, signature FileSignature) (*DownloadedFile, error)
+	// StoreSignedFile checks the signature and expiry of an upload URL and stores
+	// the uploaded file
+	StoreSignedFile(ctx context.Context, signature FileSignature, file io.Reader) error
+}
+
+// UploadStager keeps the chunks of a resumable upload until all of them are
+// received. The handle it returns when an upload starts identifies the chunks
+// and is passed back with every later call for the same upload.
+type UploadStager interface {
+	// StartUpload prepares the staging of a file that will be stored at destinationPath
+	StartUpload(ctx context.Context, destinationPath string, metadata FileMetadata) (string, error)
+	// WriteChunk appends a chunk to an upload holding offset bytes and returns how
+	// many bytes of it were kept, which may be some even when it fails
+	WriteChunk(ctx context.Context, destinationPath, handle string, offset int64, chunk io.Reader) (int64, error)
+	// FinishUpload stores the complete file at destinationPath and returns its storage path
+	FinishUpload(ctx context.Context, destinationPath, handle string, metadata FileMetadata) (string, error)
+	// AbortUpload discards the chunks of an upload
+	AbortUpload(ctx context.Context, destinationPath, handle string) error
 }
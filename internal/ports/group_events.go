@@ -0,0 +1,16 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// GroupEventBus delivers group change events to the subscribers of the group.
+// It works like BillEventBus.
+type GroupEventBus interface {
+	Publish(ctx context.Context, event *domain.GroupEvent) error
+	Subscribe(groupID uuid.UUID) (<-chan *domain.GroupEvent, func())
+	Close() error
+}
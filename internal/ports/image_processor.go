@@ -0,0 +1,34 @@
+package ports
+
+import "context"
+
+// NormalizedImage is an uploaded file prepared for storage and analysis
+type NormalizedImage struct {
+	Content     []byte
+	ContentType string
+	Extension   string // file extension matching ContentType, including the dot
+	Changed     bool   // false when the file is stored as uploaded
+
+	// PerceptualHash is a 64-bit difference hash of the image, which changes
+	// little between photos of the same receipt. Nil for files that aren't images.
+	PerceptualHash *int64
+}
+
+// Previews are JPEG renditions of a bill file, of the image or of the first
+// page of a document
+type Previews struct {
+	Thumbnail []byte // small rendition for bill lists
+	Preview   []byte // medium rendition to look at the receipt
+}
+
+// PreviewRenderer renders the renditions of bill files shown in lists and previews
+type PreviewRenderer interface {
+	// RenderPreviews returns nil for files of a type it can't render
+	RenderPreviews(ctx context.Context, content []byte, contentType string) (*Previews, error)
+}
+
+// ImageProcessor prepares uploaded bill images before they are stored.
+// Files it doesn't handle, such as PDFs, are returned unchanged.
+type ImageProcessor interface {
+	Normalize(ctx context.Context, content []byte, filename, contentType string) (*NormalizedImage, error)
+}
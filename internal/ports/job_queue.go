@@ -0,0 +1,27 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// JobQueue stores background analysis jobs. Dequeue claims the next ready job
+// for the caller, or returns domain.ErrNoJobs when there is none. Retry puts a
+// job back to run at runAt, while Fail dead-letters it until it is requeued.
+// SetTextractJob records the asynchronous Textract job an attempt started.
+type JobQueue interface {
+	Enqueue(ctx context.Context, job *domain.AnalysisJob) error
+	Dequeue(ctx context.Context) (*domain.AnalysisJob, error)
+	// Complete, Retry, Fail and SetTextractJob only update a job under the lease
+	// it was dequeued with, failing with domain.ErrJobLeaseLost once another
+	// worker dequeued it
+	Complete(ctx context.Context, job *domain.AnalysisJob) error
+	Retry(ctx context.Context, job *domain.AnalysisJob, jobErr error, runAt time.Time) error
+	Fail(ctx context.Context, job *domain.AnalysisJob, jobErr error) error
+	SetTextractJob(ctx context.Context, job *domain.AnalysisJob, textractJobID string) error
+	ListFailed(ctx context.Context) ([]*domain.AnalysisJob, error)
+	Requeue(ctx context.Context, jobID uuid.UUID) (*domain.AnalysisJob, error)
+}
@@ -0,0 +1,33 @@
+package ports
+
+import "context"
+
+// KeyProvider protects the data keys stored files are encrypted with, by
+// wrapping them with master keys it holds, the way a key management service does.
+// Master keys are named by IDs stored next to the wrapped data keys, so data keys
+// wrapped with an older master key can still be unwrapped after a rotation.
+type KeyProvider interface {
+	// CurrentKeyID names the master key new data keys are wrapped with
+	CurrentKeyID() string
+	// WrapKey encrypts a data key with the current master key and returns it
+	// with the ID of that key
+	WrapKey(ctx context.Context, dataKey []byte) (wrapped []byte, keyID string, err error)
+	// UnwrapKey decrypts a data key wrapped with the master key named keyID
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// FileKeyRotation is what rotating the key of a stored file did
+type FileKeyRotation string
+
+const (
+	FileKeyRotated   FileKeyRotation = "rotated"   // data key rewrapped with the current master key
+	FileKeyEncrypted FileKeyRotation = "encrypted" // file stored before encryption was enabled, now encrypted
+	FileKeyCurrent   FileKeyRotation = "current"   // data key already wrapped with the current master key
+)
+
+// FileKeyRotator rewraps the data keys of stored files with the current master key
+type FileKeyRotator interface {
+	// RotateFileKey rewraps the data key of a stored file, encrypting files stored
+	// in plain. With dryRun it only reports what it would do.
+	RotateFileKey(ctx context.Context, storagePath string, dryRun bool) (FileKeyRotation, error)
+}
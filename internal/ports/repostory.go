@@ -20,6 +20,11 @@ type BillRepository interface {
 	GetBillsByUserID(ctx context.Context, userID uuid.UUID) ([]*domain.Bill, error)
 	UpdateBill(ctx context.Context, bill *domain.Bill) error
 	SaveBillWithLineItems(ctx context.Context, bill *domain.Bill, lineItems []*domain.LineItem) error
+	GetBillsByGroupID(ctx context.Context, groupID uuid.UUID) ([]*domain.Bill, error)
+	SetBillGroup(ctx context.Context, bill *domain.Bill) error
+	ReplaceLineItemAssignments(ctx context.Context, lineItemID uuid.UUID, assignments []*domain.LineItemAssignment) error
+	SaveBillCorrection(ctx context.Context, correction *domain.BillCorrection) error
+	GetBillRevisions(ctx context.Context, billID uuid.UUID) ([]*domain.BillRevision, error)
 }
 
 // GroupRepository defines the interface for group data access operations
@@ -30,4 +35,21 @@ type GroupRepository interface {
 	ListByOwner(ctx context.Context, ownerID uuid.UUID, options domain.ListGroupsOptions) ([]domain.Group, int64, error)
 	Update(ctx context.Context, group *domain.Group) error
 	Delete(ctx context.Context, groupID uuid.UUID) error
+	UpdateMember(ctx context.Context, member *domain.GroupMember) error
+	CreateSettlement(ctx context.Context, settlement *domain.Settlement) error
+	ListSettlements(ctx context.Context, groupID uuid.UUID) ([]*domain.Settlement, error)
+}
+
+// GroupEventRepository stores the log of group events clients resume from
+type GroupEventRepository interface {
+	Append(ctx context.Context, event *domain.GroupEvent) error
+	ListSince(ctx context.Context, groupID uuid.UUID, afterID int64, limit int) ([]*domain.GroupEvent, error)
+}
+
+// ClaimSessionRepository defines the interface for line item claiming sessions
+type ClaimSessionRepository interface {
+	Create(ctx context.Context, session *domain.ClaimSession) error
+	GetByBillID(ctx context.Context, billID uuid.UUID) (*domain.ClaimSession, error)
+	ReplaceMemberClaims(ctx context.Context, sessionID, memberID uuid.UUID, claims []*domain.LineItemClaim) error
+	Finalize(ctx context.Context, sessionID uuid.UUID, buildAssignments func(session *domain.ClaimSession) (map[uuid.UUID][]*domain.LineItemAssignment, error)) (*domain.ClaimSession, error)
 }
@@ -3,14 +3,28 @@ package ports
 import (
 	"context"
 	"time"
+
+	"github.com/dgsaltarin/SharedBitesBackend/internal/domain"
 )
 
 type ParsedTextractData struct {
 	VendorName      *string
 	TransactionDate *time.Time
 	TotalAmount     *float64
+	SubtotalAmount  *float64
+	TaxAmount       *float64
+	TipAmount       *float64
+	DiscountAmount  *float64
 	LineItems       []ParsedLineItem
 	RawTextOutput   string
+
+	// Detections holds the confidence and location of the vendor, date and
+	// total, by bill field name
+	Detections domain.FieldDetections
+
+	// RawResponse is the JSON of the full Textract response the data was parsed
+	// from, kept so it can be parsed again later
+	RawResponse []byte
 }
 
 type ParsedLineItem struct {
@@ -18,8 +32,26 @@ type ParsedLineItem struct {
 	Quantity    *float64
 	UnitPrice   *float64
 	TotalPrice  *float64
+	PageNumber  *int // page of the document the item was found on
+
+	// Key identifies where in the Textract response the item was read, the same
+	// every time the response is parsed
+	Key string
+
+	// Detections holds the confidence and location of each field of the item,
+	// by line item field name
+	Detections domain.FieldDetections
 }
 
 type TextProcessor interface {
 	AnalyzeDocument(ctx context.Context, storagePath string) (*ParsedTextractData, error)
 }
+
+// AnalysisCache keeps raw Textract responses by the SHA-256 hash of the analyzed
+// document, so a document is only sent to Textract once
+type AnalysisCache interface {
+	// Get returns the cached response for a document, false when there is none
+	Get(ctx context.Context, contentHash string) ([]byte, bool, error)
+	// Put caches the response for a document
+	Put(ctx context.Context, contentHash string, response []byte) error
+}
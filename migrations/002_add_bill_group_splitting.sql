@@ -0,0 +1,28 @@
+-- Migration: Link bills to groups and assign line items to group members
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Link bills to a group and the member who paid them
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS group_id UUID;
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS paid_by_member_id UUID;
+
+-- Create index on group_id for group ledger queries
+CREATE INDEX IF NOT EXISTS idx_bills_group_id ON bills(group_id);
+
+-- Create bill_line_item_assignments table
+CREATE TABLE IF NOT EXISTS bill_line_item_assignments (
+    id UUID PRIMARY KEY,
+    line_item_id UUID NOT NULL,
+    group_member_id UUID NOT NULL,
+    units DECIMAL(10,3) NOT NULL DEFAULT 1,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CONSTRAINT fk_bill_line_items_assignments FOREIGN KEY (line_item_id) REFERENCES bill_line_items(id) ON DELETE CASCADE
+);
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_bill_line_item_assignments_line_item_id ON bill_line_item_assignments(line_item_id);
+CREATE INDEX IF NOT EXISTS idx_bill_line_item_assignments_group_member_id ON bill_line_item_assignments(group_member_id);
+
+-- Add comments for documentation
+COMMENT ON COLUMN bills.group_id IS 'Reference to the group this bill is split within';
+COMMENT ON COLUMN bills.paid_by_member_id IS 'Reference to the group member who paid the bill';
+COMMENT ON TABLE bill_line_item_assignments IS 'Stores which group members had each line item and how many units';
@@ -0,0 +1,42 @@
+-- Migration: Collaborative line item claiming
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Link group members to registered users so they can claim items themselves
+ALTER TABLE group_members ADD COLUMN IF NOT EXISTS user_id UUID;
+CREATE INDEX IF NOT EXISTS idx_group_members_user_id ON group_members(user_id);
+
+-- Create bill_claim_sessions table
+CREATE TABLE IF NOT EXISTS bill_claim_sessions (
+    id UUID PRIMARY KEY,
+    bill_id UUID NOT NULL,
+    group_id UUID NOT NULL,
+    opened_by_user_id UUID NOT NULL,
+    status VARCHAR(25) NOT NULL,
+    over_claim_policy VARCHAR(25) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    finalized_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bill_claim_sessions_bill_id ON bill_claim_sessions(bill_id);
+CREATE INDEX IF NOT EXISTS idx_bill_claim_sessions_group_id ON bill_claim_sessions(group_id);
+
+-- Create bill_line_item_claims table
+CREATE TABLE IF NOT EXISTS bill_line_item_claims (
+    id UUID PRIMARY KEY,
+    session_id UUID NOT NULL,
+    line_item_id UUID NOT NULL,
+    group_member_id UUID NOT NULL,
+    units DECIMAL(10,3) NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CONSTRAINT fk_bill_claim_sessions_claims FOREIGN KEY (session_id) REFERENCES bill_claim_sessions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_bill_line_item_claims_session_id ON bill_line_item_claims(session_id);
+CREATE INDEX IF NOT EXISTS idx_bill_line_item_claims_line_item_id ON bill_line_item_claims(line_item_id);
+CREATE INDEX IF NOT EXISTS idx_bill_line_item_claims_group_member_id ON bill_line_item_claims(group_member_id);
+
+-- Add comments for documentation
+COMMENT ON COLUMN group_members.user_id IS 'Registered user linked to this group member, if any';
+COMMENT ON TABLE bill_claim_sessions IS 'Claim sessions where group members pick the line items they had';
+COMMENT ON TABLE bill_line_item_claims IS 'Units of a line item claimed by a group member during a claim session';
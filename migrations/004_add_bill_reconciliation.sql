@@ -0,0 +1,16 @@
+-- Migration: Reconcile extracted bill amounts
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Amounts detected next to the total
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS subtotal_amount DECIMAL;
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS tax_amount DECIMAL;
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS tip_amount DECIMAL;
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS discount_amount DECIMAL;
+
+-- Result of checking that line items and adjustments add up to the total
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS reconciliation_status VARCHAR(25);
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS discrepancies JSONB;
+
+-- Add comments for documentation
+COMMENT ON COLUMN bills.reconciliation_status IS 'balanced, mismatch, or incomplete when there is not enough data to check the total';
+COMMENT ON COLUMN bills.discrepancies IS 'Fields whose amounts do not match the other extracted amounts';
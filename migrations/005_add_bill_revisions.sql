@@ -0,0 +1,32 @@
+-- Migration: Manual correction of OCR results with edit history
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Version of the bill, bumped on every correction
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS edited_fields JSONB;
+
+-- Provenance of line items
+ALTER TABLE bill_line_items ADD COLUMN IF NOT EXISTS source VARCHAR(10) NOT NULL DEFAULT 'ocr';
+ALTER TABLE bill_line_items ADD COLUMN IF NOT EXISTS edited_fields JSONB;
+
+-- Create bill_revisions table
+CREATE TABLE IF NOT EXISTS bill_revisions (
+    id UUID PRIMARY KEY,
+    bill_id UUID NOT NULL,
+    line_item_id UUID,
+    version INTEGER NOT NULL,
+    field VARCHAR(50) NOT NULL,
+    value TEXT,
+    source VARCHAR(10) NOT NULL,
+    edited_by_user_id UUID,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_bill_revisions_bill_id ON bill_revisions(bill_id);
+CREATE INDEX IF NOT EXISTS idx_bill_revisions_line_item_id ON bill_revisions(line_item_id);
+
+-- Add comments for documentation
+COMMENT ON COLUMN bills.edited_fields IS 'Bill fields whose current value was entered by a user instead of OCR';
+COMMENT ON COLUMN bill_line_items.source IS 'ocr when the item was extracted, user when it was added by hand';
+COMMENT ON TABLE bill_revisions IS 'Every OCR value and user correction of bill and line item fields';
@@ -0,0 +1,25 @@
+-- Migration: Asynchronous bill analysis
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Create analysis_jobs table
+CREATE TABLE IF NOT EXISTS analysis_jobs (
+    id UUID PRIMARY KEY,
+    bill_id UUID NOT NULL,
+    status VARCHAR(25) NOT NULL,
+    options JSONB,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    locked_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_analysis_jobs_bill_id ON analysis_jobs(bill_id);
+CREATE INDEX IF NOT EXISTS idx_analysis_jobs_status_run_at ON analysis_jobs(status, run_at);
+
+-- Add comments for documentation
+COMMENT ON TABLE analysis_jobs IS 'Queue of bills waiting to be analyzed by the background workers';
+COMMENT ON COLUMN analysis_jobs.locked_at IS 'When a worker claimed the job; jobs locked longer than the lease are handed out again';
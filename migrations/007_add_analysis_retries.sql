@@ -0,0 +1,11 @@
+-- Migration: Retries and dead-lettering of failed analyses
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Attempts and last error of the background analysis
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS analysis_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS last_analysis_error TEXT;
+
+-- Add comments for documentation
+COMMENT ON COLUMN bills.analysis_attempts IS 'Number of times the bill was sent to Textract';
+COMMENT ON COLUMN bills.last_analysis_error IS 'Error of the last failed analysis, cleared once the bill is analyzed';
+COMMENT ON COLUMN analysis_jobs.status IS 'queued, running, succeeded, or failed; failed jobs are dead-lettered until an admin retries them';
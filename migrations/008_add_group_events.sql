@@ -0,0 +1,18 @@
+-- Migration: Live group updates
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Create group_events table
+CREATE TABLE IF NOT EXISTS group_events (
+    id BIGSERIAL PRIMARY KEY,
+    group_id UUID NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    actor_user_id UUID,
+    payload JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_group_events_group_id ON group_events(group_id);
+
+-- Add comments for documentation
+COMMENT ON TABLE group_events IS 'Log of group changes; WebSocket clients resume from the ID of the last event they received';
@@ -0,0 +1,33 @@
+-- Migration: Batch upload of receipts
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Batch a bill was uploaded in
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS batch_id UUID;
+
+-- Create bill_batches table
+CREATE TABLE IF NOT EXISTS bill_batches (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    group_id UUID,
+    paid_by_member_id UUID,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create bill_batch_files table
+CREATE TABLE IF NOT EXISTS bill_batch_files (
+    id UUID PRIMARY KEY,
+    batch_id UUID NOT NULL REFERENCES bill_batches(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    status VARCHAR(25) NOT NULL,
+    bill_id UUID,
+    error TEXT
+);
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_bills_batch_id ON bills(batch_id);
+CREATE INDEX IF NOT EXISTS idx_bill_batches_user_id ON bill_batches(user_id);
+CREATE INDEX IF NOT EXISTS idx_bill_batch_files_batch_id ON bill_batch_files(batch_id);
+
+-- Add comments for documentation
+COMMENT ON TABLE bill_batch_files IS 'Outcome of each file of a batch upload; rejected files have no bill';
@@ -0,0 +1,8 @@
+-- Migration: Page numbers of line items from multi-page documents
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Page of the document each extracted line item was found on
+ALTER TABLE bill_line_items ADD COLUMN IF NOT EXISTS page_number BIGINT;
+
+-- Add comments for documentation
+COMMENT ON COLUMN bill_line_items.page_number IS 'Page of the PDF or TIFF the item was extracted from; NULL for items added by users';
@@ -0,0 +1,8 @@
+-- Migration: Original files of normalized bill images
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- File as uploaded, when the stored file is a normalized copy
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS original_storage_path VARCHAR(255);
+
+-- Add comments for documentation
+COMMENT ON COLUMN bills.original_storage_path IS 'Uploaded file before conversion, rotation and downscaling; NULL when the file was stored as uploaded';
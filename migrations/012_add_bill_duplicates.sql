@@ -0,0 +1,21 @@
+-- Migration: Duplicate receipt detection
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Hashes of the uploaded file and fingerprint of the analyzed receipt
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64);
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS perceptual_hash BIGINT;
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS fingerprint VARCHAR(255);
+
+-- Bill that an upload repeats
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS duplicate_of_bill_id UUID;
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS duplicate_reason VARCHAR(20);
+ALTER TABLE bill_batch_files ADD COLUMN IF NOT EXISTS duplicate_of_bill_id UUID;
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_bills_content_hash ON bills(content_hash);
+CREATE INDEX IF NOT EXISTS idx_bills_fingerprint ON bills(fingerprint);
+
+-- Add comments for documentation
+COMMENT ON COLUMN bills.perceptual_hash IS 'Difference hash of the image; photos of the same receipt differ in a few bits';
+COMMENT ON COLUMN bills.fingerprint IS 'Normalized vendor, date and total in cents, set once the bill is analyzed';
+COMMENT ON COLUMN bills.duplicate_reason IS 'same_file, same_image, or same_receipt';
@@ -0,0 +1,8 @@
+-- Migration: Error codes of rejected batch files
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Upload validation code of a rejected file
+ALTER TABLE bill_batch_files ADD COLUMN IF NOT EXISTS error_code VARCHAR(50);
+
+-- Add comments for documentation
+COMMENT ON COLUMN bill_batch_files.error_code IS 'missing_file, empty_file, file_too_large, request_too_large, unsupported_file_type, corrupt_file, or duplicate_bill';
@@ -0,0 +1,28 @@
+-- Migration: Resumable uploads
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Create resumable_uploads table
+CREATE TABLE IF NOT EXISTS resumable_uploads (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    uploaded_bytes BIGINT NOT NULL DEFAULT 0,
+    metadata TEXT,
+    options JSONB,
+    storage_key TEXT NOT NULL,
+    staging_handle TEXT,
+    bill_id UUID,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_resumable_uploads_user_id ON resumable_uploads(user_id);
+CREATE INDEX IF NOT EXISTS idx_resumable_uploads_expires_at ON resumable_uploads(expires_at);
+
+-- Add comments for documentation
+COMMENT ON TABLE resumable_uploads IS 'Bill files uploaded in chunks with the tus protocol; bill_id is set once the upload is complete';
+COMMENT ON COLUMN resumable_uploads.staging_handle IS 'Staging file name on disk, or S3 multipart upload ID';
@@ -0,0 +1,10 @@
+-- Migration: Thumbnails and previews of bill files
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Renditions of the bill file shown in lists and previews
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS thumbnail_storage_path VARCHAR(255);
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS preview_storage_path VARCHAR(255);
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS previews_rendered_at TIMESTAMP WITH TIME ZONE;
+
+-- Add comments for documentation
+COMMENT ON COLUMN bills.previews_rendered_at IS 'NULL until the renditions are rendered; files that cannot be rendered have none';
@@ -0,0 +1,22 @@
+-- Migration: Stored Textract responses
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Create analysis_runs table
+CREATE TABLE IF NOT EXISTS analysis_runs (
+    id UUID PRIMARY KEY,
+    bill_id UUID NOT NULL,
+    options JSONB,
+    parser_version INTEGER NOT NULL,
+    response BYTEA,
+    response_size INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    reparsed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_analysis_runs_bill_id ON analysis_runs(bill_id);
+
+-- Add comments for documentation
+COMMENT ON TABLE analysis_runs IS 'Full Textract response of every analysis of a bill, to parse it again without another analysis';
+COMMENT ON COLUMN analysis_runs.response IS 'Gzip-compressed JSON of the Textract response';
+COMMENT ON COLUMN analysis_runs.parser_version IS 'Parser version and options the response was last parsed with';
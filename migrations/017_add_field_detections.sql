@@ -0,0 +1,10 @@
+-- Migration: Confidence and location of extracted fields
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Confidence and bounding box of each extracted field, by field name
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS field_detections JSONB;
+ALTER TABLE bill_line_items ADD COLUMN IF NOT EXISTS field_detections JSONB;
+
+-- Add comments for documentation
+COMMENT ON COLUMN bills.field_detections IS 'Confidence (0 to 1), page and bounding box of the extracted vendor, date and total';
+COMMENT ON COLUMN bill_line_items.field_detections IS 'Confidence (0 to 1), page and bounding box of each extracted field of the item';
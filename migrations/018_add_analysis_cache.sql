@@ -0,0 +1,20 @@
+-- Migration: Cached Textract responses
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Create analysis_cache table
+CREATE TABLE IF NOT EXISTS analysis_cache (
+    content_hash VARCHAR(64) PRIMARY KEY,
+    response BYTEA,
+    response_size INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Create indexes for eviction
+CREATE INDEX IF NOT EXISTS idx_analysis_cache_created_at ON analysis_cache(created_at);
+CREATE INDEX IF NOT EXISTS idx_analysis_cache_expires_at ON analysis_cache(expires_at);
+
+-- Add comments for documentation
+COMMENT ON TABLE analysis_cache IS 'Textract responses by document hash, to analyze a document uploaded again without calling Textract';
+COMMENT ON COLUMN analysis_cache.content_hash IS 'Hex-encoded SHA-256 hash of the analyzed document';
+COMMENT ON COLUMN analysis_cache.response IS 'Gzip-compressed JSON of the Textract response';
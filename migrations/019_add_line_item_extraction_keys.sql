@@ -0,0 +1,12 @@
+-- Migration: Extraction keys and user deletions of line items
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Where in the Textract response each extracted item was read
+ALTER TABLE bill_line_items ADD COLUMN IF NOT EXISTS extraction_key VARCHAR(32);
+
+-- Items deleted by a user, as opposed to items replaced by a new analysis
+ALTER TABLE bill_line_items ADD COLUMN IF NOT EXISTS deleted_by_user BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Add comments for documentation
+COMMENT ON COLUMN bill_line_items.extraction_key IS 'Document, group and item index of the item in the Textract response, empty for items added by users';
+COMMENT ON COLUMN bill_line_items.deleted_by_user IS 'Whether a user deleted the item, so analyzing the bill again does not add it back';
@@ -0,0 +1,10 @@
+-- Migration: Analysis claims on bills
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- The analysis currently processing a bill, the only one allowed to save its results
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS analysis_token UUID;
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS analysis_claimed_at TIMESTAMP WITH TIME ZONE;
+
+-- Add comments for documentation
+COMMENT ON COLUMN bills.analysis_token IS 'Token of the analysis that last marked the bill as processing';
+COMMENT ON COLUMN bills.analysis_claimed_at IS 'When that analysis claimed the bill, a job handed out again after its lease expired takes older claims over';
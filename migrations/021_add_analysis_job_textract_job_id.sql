@@ -0,0 +1,8 @@
+-- Migration: Textract jobs of analysis jobs
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Asynchronous Textract job started for a bill, resumed by later attempts
+ALTER TABLE analysis_jobs ADD COLUMN IF NOT EXISTS textract_job_id VARCHAR(64);
+
+-- Add comments for documentation
+COMMENT ON COLUMN analysis_jobs.textract_job_id IS 'JobId of the StartExpenseAnalysis call for multi-page documents, polled again on retry instead of starting another';
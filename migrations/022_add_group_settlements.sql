@@ -0,0 +1,20 @@
+-- Migration: Group settlements
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Create group_settlements table
+CREATE TABLE IF NOT EXISTS group_settlements (
+    id UUID PRIMARY KEY,
+    group_id UUID NOT NULL,
+    from_member_id UUID NOT NULL,
+    to_member_id UUID NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    note VARCHAR(255),
+    recorded_by_user_id UUID,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create indexes for faster lookups
+CREATE INDEX IF NOT EXISTS idx_group_settlements_group_id ON group_settlements(group_id);
+
+-- Add comments for documentation
+COMMENT ON TABLE group_settlements IS 'Payments between group members, counted in the group balances and published as settlement.recorded events';
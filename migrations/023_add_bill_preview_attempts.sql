@@ -0,0 +1,10 @@
+-- Migration: Preview render attempts
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Background renders tried for bills without renditions
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS preview_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE bills ADD COLUMN IF NOT EXISTS previews_attempted_at TIMESTAMP WITH TIME ZONE;
+
+-- Add comments for documentation
+COMMENT ON COLUMN bills.preview_attempts IS 'Background preview renders tried; bills are given up on after 5 failed attempts';
+COMMENT ON COLUMN bills.previews_attempted_at IS 'Last background preview render; failed bills are tried again an hour later';
@@ -0,0 +1,10 @@
+-- Migration: Leases of resumable uploads
+-- This file is for reference only. The actual migration is handled by GORM AutoMigrate.
+
+-- Lease of the request writing a chunk of an upload
+ALTER TABLE resumable_uploads ADD COLUMN IF NOT EXISTS lock_token UUID;
+ALTER TABLE resumable_uploads ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
+
+-- Add comments for documentation
+COMMENT ON COLUMN resumable_uploads.lock_token IS 'Token of the request holding the upload, so chunks are written one at a time across API instances';
+COMMENT ON COLUMN resumable_uploads.locked_until IS 'End of the lease, renewed while the chunk is received; NULL when the upload is free';
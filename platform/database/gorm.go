@@ -69,8 +69,20 @@ func ConnectGORM(cfg config.DatabaseConfig) (*gorm.DB, error) {
 		&domain.User{},
 		&domain.Bill{},
 		&domain.LineItem{},
+		&domain.LineItemAssignment{},
+		&domain.BillRevision{},
+		&domain.AnalysisJob{},
+		&domain.AnalysisRun{},
+		&domain.AnalysisCacheEntry{},
+		&domain.BillBatch{},
+		&domain.BillBatchFile{},
+		&domain.ResumableUpload{},
 		&domain.Group{},
 		&domain.GroupMember{},
+		&domain.GroupEvent{},
+		&domain.Settlement{},
+		&domain.ClaimSession{},
+		&domain.LineItemClaim{},
 		//&domain.Expense{}, // Add other domain models you need tables for
 	)
 	if err != nil {